@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tomekjarosik/inout_tester/internal/backup"
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+)
+
+// AdminRequestProcessor processes HTTP requests of administrators, authorized with a bearer token
+type AdminRequestProcessor struct {
+	Storage           submission.Storage
+	Processor         submission.Processor
+	ProblemsDirectory string
+	// Token admin endpoints are disabled if it's empty
+	Token string
+}
+
+// NewAdminRequestProcessor constructor
+func NewAdminRequestProcessor(store submission.Storage, sp submission.Processor, problemsDir string, token string) AdminRequestProcessor {
+	return AdminRequestProcessor{store, sp, problemsDir, token}
+}
+
+// authorized passes only requests with "Authorization: Bearer <token>" to the handler
+func (ap *AdminRequestProcessor) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ap.Token == "" {
+			http.Error(w, "admin endpoints are disabled, start the server with -admin-token", http.StatusNotFound)
+			return
+		}
+		expected := []byte("Bearer " + ap.Token)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// apiBackup streams a backup of all submissions, with problems if ?problems=true
+func (ap *AdminRequestProcessor) apiBackup(w http.ResponseWriter, r *http.Request) {
+	problemsDir := ""
+	if r.URL.Query().Get("problems") == "true" {
+		problemsDir = ap.ProblemsDirectory
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="inout-backup-%s.tar.gz"`, time.Now().Format("20060102-150405")))
+	if _, err := backup.Export(w, ap.Storage, problemsDir); err != nil {
+		// the response has already started, the client gets a truncated tarball
+		log.Println("backup failed:", err)
+	}
+}
+
+// apiRestore imports a backup sent as the request body, ?onConflict=skip|overwrite|fail, ?problems=true
+func (ap *AdminRequestProcessor) apiRestore(w http.ResponseWriter, r *http.Request) {
+	policy, err := backup.ParseConflictPolicy(r.URL.Query().Get("onConflict"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	problemsDir := ""
+	if r.URL.Query().Get("problems") == "true" {
+		problemsDir = ap.ProblemsDirectory
+	}
+	report, err := backup.Import(r.Body, ap.Storage, problemsDir, policy)
+	switch {
+	case err == backup.ErrConflict:
+		writeJSON(w, http.StatusConflict, report)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// apiRejudge judges a finished submission again, without reusing results of the same solution
+func (ap *AdminRequestProcessor) apiRejudge(w http.ResponseWriter, r *http.Request) {
+	id, err := submission.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metadata, ok := ap.Storage.Get(id)
+	if !ok {
+		http.Error(w, "submission not found", http.StatusNotFound)
+		return
+	}
+	if !metadata.Status.Final() {
+		http.Error(w, "submission is still being processed", http.StatusConflict)
+		return
+	}
+	ap.Processor.Rejudge(metadata)
+	w.WriteHeader(http.StatusAccepted)
+}
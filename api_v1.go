@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// apiV1Prefix prefix of the versioned JSON API, every response of the API is JSON except sources of submissions
+const apiV1Prefix = "/api/v1"
+
+// apiError body of every error response of the JSON API
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+// submitResponse body of the response to a submission accepted by the JSON API
+type submitResponse struct {
+	ID     submission.ID     `json:"id"`
+	Status submission.Status `json:"status"`
+	// StatusURL poll it, or follow /api/events?id=<id>, for results
+	StatusURL string `json:"statusUrl"`
+	PageURL   string `json:"pageUrl"`
+}
+
+// submissionsPage a page of submissions in the JSON API, see submission.Page
+type submissionsPage struct {
+	Submissions []submission.Info `json:"submissions"`
+	NextCursor  string            `json:"nextCursor,omitempty"`
+}
+
+// problemInfo a problem of the archive in the JSON API
+type problemInfo struct {
+	Name      string `json:"name"`
+	TestCount int    `json:"testCount"`
+	// TimeLimit and MemoryLimit the largest limits among the testcases
+	TimeLimit   time.Duration   `json:"timeLimit"`
+	MemoryLimit int             `json:"memoryLimit"`
+	Testcases   []testcase.Info `json:"testcases"`
+}
+
+// registerAPIv1 adds routes of the JSON API to the router
+func (rp *RequestProcessor) registerAPIv1(router *mux.Router) {
+	api := router.PathPrefix(apiV1Prefix).Subrouter()
+	api.HandleFunc("/submissions", rp.apiV1ListSubmissions).Methods("GET")
+	api.HandleFunc("/submissions", rp.apiV1Submit).Methods("POST")
+	api.HandleFunc("/submissions/{id}", rp.apiV1GetSubmission).Methods("GET")
+	api.HandleFunc("/submissions/{id}/source", rp.apiV1GetSource).Methods("GET")
+	api.HandleFunc("/problems", rp.apiV1ListProblems).Methods("GET")
+	api.HandleFunc("/problems/{problemName}", rp.apiV1GetProblem).Methods("GET")
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+	})
+	api.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed for %s", r.Method, r.URL.Path))
+	})
+}
+
+// apiV1ListSubmissions returns a page of submissions with results, see parseQuery for parameters
+func (rp *RequestProcessor) apiV1ListSubmissions(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	page, err := rp.SubmissionStorage.Query(q)
+	if err == submission.ErrInvalidCursor {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res := submissionsPage{Submissions: make([]submission.Info, len(page.Submissions)), NextCursor: page.NextCursor}
+	for i, m := range page.Submissions {
+		res.Submissions[i] = submission.NewInfo(m)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// apiV1Submit accepts a solution sent as a multipart form, like /api/submit
+func (rp *RequestProcessor) apiV1Submit(w http.ResponseWriter, r *http.Request) {
+	metadata, status, err := rp.submitSolution(r)
+	if err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+	statusURL := apiV1Prefix + "/submissions/" + metadata.ID.String()
+	w.Header().Set("Location", statusURL)
+	writeJSON(w, http.StatusCreated, submitResponse{
+		ID:        metadata.ID,
+		Status:    metadata.Status,
+		StatusURL: statusURL,
+		PageURL:   "/submission/" + metadata.ID.String(),
+	})
+}
+
+// submissionOf the submission with the ID in the path, writes the error response if there is none
+func (rp *RequestProcessor) submissionOf(w http.ResponseWriter, r *http.Request) (submission.Metadata, bool) {
+	key := mux.Vars(r)["id"]
+	submissionID, err := submission.ParseID(key)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid submission id '%s'", key))
+		return submission.Metadata{}, false
+	}
+	metadata, found := rp.SubmissionStorage.Get(submissionID)
+	if !found {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("submission %s not found", key))
+	}
+	return metadata, found
+}
+
+func (rp *RequestProcessor) apiV1GetSubmission(w http.ResponseWriter, r *http.Request) {
+	if metadata, found := rp.submissionOf(w, r); found {
+		writeJSON(w, http.StatusOK, submission.NewInfo(metadata))
+	}
+}
+
+// apiV1GetSource the solution file of the submission, an archive for submissions of many files
+func (rp *RequestProcessor) apiV1GetSource(w http.ResponseWriter, r *http.Request) {
+	metadata, found := rp.submissionOf(w, r)
+	if !found {
+		return
+	}
+	solution, err := rp.SubmissionStorage.Download(metadata)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer solution.Close()
+	if metadata.SourceArchive != "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", metadata.SolutionFilename))
+	io.Copy(w, solution)
+}
+
+func (rp *RequestProcessor) problemInfo(problemName string) (problemInfo, error) {
+	testcases, err := rp.TestcaseArchive.Testcases(problemName)
+	if err != nil {
+		return problemInfo{}, err
+	}
+	info := problemInfo{Name: problemName, TestCount: len(testcases), Testcases: testcases}
+	for _, tc := range testcases {
+		if tc.TimeLimit > info.TimeLimit {
+			info.TimeLimit = tc.TimeLimit
+		}
+		if tc.MemoryLimit > info.MemoryLimit {
+			info.MemoryLimit = tc.MemoryLimit
+		}
+	}
+	if info.Testcases == nil {
+		info.Testcases = []testcase.Info{}
+	}
+	return info, nil
+}
+
+func (rp *RequestProcessor) apiV1ListProblems(w http.ResponseWriter, r *http.Request) {
+	problems, err := rp.TestcaseArchive.Problems()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res := make([]problemInfo, 0, len(problems))
+	for _, problemName := range problems {
+		info, err := rp.problemInfo(problemName)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+		res = append(res, info)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (rp *RequestProcessor) apiV1GetProblem(w http.ResponseWriter, r *http.Request) {
+	problemName := mux.Vars(r)["problemName"]
+	known, err := rp.knownProblem(problemName)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !known {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("problem %s not found", problemName))
+		return
+	}
+	info, err := rp.problemInfo(problemName)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// newTestAPI router with the JSON API of a server with the multiply_by_2 problem and no submissions
+func newTestAPI(t *testing.T) (*mux.Router, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testapiv1-*")
+	assert.NoError(t, err)
+	problemsDir := filepath.Join(dir, "problems")
+	generateMultiplyBy2(problemsDir)
+	store := submission.NewDefaultStorage(filepath.Join(dir, "submissions"))
+	assert.NoError(t, store.Init())
+	archive := testcase.NewArchive(problemsDir)
+	events := submission.NewEventBus()
+	rp := NewRequestProcessor(store, submission.NewProcessor(store, archive, events, nil), archive, events)
+	router := mux.NewRouter().StrictSlash(true)
+	rp.registerAPIv1(router)
+	return router, func() { os.RemoveAll(dir) }
+}
+
+func serve(router *mux.Router, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func submitForm(t *testing.T, fields map[string]string, solution string) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		assert.NoError(t, form.WriteField(name, value))
+	}
+	file, err := form.CreateFormFile("solution", "solution.cpp")
+	assert.NoError(t, err)
+	io.WriteString(file, solution)
+	assert.NoError(t, form.Close())
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/submissions", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+// assertAPIError checks the status code and that the body is an apiError
+func assertAPIError(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	assert.Equal(t, status, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var res apiError
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.NotEmpty(t, res.Error)
+}
+
+func TestAPIv1_Submissions(t *testing.T) {
+	router, cleanup := newTestAPI(t)
+	defer cleanup()
+	fields := map[string]string{"problemName": "multiply_by_2", "language": testcase.DefaultLanguageID,
+		"compilationMode": string(testcase.ReleaseMode), "author": "alice"}
+
+	rec := serve(router, submitForm(t, fields, "int main() {}"))
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var submitted submitResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &submitted))
+	assert.Equal(t, submission.Queued, submitted.Status)
+	assert.Equal(t, "/api/v1/submissions/"+submitted.ID.String(), submitted.StatusURL)
+	assert.Equal(t, submitted.StatusURL, rec.Header().Get("Location"))
+	assert.Equal(t, "/submission/"+submitted.ID.String(), submitted.PageURL)
+
+	rec = serve(router, httptest.NewRequest(http.MethodGet, submitted.StatusURL, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var info submission.Info
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, submitted.ID, info.ID)
+	assert.Equal(t, "multiply_by_2", info.ProblemName)
+	assert.Equal(t, "alice", info.Author)
+	var raw map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &raw))
+	for _, internal := range []string{"schemaVersion", "solutionFilename", "solutionHash", "executableFilename", "judgeKey", "workerCount"} {
+		assert.NotContains(t, raw, internal)
+	}
+
+	rec = serve(router, httptest.NewRequest(http.MethodGet, "/api/v1/submissions?author=alice", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var page struct {
+		Submissions []map[string]interface{} `json:"submissions"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	if assert.Equal(t, 1, len(page.Submissions)) {
+		assert.Equal(t, submitted.ID.String(), page.Submissions[0]["id"])
+		assert.NotContains(t, page.Submissions[0], "solutionHash")
+	}
+
+	rec = serve(router, httptest.NewRequest(http.MethodGet, submitted.StatusURL+"/source", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "int main() {}", rec.Body.String())
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+}
+
+func TestAPIv1_SubmissionErrors(t *testing.T) {
+	router, cleanup := newTestAPI(t)
+	defer cleanup()
+	unknown := submission.NewMetadata("multiply_by_2", testcase.Language{}, testcase.ReleaseMode).ID.String()
+
+	assertAPIError(t, serve(router, httptest.NewRequest(http.MethodPost, "/api/v1/submissions", nil)), http.StatusBadRequest)
+	assertAPIError(t, serve(router, submitForm(t, map[string]string{"problemName": "no_such_problem",
+		"language": testcase.DefaultLanguageID}, "int main() {}")), http.StatusBadRequest)
+	assertAPIError(t, serve(router, submitForm(t, map[string]string{"problemName": "multiply_by_2",
+		"language": "cobol"}, "int main() {}")), http.StatusBadRequest)
+	assertAPIError(t, serve(router, submitForm(t, map[string]string{"problemName": "multiply_by_2",
+		"language": testcase.DefaultLanguageID, "compilationMode": "NoSuchMode"}, "int main() {}")), http.StatusBadRequest)
+
+	for url, status := range map[string]int{
+		"/api/v1/submissions?status=Unknown":           http.StatusBadRequest,
+		"/api/v1/submissions?cursor=invalid":           http.StatusBadRequest,
+		"/api/v1/submissions/not-an-id":                http.StatusBadRequest,
+		"/api/v1/submissions/" + unknown:               http.StatusNotFound,
+		"/api/v1/submissions/" + unknown + "/source":   http.StatusNotFound,
+		"/api/v1/submissions/not-an-id/source":         http.StatusBadRequest,
+		"/api/v1/problems/no_such_problem":             http.StatusNotFound,
+		"/api/v1/no-such-endpoint":                     http.StatusNotFound,
+		"/api/v1/submissions/" + unknown + "/comments": http.StatusNotFound,
+	} {
+		assertAPIError(t, serve(router, httptest.NewRequest(http.MethodGet, url, nil)), status)
+	}
+	assertAPIError(t, serve(router, httptest.NewRequest(http.MethodDelete, "/api/v1/submissions", nil)), http.StatusMethodNotAllowed)
+	assertAPIError(t, serve(router, httptest.NewRequest(http.MethodPost, "/api/v1/problems", nil)), http.StatusMethodNotAllowed)
+}
+
+func TestAPIv1_Problems(t *testing.T) {
+	router, cleanup := newTestAPI(t)
+	defer cleanup()
+
+	rec := serve(router, httptest.NewRequest(http.MethodGet, "/api/v1/problems", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var problems []problemInfo
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problems))
+	if assert.Equal(t, 1, len(problems)) {
+		assert.Equal(t, "multiply_by_2", problems[0].Name)
+		assert.Equal(t, 4, problems[0].TestCount)
+		assert.Equal(t, 4, len(problems[0].Testcases))
+	}
+
+	rec = serve(router, httptest.NewRequest(http.MethodGet, "/api/v1/problems/multiply_by_2", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var problem problemInfo
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, problems[0], problem)
+}
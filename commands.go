@@ -0,0 +1,234 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/tomekjarosik/inout_tester/internal/backup"
+	"github.com/tomekjarosik/inout_tester/internal/client"
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// loadedStorage storage selected with -storage with all submissions loaded
+func loadedStorage() submission.Storage {
+	store := newStorage()
+	if err := store.Init(); err != nil {
+		log.Fatal(err)
+	}
+	if err := store.LoadAll(); err != nil {
+		log.Fatal(err)
+	}
+	return store
+}
+
+// runBackup implements "backup [-problems] <file>"
+func runBackup(args []string) {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	withProblems := flags.Bool("problems", false, "Include problems from -problems-dir")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal("usage: inout_tester [flags] backup [-problems] <file.tar.gz>")
+	}
+	problemsDir := ""
+	if *withProblems {
+		problemsDir = flagProblemsDirectory
+	}
+	out, err := os.Create(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	manifest, err := backup.Export(out, loadedStorage(), problemsDir)
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		os.Remove(flags.Arg(0))
+		log.Fatal("backup failed: ", err)
+	}
+	fmt.Printf("Saved %d submissions and %d problems into %s\n", manifest.Submissions, manifest.Problems, flags.Arg(0))
+}
+
+// runRestore implements "restore [-problems] [-on-conflict skip|overwrite|fail] <file>"
+func runRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	withProblems := flags.Bool("problems", false, "Restore problems into -problems-dir")
+	onConflict := flags.String("on-conflict", string(backup.ConflictSkip),
+		"What to do with submissions and problem files which already exist: skip, overwrite or fail")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal("usage: inout_tester [flags] restore [-problems] [-on-conflict skip|overwrite|fail] <file.tar.gz>")
+	}
+	policy, err := backup.ParseConflictPolicy(*onConflict)
+	if err != nil {
+		log.Fatal(err)
+	}
+	problemsDir := ""
+	if *withProblems {
+		problemsDir = flagProblemsDirectory
+	}
+	in, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+	report, err := backup.Import(in, loadedStorage(), problemsDir, policy)
+	for _, conflict := range report.Conflicts {
+		fmt.Println("conflict:", conflict)
+	}
+	if err != nil {
+		log.Fatal("restore failed: ", err)
+	}
+	fmt.Printf("Restored %d submissions (%d overwritten) and %d problem files\n",
+		report.Imported, report.Overwritten, report.ProblemFiles)
+}
+
+// Exit codes of the client commands
+const (
+	exitOK = 0
+	// exitRejected the submission was judged and didn't pass all the tests
+	exitRejected = 1
+	// exitFailed the command failed, e.g. the server is unreachable
+	exitFailed = 2
+	// exitPending the submission isn't judged yet
+	exitPending = 3
+)
+
+// parseInterleaved parses flags which may follow positional arguments, e.g. "status <id> -wait",
+// and returns the positional arguments
+func parseInterleaved(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// defaultServerURL server of the client commands, $INOUT_SERVER or the local one
+func defaultServerURL() string {
+	if server := os.Getenv("INOUT_SERVER"); server != "" {
+		return server
+	}
+	return "http://localhost:8080"
+}
+
+// colorEnabled returns true if the output is a terminal and colors weren't turned off
+func colorEnabled(noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// runSubmit implements "submit -problem <problem> [-server URL] [-mode mode] [-language id] [-no-wait] <file>"
+func runSubmit(args []string) int {
+	flags := flag.NewFlagSet("submit", flag.ExitOnError)
+	server := flags.String("server", defaultServerURL(), "URL of the server, $INOUT_SERVER if set")
+	problem := flags.String("problem", "", "Name of the problem")
+	mode := flags.String("mode", string(testcase.ReleaseMode), "Compilation mode")
+	language := flags.String("language", "", "Language of the solution, recognized by the extension of the file if empty")
+	author := flags.String("author", "", "Author of the submission")
+	entry := flags.String("entry", "", "File with the entry point, for archives of many source files")
+	noWait := flags.Bool("no-wait", false, "Exit after submitting, without waiting for results")
+	noColor := flags.Bool("no-color", false, "Don't color the output")
+	files := parseInterleaved(flags, args)
+	if len(files) != 1 || *problem == "" {
+		fmt.Fprintln(os.Stderr, "usage: inout_tester submit -problem <problem> [-server URL] [-mode mode] [-language id] [-no-wait] <file>")
+		return exitFailed
+	}
+	if *language == "" {
+		lang, ok := client.LanguageOf(files[0])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unable to recognize the language of %s, choose it with -language\n", files[0])
+			return exitFailed
+		}
+		*language = lang
+	}
+	solution, err := os.Open(files[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	defer solution.Close()
+
+	c := client.NewClient(*server)
+	res, err := c.Submit(client.Submission{
+		ProblemName:     *problem,
+		Language:        *language,
+		CompilationMode: testcase.CompilationMode(*mode),
+		Author:          *author,
+		EntryFile:       *entry,
+		Filename:        files[0],
+		Solution:        solution,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "submit failed:", err)
+		return exitFailed
+	}
+	fmt.Printf("Submitted %s (%s), see %s%s\n", res.ID, res.Status, strings.TrimSuffix(*server, "/"), res.PageURL)
+	if *noWait {
+		return exitOK
+	}
+	return waitForResults(c, res.ID, colorEnabled(*noColor))
+}
+
+// runStatus implements "status [-server URL] [-wait] <id>"
+func runStatus(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	server := flags.String("server", defaultServerURL(), "URL of the server, $INOUT_SERVER if set")
+	wait := flags.Bool("wait", false, "Follow progress until the submission is judged")
+	noColor := flags.Bool("no-color", false, "Don't color the output")
+	ids := parseInterleaved(flags, args)
+	if len(ids) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: inout_tester status [-server URL] [-wait] <id>")
+		return exitFailed
+	}
+	id, err := submission.ParseID(ids[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid submission id '%s'\n", ids[0])
+		return exitFailed
+	}
+	c := client.NewClient(*server)
+	if *wait {
+		return waitForResults(c, id, colorEnabled(*noColor))
+	}
+	m, err := c.Get(id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	return printVerdict(m, colorEnabled(*noColor))
+}
+
+// waitForResults prints progress of the submission until it's judged, then its results
+func waitForResults(c client.Client, id submission.ID, color bool) int {
+	m, err := c.Wait(id, func(e submission.Event) {
+		client.PrintProgress(os.Stdout, e, color)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to follow the submission:", err)
+		return exitFailed
+	}
+	return printVerdict(m, color)
+}
+
+// printVerdict prints results of the submission and returns the exit code for its verdict
+func printVerdict(m submission.Metadata, color bool) int {
+	client.PrintResults(os.Stdout, m, color)
+	switch {
+	case client.Accepted(m):
+		return exitOK
+	case m.Status.Final():
+		return exitRejected
+	}
+	return exitPending
+}
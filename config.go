@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// Config server configuration, read from the file given with -config flag
+type Config struct {
+	// CompilationProfiles replace the default compilation modes if not empty
+	CompilationProfiles []testcase.CompilationProfile `json:"compilationProfiles"`
+	// CompilationLimits replace the default limits of the compiler if set
+	CompilationLimits *testcase.CompilationLimits `json:"compilationLimits,omitempty"`
+	// CrashBacktraces replace the default configuration of backtraces of crashed solutions if set
+	CrashBacktraces *testcase.CrashBacktraces `json:"crashBacktraces,omitempty"`
+	// Retention policy removing old submissions, nothing is removed if not set
+	Retention *submission.RetentionPolicy `json:"retention,omitempty"`
+}
+
+func loadConfig(filename string) (Config, error) {
+	var config Config
+	f, err := os.Open(filename)
+	if err != nil {
+		return config, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&config)
+	return config, err
+}
+
+// apply makes the configuration effective
+func (c Config) apply() error {
+	if len(c.CompilationProfiles) > 0 {
+		if err := testcase.SetCompilationProfiles(c.CompilationProfiles); err != nil {
+			return err
+		}
+	}
+	if c.CompilationLimits != nil {
+		if err := testcase.SetCompilationLimits(*c.CompilationLimits); err != nil {
+			return err
+		}
+	}
+	if c.CrashBacktraces != nil {
+		if err := testcase.SetCrashBacktraces(*c.CrashBacktraces); err != nil {
+			return err
+		}
+	}
+	if c.Retention != nil {
+		return c.Retention.Validate()
+	}
+	return nil
+}
+
+// retentionPolicy configured retention policy or the default one
+func (c Config) retentionPolicy() submission.RetentionPolicy {
+	if c.Retention != nil {
+		return *c.Retention
+	}
+	return submission.DefaultRetentionPolicy()
+}
@@ -5,6 +5,7 @@ go 1.14
 require (
 	github.com/google/uuid v1.1.2
 	github.com/gorilla/mux v1.8.0
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/stretchr/testify v1.6.1
 	golang.org/x/tools v0.0.0-20201125231158-b5590deeca9b // indirect
 )
@@ -0,0 +1,175 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+)
+
+// FormatVersion version of the layout of backup tarballs:
+//
+//	manifest.json
+//	submissions/<id>/metadata.json
+//	submissions/<id>/solution
+//	submissions/<id>/artifacts/<name>
+//	problems/<problem>/<file>
+const FormatVersion = 1
+
+const manifestName = "manifest.json"
+
+// Manifest description of the backup, the first entry of the tarball
+type Manifest struct {
+	FormatVersion int       `json:"formatVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// SchemaVersion of metadata of the submissions, older ones are upgraded when restored
+	SchemaVersion int  `json:"schemaVersion"`
+	Submissions   int  `json:"submissions"`
+	Problems      int  `json:"problems"`
+	WithProblems  bool `json:"withProblems"`
+}
+
+type tarWriter struct {
+	*tar.Writer
+	now time.Time
+}
+
+func (w tarWriter) writeFile(name string, size int64, content io.Reader) error {
+	err := w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: w.now, Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(w, content, size)
+	return err
+}
+
+func (w tarWriter) writeJSON(name string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return w.writeFile(name, int64(len(content)), bytes.NewReader(content))
+}
+
+// writeStream writes content of unknown size, it's buffered in a temporary file because tar needs the size first
+func (w tarWriter) writeStream(name string, content io.Reader) error {
+	tmp, err := ioutil.TempFile(os.TempDir(), "backup-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	size, err := io.Copy(tmp, content)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return w.writeFile(name, size, tmp)
+}
+
+// Export writes all submissions of the store, with solutions and artifacts, as a gzipped tarball.
+// Problems in problemsDir are included unless it's empty. The store must be loaded.
+func Export(out io.Writer, store submission.Storage, problemsDir string) (Manifest, error) {
+	gz := gzip.NewWriter(out)
+	w := tarWriter{Writer: tar.NewWriter(gz), now: time.Now()}
+	submissions := store.List()
+	manifest := Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     w.now,
+		SchemaVersion: submission.CurrentSchemaVersion,
+		Submissions:   len(submissions),
+		WithProblems:  problemsDir != "",
+	}
+	var problems []string
+	if problemsDir != "" {
+		infos, err := ioutil.ReadDir(problemsDir)
+		if err != nil {
+			return manifest, err
+		}
+		for _, info := range infos {
+			if info.IsDir() {
+				problems = append(problems, info.Name())
+			}
+		}
+		manifest.Problems = len(problems)
+	}
+	if err := w.writeJSON(manifestName, manifest); err != nil {
+		return manifest, err
+	}
+	for _, metadata := range submissions {
+		if err := exportSubmission(w, store, metadata); err != nil {
+			return manifest, err
+		}
+	}
+	for _, problem := range problems {
+		if err := exportProblem(w, problemsDir, problem); err != nil {
+			return manifest, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, gz.Close()
+}
+
+func exportSubmission(w tarWriter, store submission.Storage, metadata submission.Metadata) error {
+	dir := path.Join("submissions", metadata.ID.String())
+	metadata.SchemaVersion = submission.CurrentSchemaVersion
+	if err := w.writeJSON(path.Join(dir, "metadata.json"), metadata); err != nil {
+		return err
+	}
+	solution, err := store.Download(metadata)
+	if err != nil {
+		return err
+	}
+	err = w.writeStream(path.Join(dir, "solution"), solution)
+	solution.Close()
+	if err != nil {
+		return err
+	}
+	artifacts, err := store.ListArtifacts(metadata)
+	if err != nil {
+		return err
+	}
+	for _, name := range artifacts {
+		content, err := store.DownloadArtifact(metadata, name)
+		if err != nil {
+			return err
+		}
+		err = w.writeStream(path.Join(dir, "artifacts", name), content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportProblem(w tarWriter, problemsDir, problem string) error {
+	root := filepath.Join(problemsDir, problem)
+	return filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return w.writeFile(path.Join("problems", problem, filepath.ToSlash(rel)), info.Size(), f)
+	})
+}
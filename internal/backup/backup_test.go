@@ -0,0 +1,161 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+func newTestStore(t *testing.T) submission.Storage {
+	dir, err := ioutil.TempDir(os.TempDir(), "backupstore-*")
+	assert.NoError(t, err)
+	store := submission.NewDefaultStorage(dir)
+	assert.NoError(t, store.Init())
+	assert.NoError(t, store.LoadAll())
+	return store
+}
+
+func newTestProblems(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir(os.TempDir(), "backupproblems-*")
+	assert.NoError(t, err)
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(path.Dir(path.Join(dir, name)), 0755))
+		assert.NoError(t, ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+// readAll content of a downloaded file, or the error
+func readAll(content io.ReadCloser, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	defer content.Close()
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func testBackup(t *testing.T) ([]byte, []submission.Metadata) {
+	store := newTestStore(t)
+	defer store.Destroy()
+	problems := newTestProblems(t, map[string]string{"p1/t1.in": "1\n", "p1/t1.out": "2\n", "p1/grader/main.cpp": "int main() {}"})
+	defer os.RemoveAll(problems)
+	cpp, _ := testcase.LookupLanguage(testcase.DefaultLanguageID)
+	var submissions []submission.Metadata
+	for i, source := range []string{"first", "second"} {
+		m := submission.NewMetadata("p1", cpp, testcase.CoverageMode)
+		m.Status = submission.AllTestsCompleted
+		m.Author = "alice"
+		assert.NoError(t, store.Upload(&m, strings.NewReader(source)))
+		if i == 0 {
+			assert.NoError(t, store.UploadArtifact(m, submission.CoverageArtifact, strings.NewReader(`{"files": []}`)))
+		}
+		submissions = append(submissions, m)
+	}
+	var buf bytes.Buffer
+	manifest, err := Export(&buf, store, problems)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, manifest.Submissions)
+	assert.Equal(t, 1, manifest.Problems)
+	return buf.Bytes(), submissions
+}
+
+func TestExportImport(t *testing.T) {
+	backup, submissions := testBackup(t)
+	store := newTestStore(t)
+	defer store.Destroy()
+	problems := newTestProblems(t, nil)
+	defer os.RemoveAll(problems)
+
+	report, err := Import(bytes.NewReader(backup), store, problems, ConflictSkip)
+	assert.NoError(t, err)
+	assert.Equal(t, Report{Imported: 2, ProblemFiles: 3}, report)
+
+	for i, source := range []string{"first", "second"} {
+		m, found := store.Get(submissions[i].ID)
+		assert.True(t, found)
+		assert.Equal(t, "alice", m.Author)
+		assert.Equal(t, submission.AllTestsCompleted, m.Status)
+		assert.Equal(t, source, readAll(store.Download(m)))
+	}
+	artifacts, err := store.ListArtifacts(submissions[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{submission.CoverageArtifact}, artifacts)
+	assert.Equal(t, `{"files": []}`, readAll(store.DownloadArtifact(submissions[0], submission.CoverageArtifact)))
+	grader, err := ioutil.ReadFile(path.Join(problems, "p1", "grader", "main.cpp"))
+	assert.NoError(t, err)
+	assert.Equal(t, "int main() {}", string(grader))
+}
+
+func TestImport_Conflicts(t *testing.T) {
+	backup, submissions := testBackup(t)
+	store := newTestStore(t)
+	defer store.Destroy()
+	problems := newTestProblems(t, map[string]string{"p1/t1.in": "1\n", "p1/t1.out": "changed\n"})
+	defer os.RemoveAll(problems)
+	existing := submissions[1]
+	existing.Author = "bob"
+	assert.NoError(t, store.Upload(&existing, strings.NewReader("existing")))
+	conflicts := []string{"problems/p1/t1.out", existing.ID.String()}
+	sort.Strings(conflicts)
+
+	report, err := Import(bytes.NewReader(backup), store, problems, ConflictFail)
+	assert.Equal(t, ErrConflict, err)
+	assert.Equal(t, conflicts, report.Conflicts)
+	assert.Len(t, store.List(), 1)
+
+	report, err = Import(bytes.NewReader(backup), store, problems, ConflictSkip)
+	assert.NoError(t, err)
+	assert.Equal(t, Report{Imported: 1, ProblemFiles: 2, Conflicts: conflicts}, report)
+	m, _ := store.Get(existing.ID)
+	assert.Equal(t, "bob", m.Author)
+	expected, _ := ioutil.ReadFile(path.Join(problems, "p1", "t1.out"))
+	assert.Equal(t, "changed\n", string(expected))
+
+	report, err = Import(bytes.NewReader(backup), store, problems, ConflictOverwrite)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Overwritten)
+	m, _ = store.Get(existing.ID)
+	assert.Equal(t, "alice", m.Author)
+	assert.Equal(t, "second", readAll(store.Download(m)))
+	expected, _ = ioutil.ReadFile(path.Join(problems, "p1", "t1.out"))
+	assert.Equal(t, "2\n", string(expected))
+}
+
+func TestImport_InvalidBackup(t *testing.T) {
+	store := newTestStore(t)
+	defer store.Destroy()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "../escape", Mode: 0644, Size: 1, Typeflag: tar.TypeReg}))
+	tw.Write([]byte("x"))
+	tw.Close()
+	gz.Close()
+	_, err := Import(&buf, store, "", ConflictSkip)
+	assert.EqualError(t, err, "invalid entry '../escape' in the backup")
+
+	_, err = Import(strings.NewReader("not a tarball"), store, "", ConflictSkip)
+	assert.Error(t, err)
+
+	_, err = ParseConflictPolicy("merge")
+	assert.Error(t, err)
+	policy, err := ParseConflictPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, ConflictSkip, policy)
+}
@@ -0,0 +1,291 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+)
+
+// ConflictPolicy decides what Import does with submissions and problem files which already exist
+type ConflictPolicy string
+
+const (
+	// ConflictSkip keeps the existing submissions and files
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the existing submissions and files with the ones from the backup
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictFail imports nothing if there is any conflict
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// ParseConflictPolicy returns the policy with the name, empty name means ConflictSkip
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(name); policy {
+	case "":
+		return ConflictSkip, nil
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy '%s', expected skip, overwrite or fail", name)
+}
+
+// ErrConflict returned by Import with ConflictFail when the backup conflicts with existing data
+var ErrConflict = errors.New("backup conflicts with existing submissions or problems")
+
+// Report summary of Import
+type Report struct {
+	Imported     int `json:"imported"`
+	Overwritten  int `json:"overwritten"`
+	ProblemFiles int `json:"problemFiles"`
+	// Conflicts IDs of existing submissions and paths of existing problem files with a different content
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// validName returns true if name can be used as a single path element
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// extract unpacks the gzipped tarball into dir, rejecting entries outside of it
+func extract(in io.Reader, dir string) error {
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid entry '%s' in the backup", header.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// backedUpSubmission submission extracted from the backup
+type backedUpSubmission struct {
+	metadata submission.Metadata
+	dir      string
+}
+
+func readSubmissions(dir string) ([]backedUpSubmission, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(dir, "submissions"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res []backedUpSubmission
+	for _, info := range infos {
+		submissionDir := filepath.Join(dir, "submissions", info.Name())
+		content, err := ioutil.ReadFile(filepath.Join(submissionDir, "metadata.json"))
+		if err != nil {
+			return nil, err
+		}
+		metadata, err := submission.DecodeMetadata(content)
+		if err != nil {
+			return nil, fmt.Errorf("submission %s: %v", info.Name(), err)
+		}
+		if metadata.ID.String() != info.Name() || !validName(metadata.ProblemName) || !validName(metadata.SolutionFilename) {
+			return nil, fmt.Errorf("submission %s: invalid metadata", info.Name())
+		}
+		res = append(res, backedUpSubmission{metadata: metadata, dir: submissionDir})
+	}
+	return res, nil
+}
+
+// readProblemFiles paths of problem files in the backup, relative to the problems directory
+func readProblemFiles(dir string) ([]string, error) {
+	root := filepath.Join(dir, "problems")
+	var res []string
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && file == root {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		res = append(res, rel)
+		return nil
+	})
+	return res, err
+}
+
+// sameContent returns true if both files exist and have the same content
+func sameContent(a, b string) bool {
+	contentA, errA := ioutil.ReadFile(a)
+	contentB, errB := ioutil.ReadFile(b)
+	return errA == nil && errB == nil && bytes.Equal(contentA, contentB)
+}
+
+// Import restores submissions from a tarball written by Export, preserving their IDs, into the store,
+// which must be loaded. Problems are restored into problemsDir unless it's empty. Existing submissions and
+// problem files with a different content are handled according to the policy.
+func Import(in io.Reader, store submission.Storage, problemsDir string, policy ConflictPolicy) (Report, error) {
+	var report Report
+	dir, err := ioutil.TempDir(os.TempDir(), "restore-")
+	if err != nil {
+		return report, err
+	}
+	defer os.RemoveAll(dir)
+	if err = extract(in, dir); err != nil {
+		return report, err
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return report, fmt.Errorf("not a backup, %s is missing", manifestName)
+	}
+	var manifest Manifest
+	if err = json.Unmarshal(content, &manifest); err != nil {
+		return report, err
+	}
+	if manifest.FormatVersion > FormatVersion {
+		return report, fmt.Errorf("backup format %d is newer than supported %d", manifest.FormatVersion, FormatVersion)
+	}
+	submissions, err := readSubmissions(dir)
+	if err != nil {
+		return report, err
+	}
+	var problemFiles []string
+	if problemsDir != "" {
+		if problemFiles, err = readProblemFiles(dir); err != nil {
+			return report, err
+		}
+	}
+
+	existing := make(map[submission.ID]bool)
+	for _, s := range submissions {
+		if _, found := store.Get(s.metadata.ID); found {
+			existing[s.metadata.ID] = true
+			report.Conflicts = append(report.Conflicts, s.metadata.ID.String())
+		}
+	}
+	changed := make(map[string]bool)
+	for _, file := range problemFiles {
+		target := filepath.Join(problemsDir, file)
+		if _, err := os.Stat(target); err == nil && !sameContent(filepath.Join(dir, "problems", file), target) {
+			changed[file] = true
+			report.Conflicts = append(report.Conflicts, path.Join("problems", filepath.ToSlash(file)))
+		}
+	}
+	sort.Strings(report.Conflicts)
+	if policy == ConflictFail && len(report.Conflicts) > 0 {
+		return report, ErrConflict
+	}
+
+	for _, s := range submissions {
+		if existing[s.metadata.ID] {
+			if policy != ConflictOverwrite {
+				continue
+			}
+			if err = store.Remove(s.metadata.ID); err != nil {
+				return report, err
+			}
+			report.Overwritten++
+		}
+		if err = restoreSubmission(store, s); err != nil {
+			return report, fmt.Errorf("submission %s: %v", s.metadata.ID, err)
+		}
+		report.Imported++
+	}
+	for _, file := range problemFiles {
+		if changed[file] && policy != ConflictOverwrite {
+			continue
+		}
+		if err = copyFile(filepath.Join(dir, "problems", file), filepath.Join(problemsDir, file)); err != nil {
+			return report, err
+		}
+		report.ProblemFiles++
+	}
+	return report, nil
+}
+
+func restoreSubmission(store submission.Storage, s backedUpSubmission) error {
+	solution, err := os.Open(filepath.Join(s.dir, "solution"))
+	if err != nil {
+		return err
+	}
+	defer solution.Close()
+	if err = store.Upload(&s.metadata, solution); err != nil {
+		return err
+	}
+	artifacts, err := ioutil.ReadDir(filepath.Join(s.dir, "artifacts"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, artifact := range artifacts {
+		content, err := os.Open(filepath.Join(s.dir, "artifacts", artifact.Name()))
+		if err != nil {
+			return err
+		}
+		err = store.UploadArtifact(s.metadata, artifact.Name(), content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
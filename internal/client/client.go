@@ -0,0 +1,259 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+	// pollInterval how often Wait asks for the submission, events can be dropped by the server
+	pollInterval = 5 * time.Second
+)
+
+// Client of the JSON API of an inout_tester server
+type Client interface {
+	// Submit uploads the solution, which is queued for judging
+	Submit(s Submission) (SubmitResponse, error)
+	// Get the current state of the submission
+	Get(id submission.ID) (submission.Metadata, error)
+	// Wait follows events of the submission, and polls it, until it's judged and returns the judged submission.
+	// progress is called with every event.
+	Wait(id submission.ID, progress func(submission.Event)) (submission.Metadata, error)
+}
+
+// Submission a solution to submit
+type Submission struct {
+	ProblemName     string
+	Language        string
+	CompilationMode testcase.CompilationMode
+	Author          string
+	// EntryFile file with the entry point of an archive of many source files
+	EntryFile string
+	// Filename name of the solution file, archives are recognized by the extension
+	Filename string
+	Solution io.Reader
+}
+
+// SubmitResponse response of the server to a submitted solution
+type SubmitResponse struct {
+	ID        submission.ID     `json:"id"`
+	Status    submission.Status `json:"status"`
+	StatusURL string            `json:"statusUrl"`
+	PageURL   string            `json:"pageUrl"`
+}
+
+// APIError error response of the server
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server responded %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+type defaultClient struct {
+	server string
+	// requests for single responses time out, event streams don't
+	requests     *http.Client
+	streams      *http.Client
+	pollInterval time.Duration
+}
+
+// NewClient constructor of the Client of the server at serverURL, e.g. http://localhost:8080
+func NewClient(serverURL string) Client {
+	return &defaultClient{
+		server:       strings.TrimSuffix(serverURL, "/"),
+		requests:     &http.Client{Timeout: requestTimeout},
+		streams:      &http.Client{},
+		pollInterval: pollInterval,
+	}
+}
+
+// LanguageOf the ID of the language of the solution file, recognized by its extension.
+// The default language is preferred among languages with the same extension.
+func LanguageOf(filename string) (string, bool) {
+	ext := filepath.Ext(filename)
+	if lang, ok := testcase.LookupLanguage(testcase.DefaultLanguageID); ok && lang.Extension == ext {
+		return lang.ID, true
+	}
+	for _, lang := range testcase.Languages() {
+		if lang.Extension == ext {
+			return lang.ID, true
+		}
+	}
+	return "", false
+}
+
+// checkResponse returns the error sent by the server if the response isn't successful
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+		message = apiErr.Error
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
+
+// do sends the request and decodes the JSON response into v
+func (c *defaultClient) do(req *http.Request, v interface{}) error {
+	resp, err := c.requests.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = checkResponse(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *defaultClient) Submit(s Submission) (SubmitResponse, error) {
+	var res SubmitResponse
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"problemName":     s.ProblemName,
+		"language":        s.Language,
+		"compilationMode": string(s.CompilationMode),
+		"author":          s.Author,
+		"entryFile":       s.EntryFile,
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return res, err
+		}
+	}
+	file, err := form.CreateFormFile("solution", filepath.Base(s.Filename))
+	if err != nil {
+		return res, err
+	}
+	if _, err = io.Copy(file, s.Solution); err != nil {
+		return res, err
+	}
+	if err = form.Close(); err != nil {
+		return res, err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.server+"/api/v1/submissions", &body)
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	err = c.do(req, &res)
+	return res, err
+}
+
+func (c *defaultClient) Get(id submission.ID) (submission.Metadata, error) {
+	var res submission.Metadata
+	req, err := http.NewRequest(http.MethodGet, c.server+"/api/v1/submissions/"+id.String(), nil)
+	if err != nil {
+		return res, err
+	}
+	err = c.do(req, &res)
+	return res, err
+}
+
+func (c *defaultClient) Wait(id submission.ID, progress func(submission.Event)) (submission.Metadata, error) {
+	resp, err := c.streams.Get(c.server + "/api/events?id=" + url.QueryEscape(id.String()))
+	if err != nil {
+		return submission.Metadata{}, err
+	}
+	defer resp.Body.Close()
+	if err = checkResponse(resp); err != nil {
+		return submission.Metadata{}, err
+	}
+	// the stream is open, so events published from now on aren't missed,
+	// but the submission could have been judged before
+	metadata, err := c.Get(id)
+	if err != nil || metadata.Status.Final() {
+		return metadata, err
+	}
+
+	// events are read in the background, so that the submission is polled even if the final event never comes
+	events := make(chan submission.Event)
+	streamEnded := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		streamEnded <- readEvents(resp.Body, func(e submission.Event) bool {
+			select {
+			case events <- e:
+				return true
+			case <-done:
+				return false
+			}
+		})
+	}()
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-events:
+			progress(e)
+			if e.Type == submission.SubmissionRemoved || e.Status.Final() {
+				return c.Get(id)
+			}
+		case <-streamEnded:
+			// e.g. the server was restarted, polling goes on until it fails too
+			streamEnded = nil
+			events = nil
+		case <-ticker.C:
+			metadata, err = c.Get(id)
+			if err == nil && metadata.Status.Final() {
+				return metadata, nil
+			}
+			// the server answered with an error, e.g. the submission was removed, or neither source works anymore
+			if _, answered := err.(*APIError); answered || (err != nil && streamEnded == nil) {
+				return metadata, err
+			}
+		}
+	}
+}
+
+// readEvents decodes Server-Sent Events with submission events and passes them to handle until it
+// returns false. Returns an error if the stream ends before that.
+func readEvents(stream io.Reader, handle func(submission.Event) bool) error {
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "" && data.Len() > 0:
+			var e submission.Event
+			if err := json.Unmarshal([]byte(data.String()), &e); err != nil {
+				return fmt.Errorf("invalid event: %v", err)
+			}
+			data.Reset()
+			if !handle(e) {
+				return nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
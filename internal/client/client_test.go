@@ -0,0 +1,222 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+var cpp, _ = testcase.LookupLanguage(testcase.DefaultLanguageID)
+
+func writeEvent(w http.ResponseWriter, e submission.Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	w.(http.Flusher).Flush()
+}
+
+// fakeServer judges the submission while the client follows its events
+func fakeServer(t *testing.T, judged submission.Metadata) *httptest.Server {
+	var m sync.Mutex
+	current := judged
+	current.Status = submission.Compiling
+	// events are sent after the client reads the submission which isn't judged yet
+	read := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/submissions", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "multiply_by_2", r.Form.Get("problemName"))
+		assert.Equal(t, cpp.ID, r.Form.Get("language"))
+		assert.Equal(t, "ReleaseMode", r.Form.Get("compilationMode"))
+		file, header, err := r.FormFile("solution")
+		assert.NoError(t, err)
+		content, _ := ioutil.ReadAll(file)
+		assert.Equal(t, "int main() {}", string(content))
+		assert.Equal(t, "sol.cpp", header.Filename)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(SubmitResponse{ID: judged.ID, Status: submission.Queued, PageURL: "/submission/" + judged.ID.String()})
+	})
+	mux.HandleFunc("/api/v1/submissions/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, "/api/v1/submissions/") != judged.ID.String() {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": "submission not found"}`)
+			return
+		}
+		m.Lock()
+		defer m.Unlock()
+		json.NewEncoder(w).Encode(current)
+		select {
+		case read <- struct{}{}:
+		default:
+		}
+	})
+	mux.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, judged.ID.String(), r.URL.Query().Get("id"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		w.(http.Flusher).Flush()
+		<-read
+		running := judged
+		running.Status = submission.RunningTests
+		writeEvent(w, submission.NewStatusChangedEvent(running))
+		for _, tc := range judged.CompletedTestCases {
+			writeEvent(w, submission.NewTestCaseCompletedEvent(running, tc))
+		}
+		m.Lock()
+		current = judged
+		m.Unlock()
+		writeEvent(w, submission.NewStatusChangedEvent(judged))
+	})
+	return httptest.NewServer(mux)
+}
+
+func judgedSubmission() submission.Metadata {
+	m := submission.NewMetadata("multiply_by_2", cpp, testcase.ReleaseMode)
+	m.Status = submission.AllTestsCompleted
+	m.CompletedTestCases = []testcase.CompletedTestCase{
+		{Info: testcase.Info{Name: "t1"}, Result: testcase.Result{Status: testcase.Accepted}},
+		{Info: testcase.Info{Name: "t2"}, Result: testcase.Result{Status: testcase.WrongAnswer}},
+	}
+	m.TestCasesCount = 2
+	m.AcceptedCount = 1
+	return m
+}
+
+func TestClient_SubmitAndWait(t *testing.T) {
+	judged := judgedSubmission()
+	server := fakeServer(t, judged)
+	defer server.Close()
+	c := NewClient(server.URL + "/")
+
+	res, err := c.Submit(Submission{
+		ProblemName:     "multiply_by_2",
+		Language:        cpp.ID,
+		CompilationMode: testcase.ReleaseMode,
+		Filename:        "/home/alice/sol.cpp",
+		Solution:        strings.NewReader("int main() {}"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, judged.ID, res.ID)
+	assert.Equal(t, submission.Queued, res.Status)
+
+	var events []submission.EventType
+	m, err := c.Wait(res.ID, func(e submission.Event) {
+		events = append(events, e.Type)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []submission.EventType{submission.StatusChanged, submission.TestCaseCompleted,
+		submission.TestCaseCompleted, submission.StatusChanged}, events)
+	assert.Equal(t, submission.AllTestsCompleted, m.Status)
+	assert.Equal(t, judged.CompletedTestCases, m.CompletedTestCases)
+
+	// the submission is already judged
+	events = nil
+	m, err = c.Wait(res.ID, func(e submission.Event) {
+		events = append(events, e.Type)
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	assert.Equal(t, submission.AllTestsCompleted, m.Status)
+}
+
+// lossyServer judges the submission but its event stream misses the final event,
+// it either stays open or ends right after the first event
+func lossyServer(t *testing.T, judged submission.Metadata, keepOpen bool) *httptest.Server {
+	var m sync.Mutex
+	current := judged
+	current.Status = submission.Compiling
+	read := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/submissions/", func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		json.NewEncoder(w).Encode(current)
+		select {
+		case read <- struct{}{}:
+		default:
+		}
+	})
+	mux.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		w.(http.Flusher).Flush()
+		<-read
+		running := judged
+		running.Status = submission.RunningTests
+		writeEvent(w, submission.NewStatusChangedEvent(running))
+		m.Lock()
+		current = judged
+		m.Unlock()
+		if keepOpen {
+			<-r.Context().Done()
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestClient_WaitPollsWhenFinalEventIsLost(t *testing.T) {
+	for _, keepOpen := range []bool{true, false} {
+		judged := judgedSubmission()
+		server := lossyServer(t, judged, keepOpen)
+		c := NewClient(server.URL).(*defaultClient)
+		c.pollInterval = 10 * time.Millisecond
+
+		m, err := c.Wait(judged.ID, func(submission.Event) {})
+		assert.NoError(t, err, "keepOpen=%v", keepOpen)
+		assert.Equal(t, submission.AllTestsCompleted, m.Status, "keepOpen=%v", keepOpen)
+		server.Close()
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	server := fakeServer(t, judgedSubmission())
+	defer server.Close()
+	c := NewClient(server.URL)
+
+	_, err := c.Get(submission.NewMetadata("p", cpp, testcase.ReleaseMode).ID)
+	assert.Equal(t, &APIError{StatusCode: http.StatusNotFound, Message: "submission not found"}, err)
+	assert.EqualError(t, err, "server responded 404 Not Found: submission not found")
+
+	_, err = NewClient(server.URL + "/missing").Get(judgedSubmission().ID)
+	apiErr, ok := err.(*APIError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestReadEvents_StreamEndsEarly(t *testing.T) {
+	e := submission.NewStatusChangedEvent(judgedSubmission())
+	data, _ := json.Marshal(e)
+	stream := fmt.Sprintf(": keep-alive\n\nevent: status\ndata: %s\n\n", data)
+
+	var received []submission.Event
+	err := readEvents(strings.NewReader(stream), func(e submission.Event) bool {
+		received = append(received, e)
+		return true
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []submission.Event{e}, received)
+
+	err = readEvents(strings.NewReader("data: {\n\n"), func(submission.Event) bool { return true })
+	assert.Error(t, err)
+}
+
+func TestLanguageOf(t *testing.T) {
+	lang, ok := LanguageOf("dir/sol.cpp")
+	assert.True(t, ok)
+	assert.Equal(t, testcase.DefaultLanguageID, lang)
+	lang, ok = LanguageOf("sol.py")
+	assert.True(t, ok)
+	assert.Equal(t, "python3", lang)
+	_, ok = LanguageOf("sol.zip")
+	assert.False(t, ok)
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// ANSI escape codes of colors used when printing to a terminal
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+// Accepted returns true if the submission passed all the tests
+func Accepted(m submission.Metadata) bool {
+	return m.Status == submission.AllTestsCompleted && m.AcceptedCount == m.TestCasesCount
+}
+
+func paint(text, color string, enabled bool) string {
+	if !enabled {
+		return text
+	}
+	return color + text + colorReset
+}
+
+func testCaseColor(status testcase.Status) string {
+	if status == testcase.Accepted {
+		return colorGreen
+	}
+	return colorRed
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+// firstLine of the text, marked with "..." if there are more
+func firstLine(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return text[:i] + " ..."
+	}
+	return text
+}
+
+// PrintProgress writes a line about the event of a submission being judged
+func PrintProgress(w io.Writer, e submission.Event, color bool) {
+	switch e.Type {
+	case submission.TestCaseCompleted:
+		tc := e.TestCase
+		fmt.Fprintf(w, "  %-20s %s %8s  [%d/%d accepted]\n", tc.Info.Name,
+			paint(fmt.Sprintf("%-12s", tc.Result.Status), testCaseColor(tc.Result.Status), color),
+			formatDuration(tc.Result.Duration), e.AcceptedCount, e.TestCasesCount)
+	case submission.SubmissionRemoved:
+		fmt.Fprintln(w, "submission was removed from the server")
+	default:
+		// the final status is printed with results
+		if !e.Status.Final() {
+			fmt.Fprintln(w, e.Status)
+		}
+	}
+}
+
+// alignColumns pads cells of the rows to the width of their columns
+func alignColumns(rows [][]string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		var line strings.Builder
+		line.WriteString("  ")
+		for j, cell := range row {
+			if j < len(row)-1 {
+				fmt.Fprintf(&line, "%-*s  ", widths[j], cell)
+			} else {
+				line.WriteString(cell)
+			}
+		}
+		lines[i] = line.String()
+	}
+	return lines
+}
+
+// PrintResults writes the verdict of the submission with a table of its tests, or the compiler output
+func PrintResults(w io.Writer, m submission.Metadata, color bool) {
+	if len(m.CompletedTestCases) > 0 {
+		rows := [][]string{{"TEST", "STATUS", "TIME", "LIMIT", "DETAILS"}}
+		for _, tc := range m.CompletedTestCases {
+			details := firstLine(tc.Result.Description)
+			if tc.Result.Signal != "" {
+				details = strings.TrimSpace(tc.Result.Signal + " " + details)
+			}
+			rows = append(rows, []string{tc.Info.Name, tc.Result.Status.String(),
+				formatDuration(tc.Result.Duration), formatDuration(tc.Info.TimeLimit), details})
+		}
+		lines := alignColumns(rows)
+		fmt.Fprintln(w, lines[0])
+		for i, tc := range m.CompletedTestCases {
+			fmt.Fprintln(w, paint(lines[i+1], testCaseColor(tc.Result.Status), color))
+		}
+	}
+	if m.Status == submission.CompilationError || m.Status == submission.CompilationTimeout || m.Status == submission.InternalError {
+		if output := strings.TrimSpace(string(m.CompilationOutput)); output != "" {
+			fmt.Fprintln(w, output)
+		}
+	}
+	if m.ResultsFrom != nil {
+		fmt.Fprintf(w, "results reused from submission %s of the same solution\n", m.ResultsFrom)
+	}
+	verdict := fmt.Sprintf("%s: %d/%d tests accepted", m.Status, m.AcceptedCount, m.TestCasesCount)
+	switch {
+	case Accepted(m):
+		verdict = paint(verdict, colorGreen, color)
+	case m.Status.Final():
+		verdict = paint(verdict, colorRed, color)
+	default:
+		verdict = paint(verdict, colorYellow, color)
+	}
+	fmt.Fprintln(w, verdict)
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+func TestPrintResults(t *testing.T) {
+	m := judgedSubmission()
+	m.CompletedTestCases[0].Result.Duration = 12 * time.Millisecond
+	m.CompletedTestCases[1].Info.TimeLimit = time.Second
+	m.CompletedTestCases[1].Result.Description = "expected 4\ngot 5"
+
+	var out bytes.Buffer
+	PrintResults(&out, m, false)
+	assert.Equal(t, strings.Join([]string{
+		"  TEST  STATUS       TIME  LIMIT  DETAILS",
+		"  t1    Accepted     12ms  0s     ",
+		"  t2    WrongAnswer  0s    1s     expected 4 ...",
+		"AllTestsCompleted: 1/2 tests accepted",
+		""}, "\n"), out.String())
+	assert.False(t, Accepted(m))
+
+	out.Reset()
+	m.AcceptedCount = 2
+	PrintResults(&out, m, true)
+	assert.True(t, Accepted(m))
+	assert.Contains(t, out.String(), colorGreen+"AllTestsCompleted: 2/2 tests accepted"+colorReset)
+	assert.Contains(t, out.String(), colorRed+"  t2")
+}
+
+func TestPrintResults_CompilationError(t *testing.T) {
+	m := submission.NewMetadata("multiply_by_2", cpp, testcase.ReleaseMode)
+	m.Status = submission.CompilationError
+	m.CompilationOutput = []byte("sol.cpp:1: error: expected ';'\n")
+
+	var out bytes.Buffer
+	PrintResults(&out, m, false)
+	assert.Equal(t, "sol.cpp:1: error: expected ';'\nCompilationError: 0/0 tests accepted\n", out.String())
+}
+
+func TestPrintProgress(t *testing.T) {
+	running := judgedSubmission()
+	running.Status = submission.RunningTests
+
+	var out bytes.Buffer
+	PrintProgress(&out, submission.NewStatusChangedEvent(running), false)
+	PrintProgress(&out, submission.NewTestCaseCompletedEvent(running, running.CompletedTestCases[0]), false)
+	PrintProgress(&out, submission.NewStatusChangedEvent(judgedSubmission()), false)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "RunningTests", lines[0])
+	assert.Contains(t, lines[1], "t1")
+	assert.Contains(t, lines[1], "[1/2 accepted]")
+}
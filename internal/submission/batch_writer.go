@@ -0,0 +1,110 @@
+package submission
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxPendingUpdates = 100
+	defaultFlushInterval     = time.Second
+)
+
+// batchWriter coalesces frequent updates of submissions in progress into periodic saves.
+// The in-memory view of the Storage is updated immediately, metadata files are rewritten
+// by a background goroutine at most every maxPending updates or every interval, whichever comes first.
+type batchWriter struct {
+	store   Storage
+	pending map[ID]pendingUpdate
+	// pendingCount number of updates of all pending submissions
+	pendingCount int
+	// m serializes in-memory updates with saves, so an older state is never saved over a newer one
+	m sync.Mutex
+
+	flushNow   chan struct{}
+	quit       chan struct{}
+	done       chan struct{}
+	maxPending int
+	interval   time.Duration
+}
+
+// pendingUpdate the latest state of a submission to save and how many updates it coalesces
+type pendingUpdate struct {
+	meta    Metadata
+	updates int
+}
+
+func newBatchWriter(store Storage, maxPending int, interval time.Duration) *batchWriter {
+	w := &batchWriter{
+		store:      store,
+		pending:    make(map[ID]pendingUpdate),
+		flushNow:   make(chan struct{}, 1),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+		maxPending: maxPending,
+		interval:   interval,
+	}
+	go w.run()
+	return w
+}
+
+// Update makes the new state visible to readers of the Storage right away and schedules it to be saved
+func (w *batchWriter) Update(meta Metadata) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	w.store.Update(meta)
+	w.pending[meta.ID] = pendingUpdate{meta: meta, updates: w.pending[meta.ID].updates + 1}
+	w.pendingCount++
+	if w.pendingCount >= w.maxPending {
+		select {
+		case w.flushNow <- struct{}{}:
+		default:
+			// flush already requested
+		}
+	}
+}
+
+// Flush saves the submission immediately, dropping its pending updates
+func (w *batchWriter) Flush(meta Metadata) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	w.pendingCount -= w.pending[meta.ID].updates
+	delete(w.pending, meta.ID)
+	return w.store.Save(meta)
+}
+
+// Close saves all pending updates and stops the writer
+func (w *batchWriter) Close() {
+	close(w.quit)
+	<-w.done
+}
+
+func (w *batchWriter) saveAll() {
+	w.m.Lock()
+	defer w.m.Unlock()
+	for id, update := range w.pending {
+		if err := w.store.Save(update.meta); err != nil {
+			log.Println("unable to save submission", id, err)
+		}
+		delete(w.pending, id)
+	}
+	w.pendingCount = 0
+}
+
+func (w *batchWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.quit:
+			w.saveAll()
+			return
+		case <-w.flushNow:
+			w.saveAll()
+		case <-ticker.C:
+			w.saveAll()
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package submission
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// countingStorage counts how many times metadata were persisted
+type countingStorage struct {
+	Storage
+	saves int
+	m     sync.Mutex
+}
+
+func (s *countingStorage) Save(meta Metadata) error {
+	err := s.Storage.Save(meta)
+	s.m.Lock()
+	s.saves++
+	s.m.Unlock()
+	return err
+}
+
+func (s *countingStorage) savesCount() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.saves
+}
+
+func newCountingStorage(t *testing.T) (*countingStorage, string) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "testbatchwriter-*")
+	assert.NoError(t, err)
+	store := &countingStorage{Storage: NewDefaultStorage(dirname)}
+	assert.NoError(t, store.Init())
+	return store, dirname
+}
+
+func TestBatchWriter_CoalescesUpdatesByCount(t *testing.T) {
+	store, dirname := newCountingStorage(t)
+	defer os.RemoveAll(dirname)
+	w := newBatchWriter(store, 100, time.Hour)
+
+	meta := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	for i := 1; i <= 1000; i++ {
+		meta.AcceptedCount = i
+		w.Update(meta)
+
+		current, ok := store.Get(meta.ID)
+		assert.True(t, ok)
+		assert.Equal(t, i, current.AcceptedCount)
+	}
+	meta.Status = AllTestsCompleted
+	assert.NoError(t, w.Flush(meta))
+	w.Close()
+
+	assert.True(t, store.savesCount() <= 11, "saved %d times", store.savesCount())
+
+	reloaded := NewDefaultStorage(dirname)
+	assert.NoError(t, reloaded.LoadAll())
+	persisted, ok := reloaded.Get(meta.ID)
+	assert.True(t, ok)
+	assert.Equal(t, 1000, persisted.AcceptedCount)
+	assert.Equal(t, AllTestsCompleted, persisted.Status)
+}
+
+func TestBatchWriter_FlushesPeriodically(t *testing.T) {
+	store, dirname := newCountingStorage(t)
+	defer os.RemoveAll(dirname)
+	w := newBatchWriter(store, 100, 20*time.Millisecond)
+	defer w.Close()
+
+	meta := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	meta.AcceptedCount = 7
+	w.Update(meta)
+	w.Update(meta)
+
+	for i := 0; i < 50 && store.savesCount() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1, store.savesCount())
+
+	reloaded := NewDefaultStorage(dirname)
+	assert.NoError(t, reloaded.LoadAll())
+	persisted, ok := reloaded.Get(meta.ID)
+	assert.True(t, ok)
+	assert.Equal(t, 7, persisted.AcceptedCount)
+}
+
+func TestBatchWriter_CloseSavesPendingUpdates(t *testing.T) {
+	store, dirname := newCountingStorage(t)
+	defer os.RemoveAll(dirname)
+	w := newBatchWriter(store, 100, time.Hour)
+
+	meta := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	meta.Status = RunningTests
+	w.Update(meta)
+	w.Close()
+
+	assert.Equal(t, 1, store.savesCount())
+}
+
+func TestBatchWriter_FlushForgetsItsPendingUpdates(t *testing.T) {
+	store, dirname := newCountingStorage(t)
+	defer os.RemoveAll(dirname)
+	w := newBatchWriter(store, 10, time.Hour)
+	defer w.Close()
+
+	flushed := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	for i := 0; i < 9; i++ {
+		w.Update(flushed)
+	}
+	flushed.Status = AllTestsCompleted
+	assert.NoError(t, w.Flush(flushed))
+
+	// updates of the flushed submission don't count towards the next save
+	running := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	for i := 0; i < 9; i++ {
+		w.Update(running)
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, store.savesCount())
+}
@@ -0,0 +1,95 @@
+package submission
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// BlobDirectory directory in the data directory where solutions are stored by hash of their content
+const BlobDirectory = ".blobs"
+
+// HashContent hash under which the content is stored in the blob store, hex encoded SHA-256
+func HashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// blobStore content-addressed files in dir/<first two characters of the hash>/<hash>,
+// identical contents are stored once. Users of the store count references to blobs.
+type blobStore struct {
+	dir string
+}
+
+func newBlobStore(dataDir string) blobStore {
+	return blobStore{dir: path.Join(dataDir, BlobDirectory)}
+}
+
+func (b blobStore) path(hash string) (string, error) {
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha256.Size {
+		return "", fmt.Errorf("invalid blob hash '%s'", hash)
+	}
+	return path.Join(b.dir, hash[:2], hash), nil
+}
+
+// Put stores the content and returns its hash, content which is already stored is not written again
+func (b blobStore) Put(content io.Reader) (string, error) {
+	if err := ensureDirectoryExists(b.dir); err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile(b.dir, "blob"+tempFileMarker+"*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(f, hash), content); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(hash.Sum(nil))
+	target, _ := b.path(key)
+	if _, err = os.Stat(target); err == nil {
+		return key, nil
+	}
+	if err = ensureDirectoryExists(path.Dir(target)); err != nil {
+		return "", err
+	}
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		return "", err
+	}
+	if err = os.Rename(f.Name(), target); err != nil {
+		return "", err
+	}
+	return key, syncDirectory(path.Dir(target))
+}
+
+// Open content of the blob
+func (b blobStore) Open(hash string) (io.ReadCloser, error) {
+	blobPath, err := b.path(hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(blobPath)
+}
+
+// Remove deletes the blob, removing a missing blob is not an error
+func (b blobStore) Remove(hash string) error {
+	blobPath, err := b.path(hash)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(blobPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
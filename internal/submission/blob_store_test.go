@@ -0,0 +1,115 @@
+package submission
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readAll content of a downloaded file, or the error
+func readAll(content io.ReadCloser, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	defer content.Close()
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func dataDirectoryOf(store Storage) string {
+	switch s := store.(type) {
+	case *defaultStorage:
+		return s.dataDirectory
+	case *sqliteStorage:
+		return s.dataDirectory
+	}
+	return ""
+}
+
+func TestBlobStore_PutDeduplicates(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "testblobs-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dirname)
+	blobs := newBlobStore(dirname)
+
+	first, err := blobs.Put(strings.NewReader("int main() {}"))
+	assert.NoError(t, err)
+	second, err := blobs.Put(strings.NewReader("int main() {}"))
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, HashContent([]byte("int main() {}")), first)
+
+	files, err := ioutil.ReadDir(path.Join(dirname, BlobDirectory, first[:2]))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "int main() {}", readAll(blobs.Open(first)))
+
+	assert.NoError(t, blobs.Remove(first))
+	assert.NoError(t, blobs.Remove(first))
+	_, err = blobs.Open(first)
+	assert.True(t, os.IsNotExist(err))
+	_, err = blobs.Open("../../etc/passwd")
+	assert.Error(t, err)
+}
+
+func TestStorage_SolutionsAreDeduplicated(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		submissions := testSubmissions(3)
+		for i, source := range []string{"same", "same", "other"} {
+			assert.NoError(t, store.Upload(&submissions[i], strings.NewReader(source)), name)
+		}
+		hash := submissions[0].SolutionHash
+		blob := path.Join(dataDirectoryOf(store), BlobDirectory, hash[:2], hash)
+		page, err := store.Query(Query{SolutionHash: hash, Oldest: true})
+		assert.NoError(t, err, name)
+		assert.Equal(t, []ID{submissions[0].ID, submissions[1].ID}, ids(page.Submissions), name)
+
+		assert.NoError(t, store.Remove(submissions[0].ID), name)
+		assert.FileExists(t, blob, name)
+		assert.Equal(t, "same", readAll(store.Download(submissions[1])), name)
+		assert.NoError(t, store.Remove(submissions[1].ID), name)
+		assert.NoFileExists(t, blob, name)
+		assert.Equal(t, "other", readAll(store.Download(submissions[2])), name)
+		store.Destroy()
+	}
+}
+
+func TestDefaultStorage_LoadAllCountsReferences(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "testblobs-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dirname)
+	store := NewDefaultStorage(dirname)
+	assert.NoError(t, store.Init())
+	submissions := testSubmissions(2)
+	for i := range submissions {
+		assert.NoError(t, store.Upload(&submissions[i], strings.NewReader("same")))
+	}
+
+	reloaded := NewDefaultStorage(dirname)
+	assert.NoError(t, reloaded.LoadAll())
+	assert.NoError(t, reloaded.Remove(submissions[0].ID))
+	assert.Equal(t, "same", readAll(reloaded.Download(submissions[1])))
+}
+
+func TestStorage_LegacySolutions(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		m := testSubmissions(1)[0]
+		solution := path.Join(dataDirectoryOf(store), m.ProblemName, m.SolutionFilename)
+		assert.NoError(t, os.MkdirAll(path.Dir(solution), 0755), name)
+		assert.NoError(t, ioutil.WriteFile(solution, []byte("legacy"), 0644), name)
+		assert.NoError(t, store.Save(m), name)
+
+		assert.Equal(t, "legacy", readAll(store.Download(m)), name)
+		assert.NoError(t, store.Remove(m.ID), name)
+		assert.NoFileExists(t, solution, name)
+		store.Destroy()
+	}
+}
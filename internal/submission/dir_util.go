@@ -1,6 +1,15 @@
 package submission
 
-import "os"
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// tempFileMarker part of names of temporary files written by writeFileAtomic
+const tempFileMarker = ".tmp-"
 
 func ensureDirectoryExists(dir string) error {
 	_, err := os.Stat(dir)
@@ -10,3 +19,56 @@ func ensureDirectoryExists(dir string) error {
 	}
 	return nil
 }
+
+// writeFileAtomic writes the file with write into a temporary file in the same directory, syncs it and
+// renames it over filename, so after a crash the file has either the old or the new content
+func writeFileAtomic(filename string, write func(w io.Writer) error) error {
+	dir, base := path.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, base+tempFileMarker+"*")
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), filename); err != nil {
+		return err
+	}
+	committed = true
+	return syncDirectory(dir)
+}
+
+// syncDirectory makes the rename in the directory durable, it's not supported on every platform
+// so errors of Sync are ignored
+func syncDirectory(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	d.Sync()
+	return d.Close()
+}
+
+// isTempFile returns true for temporary files left by writeFileAtomic interrupted by a crash
+func isTempFile(name string) bool {
+	return strings.Contains(name, tempFileMarker)
+}
@@ -0,0 +1,100 @@
+package submission
+
+import (
+	"log"
+
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// EventType type of the submission event
+type EventType string
+
+const (
+	// StatusChanged submission moved to a new Status
+	StatusChanged EventType = "status"
+	// TestCaseCompleted a single test case of the submission has been judged
+	TestCaseCompleted EventType = "testcase"
+	// SubmissionRemoved the submission has been removed from the Storage, e.g. by the retention policy
+	SubmissionRemoved EventType = "removed"
+)
+
+const eventBufferSize = 256
+
+// Event describes a change of a submission
+type Event struct {
+	Type           EventType                   `json:"type"`
+	SubmissionID   ID                          `json:"submissionId"`
+	ProblemName    string                      `json:"problemName"`
+	Status         Status                      `json:"status"`
+	AcceptedCount  int                         `json:"acceptedCount"`
+	TestCasesCount int                         `json:"testCasesCount"`
+	TestCase       *testcase.CompletedTestCase `json:"testCase,omitempty"`
+}
+
+// NewStatusChangedEvent constructor of an event about the current status of the submission
+func NewStatusChangedEvent(meta Metadata) Event {
+	return Event{
+		Type:           StatusChanged,
+		SubmissionID:   meta.ID,
+		ProblemName:    meta.ProblemName,
+		Status:         meta.Status,
+		AcceptedCount:  meta.AcceptedCount,
+		TestCasesCount: meta.TestCasesCount,
+	}
+}
+
+// NewTestCaseCompletedEvent constructor of an event about a test case completed for the submission
+func NewTestCaseCompletedEvent(meta Metadata, tc testcase.CompletedTestCase) Event {
+	e := NewStatusChangedEvent(meta)
+	e.Type = TestCaseCompleted
+	e.TestCase = &tc
+	return e
+}
+
+// PublishRemovals publishes a SubmissionRemoved event for every removal among changes of the Storage,
+// until the channel is closed
+func PublishRemovals(changes <-chan Change, events EventBus) {
+	for change := range changes {
+		if change.Type == ChangeRemoved {
+			e := NewStatusChangedEvent(change.Submission)
+			e.Type = SubmissionRemoved
+			events.Publish(e)
+		}
+	}
+}
+
+// EventBus delivers submission events to all subscribers
+type EventBus interface {
+	Publish(e Event)
+	// Subscribe returns a channel with all events published from now on.
+	// unsubscribe must be called when the subscriber is no longer interested.
+	Subscribe() (events <-chan Event, unsubscribe func())
+}
+
+type defaultEventBus struct {
+	subscribers fanOut
+}
+
+// NewEventBus constructor of the default EventBus
+func NewEventBus() EventBus {
+	return &defaultEventBus{}
+}
+
+// Publish never blocks, events are dropped for subscribers which don't keep up
+func (bus *defaultEventBus) Publish(e Event) {
+	if dropped := bus.subscribers.publish(e); dropped > 0 {
+		log.Println("event bus:", dropped, "subscribers are too slow, dropping event", e.Type, "for", e.SubmissionID)
+	}
+}
+
+func (bus *defaultEventBus) Subscribe() (<-chan Event, func()) {
+	sub := make(chan Event, eventBufferSize)
+	return sub, bus.subscribers.subscribe(func(v interface{}) bool {
+		select {
+		case sub <- v.(Event):
+			return true
+		default:
+			return false
+		}
+	}, func() { close(sub) })
+}
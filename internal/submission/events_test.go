@@ -0,0 +1,70 @@
+package submission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+func TestEventBus_PublishSubscribe(t *testing.T) {
+	bus := NewEventBus()
+	sub1, unsubscribe1 := bus.Subscribe()
+	sub2, unsubscribe2 := bus.Subscribe()
+	defer unsubscribe2()
+
+	meta := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	bus.Publish(NewStatusChangedEvent(meta))
+
+	e := <-sub1
+	assert.Equal(t, StatusChanged, e.Type)
+	assert.Equal(t, meta.ID, e.SubmissionID)
+	assert.Equal(t, Queued, e.Status)
+	assert.Nil(t, e.TestCase)
+	e = <-sub2
+	assert.Equal(t, meta.ID, e.SubmissionID)
+
+	unsubscribe1()
+	unsubscribe1()
+	_, open := <-sub1
+	assert.False(t, open)
+
+	tc := testcase.CompletedTestCase{Info: testcase.Info{Name: "t1"}, Result: testcase.Result{Status: testcase.Accepted}}
+	bus.Publish(NewTestCaseCompletedEvent(meta, tc))
+	e = <-sub2
+	assert.Equal(t, TestCaseCompleted, e.Type)
+	assert.Equal(t, "t1", e.TestCase.Info.Name)
+}
+
+func TestEventBus_SlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := NewEventBus()
+	sub, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	meta := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	for i := 0; i < 2*eventBufferSize; i++ {
+		bus.Publish(NewStatusChangedEvent(meta))
+	}
+	assert.Equal(t, eventBufferSize, len(sub))
+}
+
+func TestPublishRemovals(t *testing.T) {
+	store, _ := newTestSQLiteStorage(t)
+	defer store.Destroy()
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+	changes, stop := store.Watch()
+	go PublishRemovals(changes, bus)
+
+	m := testSubmissions(1)[0]
+	assert.NoError(t, store.Save(m))
+	store.Update(m)
+	assert.NoError(t, store.Remove(m.ID))
+	e := <-events
+	assert.Equal(t, SubmissionRemoved, e.Type)
+	assert.Equal(t, m.ID, e.SubmissionID)
+	assert.Equal(t, AllTestsCompleted, e.Status)
+	stop()
+	assert.Len(t, events, 0)
+}
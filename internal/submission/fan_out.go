@@ -0,0 +1,46 @@
+package submission
+
+import "sync"
+
+// fanOut delivers values to all subscribers without ever blocking, values are dropped for subscribers
+// which don't keep up. Used by the EventBus and by watchers of the Storage.
+type fanOut struct {
+	// subscribers try to send a value without blocking, by unique IDs
+	subscribers map[int]func(v interface{}) bool
+	nextID      int
+	m           sync.Mutex
+}
+
+// subscribe adds the subscriber, closeFn is called once when unsubscribe is called for the first time
+func (f *fanOut) subscribe(trySend func(v interface{}) bool, closeFn func()) (unsubscribe func()) {
+	f.m.Lock()
+	if f.subscribers == nil {
+		f.subscribers = make(map[int]func(v interface{}) bool)
+	}
+	id := f.nextID
+	f.nextID++
+	f.subscribers[id] = trySend
+	f.m.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			f.m.Lock()
+			defer f.m.Unlock()
+			delete(f.subscribers, id)
+			closeFn()
+		})
+	}
+}
+
+// publish returns how many subscribers the value was dropped for
+func (f *fanOut) publish(v interface{}) (dropped int) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	for _, trySend := range f.subscribers {
+		if !trySend(v) {
+			dropped++
+		}
+	}
+	return dropped
+}
@@ -0,0 +1,55 @@
+package submission
+
+import (
+	"time"
+
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// Info a submission as published by the JSON API and webhooks, without internal details of the storage and processing
+type Info struct {
+	ID                  ID                           `json:"id"`
+	SubmittedAt         time.Time                    `json:"submittedAt"`
+	ProblemName         string                       `json:"problemName"`
+	Language            string                       `json:"language"`
+	SourceArchive       testcase.ArchiveFormat       `json:"sourceArchive,omitempty"`
+	EntryFile           string                       `json:"entryFile,omitempty"`
+	Author              string                       `json:"author,omitempty"`
+	Status              Status                       `json:"status"`
+	CompilationMode     testcase.CompilationMode     `json:"compilationMode"`
+	CompilerVersion     string                       `json:"compilerVersion,omitempty"`
+	CompilationOutput   []byte                       `json:"compilationOutput"`
+	Diagnostics         []testcase.Diagnostic        `json:"diagnostics,omitempty"`
+	StaticAnalysis      []testcase.Finding           `json:"staticAnalysis,omitempty"`
+	CompletedTestCases  []testcase.CompletedTestCase `json:"testCases"`
+	TestCasesCount      int                          `json:"testCasesCount"`
+	AcceptedCount       int                          `json:"acceptedCount"`
+	TotalProcessingTime time.Duration                `json:"totalProcessingTime"`
+	Coverage            *testcase.CoverageSummary    `json:"coverage,omitempty"`
+	ResultsFrom         *ID                          `json:"resultsFrom,omitempty"`
+}
+
+// NewInfo public view of the submission
+func NewInfo(m Metadata) Info {
+	return Info{
+		ID:                  m.ID,
+		SubmittedAt:         m.SubmittedAt,
+		ProblemName:         m.ProblemName,
+		Language:            m.Language,
+		SourceArchive:       m.SourceArchive,
+		EntryFile:           m.EntryFile,
+		Author:              m.Author,
+		Status:              m.Status,
+		CompilationMode:     m.CompilationMode,
+		CompilerVersion:     m.CompilerVersion,
+		CompilationOutput:   m.CompilationOutput,
+		Diagnostics:         m.Diagnostics,
+		StaticAnalysis:      m.StaticAnalysis,
+		CompletedTestCases:  m.CompletedTestCases,
+		TestCasesCount:      m.TestCasesCount,
+		AcceptedCount:       m.AcceptedCount,
+		TotalProcessingTime: m.TotalProcessingTime,
+		Coverage:            m.Coverage,
+		ResultsFrom:         m.ResultsFrom,
+	}
+}
@@ -17,6 +17,9 @@ type ID guuid.UUID
 
 const metaFileExtension = ".meta"
 
+// CoverageArtifact name of the artifact with testcase.CoverageReport of submissions compiled in a coverage mode
+const CoverageArtifact = "coverage.json"
+
 // State submission status
 type Status int
 
@@ -31,39 +34,116 @@ const (
 	RunningTests
 	// AllTestsCompleted all done
 	AllTestsCompleted
+	// CompilationTimeout the compiler didn't finish within the time limit
+	CompilationTimeout
+	// InternalError the server was unable to judge the solution, e.g. its tests couldn't be read
+	InternalError
 )
 
 // Metadata metadata of the submission
 type Metadata struct {
+	SchemaVersion       int                          `json:"schemaVersion"`
 	ID                  ID                           `json:"id"`
 	SubmittedAt         time.Time                    `json:"submittedAt"`
 	ProblemName         string                       `json:"problemName"`
 	SolutionFilename    string                       `json:"solutionFilename"`
+	SolutionHash        string                       `json:"solutionHash,omitempty"` // empty for solutions stored before the blob store
+	Language            string                       `json:"language"`
+	SourceArchive       testcase.ArchiveFormat       `json:"sourceArchive,omitempty"`
+	EntryFile           string                       `json:"entryFile,omitempty"`
+	Author              string                       `json:"author,omitempty"`
 	Status              Status                       `json:"status"`
 	ExecutableFilename  string                       `json:"executableFilename"`
 	CompilationOutput   []byte                       `json:"compilationOutput"`
+	Diagnostics         []testcase.Diagnostic        `json:"diagnostics,omitempty"`
+	StaticAnalysis      []testcase.Finding           `json:"staticAnalysis,omitempty"`
 	CompilationMode     testcase.CompilationMode     `json:"compilationMode"`
+	CompilerVersion     string                       `json:"compilerVersion,omitempty"`
+	CompilationCached   bool                         `json:"compilationCached,omitempty"`
 	CompletedTestCases  []testcase.CompletedTestCase `json:"testCases"`
 	TestCasesCount      int                          `json:"testCasesCount"`
 	AcceptedCount       int                          `json:"acceptedCount"`
 	TotalProcessingTime time.Duration                `json:"totalProcessingTime"`
+	Coverage            *testcase.CoverageSummary    `json:"coverage,omitempty"`
+	ResultsFrom         *ID                          `json:"resultsFrom,omitempty"` // judged submission of the same solution
+	JudgeKey            string                       `json:"judgeKey,omitempty"`    // results are reused only for the same key, see judgeKey
 	WorkerCount         int                          `json:"workerCount"`
 }
 
-func NewMetadata(problem string, mode testcase.CompilationMode) Metadata {
+func NewMetadata(problem string, lang testcase.Language, mode testcase.CompilationMode) Metadata {
 	id := ID(guuid.New())
+	workerCount := runtime.NumCPU() / 2
+	if workerCount < 1 {
+		workerCount = 1
+	}
 	return Metadata{
+		SchemaVersion:       CurrentSchemaVersion,
 		ID:                  id,
 		SubmittedAt:         time.Now(),
-		SolutionFilename:    id.String() + ".cpp",
+		SolutionFilename:    id.String() + lang.Extension,
+		Language:            lang.ID,
 		Status:              Queued,
 		ProblemName:         problem,
 		ExecutableFilename:  id.String() + ".tsk",
 		CompilationMode:     mode,
 		TotalProcessingTime: time.Duration(0),
 		TestCasesCount:      0,
-		WorkerCount:         runtime.NumCPU() / 2,
+		WorkerCount:         workerCount,
+	}
+}
+
+// SetSourceArchive marks the solution as an archive of many source files
+func (m *Metadata) SetSourceArchive(format testcase.ArchiveFormat, entryFile string) {
+	m.SourceArchive = format
+	m.EntryFile = entryFile
+	m.SolutionFilename = m.ID.String() + "." + string(format)
+}
+
+// Solution sources of the submission with given content of the solution file
+func (m Metadata) Solution(content []byte) testcase.Solution {
+	return testcase.Solution{Source: content, Archive: m.SourceArchive, Entry: m.EntryFile}
+}
+
+// clone deep copy of the metadata, which can be read while the Storage changes the original
+func (m Metadata) clone() Metadata {
+	c := m
+	if m.CompilationOutput != nil {
+		c.CompilationOutput = append([]byte{}, m.CompilationOutput...)
+	}
+	c.Diagnostics = cloneDiagnostics(m.Diagnostics)
+	if m.StaticAnalysis != nil {
+		c.StaticAnalysis = append([]testcase.Finding{}, m.StaticAnalysis...)
+	}
+	if m.CompletedTestCases != nil {
+		c.CompletedTestCases = make([]testcase.CompletedTestCase, len(m.CompletedTestCases))
+		for i, tc := range m.CompletedTestCases {
+			if tc.Result.Backtrace != nil {
+				tc.Result.Backtrace = append([]testcase.StackFrame{}, tc.Result.Backtrace...)
+			}
+			c.CompletedTestCases[i] = tc
+		}
+	}
+	if m.Coverage != nil {
+		coverage := *m.Coverage
+		c.Coverage = &coverage
+	}
+	if m.ResultsFrom != nil {
+		from := *m.ResultsFrom
+		c.ResultsFrom = &from
+	}
+	return c
+}
+
+func cloneDiagnostics(diagnostics []testcase.Diagnostic) []testcase.Diagnostic {
+	if diagnostics == nil {
+		return nil
 	}
+	res := make([]testcase.Diagnostic, len(diagnostics))
+	for i, d := range diagnostics {
+		d.Notes = cloneDiagnostics(d.Notes)
+		res[i] = d
+	}
+	return res
 }
 
 // TODO: Add tests for marshal / unmarshall
@@ -92,19 +172,38 @@ func (id *ID) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Statuses all statuses of submissions, in the order of processing
+func Statuses() []Status {
+	return []Status{Queued, Compiling, CompilationError, CompilationTimeout, RunningTests, AllTestsCompleted, InternalError}
+}
+
+// ParseStatus returns the status with the name, e.g. "AllTestsCompleted"
+func ParseStatus(name string) (Status, error) {
+	for i := 1; i < len(_Status_index); i++ {
+		if Status(i).String() == name {
+			return Status(i), nil
+		}
+	}
+	return 0, errors.New("invalid testacase status value")
+}
+
+// Final returns true if processing of the submission has finished with this status
+func (e Status) Final() bool {
+	return e == AllTestsCompleted || e == CompilationError || e == CompilationTimeout || e == InternalError
+}
+
 func (e *Status) UnmarshalJSON(data []byte) error {
 	var s string
 	err := json.Unmarshal(data, &s)
 	if err != nil {
 		return err
 	}
-	for i := 0; i <= len(_Status_index); i++ {
-		if Status(i).String() == s {
-			*e = Status(i)
-			return nil
-		}
+	status, err := ParseStatus(s)
+	if err != nil {
+		return err
 	}
-	return errors.New("invalid testacase status value")
+	*e = status
+	return nil
 }
 
 func (e Status) MarshalJSON() ([]byte, error) {
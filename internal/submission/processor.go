@@ -1,11 +1,17 @@
 package submission
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
-	"path"
 	"sort"
+	"sync"
 	"time"
 
 	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
@@ -14,65 +20,179 @@ import (
 // Processor processes submissions
 type Processor interface {
 	Submit(meta Metadata)
+	// Rejudge judges the submission again, even if results of the same solution could be reused
+	Rejudge(meta Metadata)
+	// Process processes submitted submissions until Quit, then saves pending updates and returns
 	Process() error
+	// Quit stops Process after the submission in progress, submissions still in the queue stay Queued
 	Quit()
+	// Cancel aborts tests of the submission in progress, returns false if it isn't being processed
+	Cancel(id ID) bool
+}
+
+// queuedSubmission submission waiting for processing
+type queuedSubmission struct {
+	meta Metadata
+	// rejudge don't reuse results of the same solution
+	rejudge bool
 }
 
 type defaultProcessor struct {
-	queue           chan Metadata
+	queue           chan queuedSubmission
+	quit            chan struct{}
 	store           Storage
 	testcaseArchive testcase.Archive
+	events          EventBus
+	cache           testcase.CompilationCache
+	writer          *batchWriter
 	workersCount    int
+
+	// cancelInProgress cancels the context of the submission being processed, nil between submissions
+	cancelInProgress context.CancelFunc
+	inProgress       ID
+	m                sync.Mutex
 }
 
-// NewProcessor constructor of the Processor
-func NewProcessor(store Storage, testcaseArchive testcase.Archive) Processor {
+// NewProcessor constructor of the Processor, progress of every submission is published to events.
+// Compiled solutions are reused from the cache, nil disables caching.
+func NewProcessor(store Storage, testcaseArchive testcase.Archive, events EventBus, cache testcase.CompilationCache) Processor {
 	return &defaultProcessor{
-		queue:           make(chan Metadata, 1000),
+		queue:           make(chan queuedSubmission, 1000),
+		quit:            make(chan struct{}),
 		store:           store,
 		testcaseArchive: testcaseArchive,
+		events:          events,
+		cache:           cache,
+		writer:          newBatchWriter(store, defaultMaxPendingUpdates, defaultFlushInterval),
 	}
 }
 
 func (p *defaultProcessor) Submit(meta Metadata) {
-	p.queue <- meta
+	p.events.Publish(NewStatusChangedEvent(meta))
+	p.queue <- queuedSubmission{meta: meta}
+}
+
+func (p *defaultProcessor) Rejudge(meta Metadata) {
+	meta.Status = Queued
+	meta.CompilationOutput = nil
+	meta.Diagnostics = nil
+	meta.StaticAnalysis = nil
+	meta.CompilationCached = false
+	meta.CompletedTestCases = nil
+	meta.TestCasesCount = 0
+	meta.AcceptedCount = 0
+	meta.TotalProcessingTime = 0
+	meta.Coverage = nil
+	meta.ResultsFrom = nil
+	p.events.Publish(NewStatusChangedEvent(meta))
+	p.queue <- queuedSubmission{meta: meta, rejudge: true}
+}
+
+// saveWithStatus saves the submission with new status and notifies subscribers about it
+func (p *defaultProcessor) saveWithStatus(submission *Metadata, status Status) error {
+	submission.Status = status
+	err := p.writer.Flush(*submission)
+	p.events.Publish(NewStatusChangedEvent(*submission))
+	return err
+}
+
+// fail ends processing of the submission with InternalError, so it doesn't stay Compiling forever
+func (p *defaultProcessor) fail(submission Metadata, err error) (Metadata, error) {
+	submission.CompilationOutput = []byte("unable to judge the submission: " + err.Error())
+	p.saveWithStatus(&submission, InternalError)
+	return submission, err
 }
 
-func testcaseProcessor(runner testcase.Runner, executable string, jobs <-chan testcase.Info, results chan<- testcase.CompletedTestCase) {
+func testcaseProcessor(ctx context.Context, runner testcase.Runner, command []string, jobs <-chan testcase.Info, results chan<- testcase.CompletedTestCase) {
 	for tc := range jobs {
-		results <- testcase.CompletedTestCase{Info: tc, Result: runner.Run(executable, tc)}
+		results <- testcase.CompletedTestCase{Info: tc, Result: runner.Run(ctx, command, tc)}
 	}
 	log.Println("worker exited")
 }
 
-func (p *defaultProcessor) processSubmission(submission Metadata) (res Metadata, err error) {
+// processSubmission judges the submission, its tests are aborted when ctx is cancelled.
+// Results of the same solution judged earlier are reused unless rejudge is set.
+func (p *defaultProcessor) processSubmission(ctx context.Context, submission Metadata, rejudge bool) (res Metadata, err error) {
 	fmt.Println("Processing submission:", submission)
 	start := time.Now()
-	submission.Status = Compiling
-	p.store.Save(submission)
+	p.saveWithStatus(&submission, Compiling)
 
+	lang, ok := testcase.LookupLanguage(submission.Language)
+	if !ok {
+		submission.CompilationOutput = []byte("unknown language: " + submission.Language)
+		p.saveWithStatus(&submission, CompilationError)
+		return submission, fmt.Errorf("unknown language '%s'", submission.Language)
+	}
 	solution, err := p.store.Download(submission)
-	defer solution.Close()
+	if err != nil {
+		return p.fail(submission, err)
+	}
+	source, err := ioutil.ReadAll(solution)
+	solution.Close()
+	if err != nil {
+		return p.fail(submission, err)
+	}
 
-	executable := path.Join(os.TempDir(), submission.ProblemName+"-"+submission.ID.String()+".out")
-	defer os.Remove(executable)
+	buildDir, err := ioutil.TempDir(os.TempDir(), submission.ProblemName+"-"+submission.ID.String()+"-")
+	if err != nil {
+		return p.fail(submission, err)
+	}
+	defer os.RemoveAll(buildDir)
 
-	submission.CompilationOutput, err = testcase.CompileSolution(solution, submission.CompilationMode, executable)
+	sources, err := testcase.WithGrader(p.testcaseArchive, submission.ProblemName, lang, submission.Solution(source))
+	if err != nil {
+		submission.CompilationOutput = []byte("problem grader is misconfigured: " + err.Error())
+		p.saveWithStatus(&submission, CompilationError)
+		return submission, err
+	}
 
+	testcases, err := p.testcaseArchive.Testcases(submission.ProblemName)
+	if err != nil {
+		return p.fail(submission, err)
+	}
+	for i := range testcases {
+		testcases[i] = lang.ScaleLimits(testcases[i])
+	}
+	submission.CompilerVersion = testcase.CompilerVersion(lang, submission.CompilationMode)
+	submission.JudgeKey, err = p.judgeKey(submission.ProblemName, sources, lang, submission.CompilationMode, testcases)
 	if err != nil {
-		submission.Status = CompilationError
-		p.store.Save(submission)
+		log.Println("unable to compute the judge key of submission", submission.ID, err)
+	}
+	judged, found := Metadata{}, false
+	if !rejudge {
+		judged, found = p.findJudged(submission)
+	}
+	if found {
+		log.Println("submission", submission.ID, "has the same solution as", judged.ID, "reusing its results")
+		err = p.reuseResults(&submission, judged)
+		submission.TotalProcessingTime = time.Since(start)
+		if saveErr := p.saveWithStatus(&submission, AllTestsCompleted); err == nil {
+			err = saveErr
+		}
 		return submission, err
 	}
 
-	submission.Status = RunningTests
-	p.store.Save(submission)
+	var command []string
+	command, submission.CompilationOutput, submission.CompilationCached, err = testcase.CompileSolutionCached(
+		p.cache, sources, lang, submission.CompilationMode, buildDir)
+	submission.Diagnostics = testcase.ParseDiagnostics(submission.CompilationOutput)
 
-	testcases, err := p.testcaseArchive.Testcases(submission.ProblemName)
+	if err == testcase.ErrCompilationTimeout {
+		p.saveWithStatus(&submission, CompilationTimeout)
+		return submission, err
+	}
 	if err != nil {
+		p.saveWithStatus(&submission, CompilationError)
 		return submission, err
 	}
+	// findings of analyzers are only informative, the verdict depends on tests
+	submission.StaticAnalysis, err = testcase.StaticAnalysis(sources, lang, submission.CompilationMode)
+	if err != nil {
+		log.Println("static analysis of submission", submission.ID, "failed:", err)
+	}
+
 	submission.TestCasesCount = len(testcases)
+	p.saveWithStatus(&submission, RunningTests)
 
 	runner := p.testcaseArchive.Runner(submission.ProblemName)
 
@@ -85,43 +205,179 @@ func (p *defaultProcessor) processSubmission(submission Metadata) (res Metadata,
 
 	resultChan := make(chan testcase.CompletedTestCase, len(testcases))
 	for i := 0; i < submission.WorkerCount; i++ {
-		go testcaseProcessor(runner, executable, infoChan, resultChan)
+		go testcaseProcessor(ctx, runner, command, infoChan, resultChan)
 	}
 
-	processedTestCases := make([]testcase.CompletedTestCase, 0)
+	// processedTestCases is append-only while tests are running, so the Storage can copy the in-memory
+	// view for readers while it grows. It is sorted once all the tests are completed.
+	processedTestCases := make([]testcase.CompletedTestCase, 0, len(testcases))
 	for i := 0; i < len(testcases); i++ {
 		completedTc := <-resultChan
 		processedTestCases = append(processedTestCases, completedTc)
 		if completedTc.Result.Status == testcase.Accepted {
 			submission.AcceptedCount++
 		}
-		// TODO: Implement saving submission using channels not mutex
-		sort.Sort(testcase.ByTestcaseStatusAndName(processedTestCases))
 		submission.CompletedTestCases = processedTestCases
-		p.store.Save(submission)
+		p.writer.Update(submission)
+		p.events.Publish(NewTestCaseCompletedEvent(submission, completedTc))
 	}
 
-	submission.Status = AllTestsCompleted
+	sortedTestCases := make([]testcase.CompletedTestCase, len(processedTestCases))
+	copy(sortedTestCases, processedTestCases)
+	sort.Sort(testcase.ByTestcaseStatusAndName(sortedTestCases))
+	submission.CompletedTestCases = sortedTestCases
+	if testcase.CoverageEnabled(lang, submission.CompilationMode) {
+		if err := p.saveCoverage(&submission, lang, buildDir); err != nil {
+			log.Println("unable to collect coverage of submission", submission.ID, err)
+		}
+	}
 	submission.TotalProcessingTime = time.Since(start)
-	err = p.store.Save(submission)
+	err = p.saveWithStatus(&submission, AllTestsCompleted)
 	log.Println("Processed submission", submission)
 	return submission, err
 }
 
+// judgeKey hash of everything the verdict depends on: the compilation (see testcase.CompilationCacheKey),
+// data of the problem and the tests with their limits
+func (p *defaultProcessor) judgeKey(problemName string, sources testcase.Solution, lang testcase.Language,
+	mode testcase.CompilationMode, testcases []testcase.Info) (string, error) {
+	compilation, err := testcase.CompilationCacheKey(sources, lang, mode)
+	if err != nil {
+		return "", err
+	}
+	problem, err := p.testcaseArchive.Fingerprint(problemName)
+	if err != nil {
+		return "", err
+	}
+	sorted := make([]testcase.Info, len(testcases))
+	copy(sorted, testcases)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	if err = enc.Encode([]string{compilation, problem}); err != nil {
+		return "", err
+	}
+	if err = enc.Encode(sorted); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findJudged finds a completed submission of the same solution to the problem with the same judge key,
+// so its results are the results of the submission
+func (p *defaultProcessor) findJudged(submission Metadata) (Metadata, bool) {
+	if submission.SolutionHash == "" || submission.CompilerVersion == "" || submission.JudgeKey == "" {
+		return Metadata{}, false
+	}
+	page, err := p.store.Query(Query{
+		ProblemName:     submission.ProblemName,
+		Status:          AllTestsCompleted,
+		CompilationMode: submission.CompilationMode,
+		Language:        submission.Language,
+		SolutionHash:    submission.SolutionHash,
+		Limit:           MaxQueryLimit,
+	})
+	if err != nil {
+		log.Println("unable to look for judged submissions of the same solution:", err)
+		return Metadata{}, false
+	}
+	for _, judged := range page.Submissions {
+		if judged.ID != submission.ID && judged.JudgeKey == submission.JudgeKey {
+			return judged, true
+		}
+	}
+	return Metadata{}, false
+}
+
+// reuseResults copies results and artifacts of the judged submission into the submission
+func (p *defaultProcessor) reuseResults(submission *Metadata, judged Metadata) error {
+	from := judged.ID
+	if judged.ResultsFrom != nil {
+		from = *judged.ResultsFrom
+	}
+	submission.ResultsFrom = &from
+	submission.CompilerVersion = judged.CompilerVersion
+	submission.CompilationOutput = judged.CompilationOutput
+	submission.Diagnostics = judged.Diagnostics
+	submission.StaticAnalysis = judged.StaticAnalysis
+	submission.CompletedTestCases = judged.CompletedTestCases
+	submission.TestCasesCount = judged.TestCasesCount
+	submission.AcceptedCount = judged.AcceptedCount
+	submission.Coverage = judged.Coverage
+
+	artifacts, err := p.store.ListArtifacts(judged)
+	if err != nil {
+		return err
+	}
+	for _, name := range artifacts {
+		content, err := p.store.DownloadArtifact(judged, name)
+		if err != nil {
+			return err
+		}
+		err = p.store.UploadArtifact(*submission, name, content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveCoverage stores coverage gathered by all test runs as the CoverageArtifact of the submission
+func (p *defaultProcessor) saveCoverage(submission *Metadata, lang testcase.Language, buildDir string) error {
+	report, err := testcase.CollectCoverage(lang, submission.CompilationMode, buildDir)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if err = p.store.UploadArtifact(*submission, CoverageArtifact, bytes.NewReader(content)); err != nil {
+		return err
+	}
+	summary := report.Summary()
+	submission.Coverage = &summary
+	return nil
+}
+
 func (p *defaultProcessor) Process() error {
 	if err := p.store.LoadAll(); err != nil {
 		log.Panic(err)
 	}
-	for submission := range p.queue {
-		_, err := p.processSubmission(submission)
-		if err != nil {
-			log.Println("ProcessSubmission returned error: ", err)
+	for {
+		select {
+		case <-p.quit:
+			p.writer.Close()
+			fmt.Println("defaultSubmissionProcessor has exited successfully.")
+			return nil
+		case queued := <-p.queue:
+			ctx, cancel := context.WithCancel(context.Background())
+			p.m.Lock()
+			p.inProgress, p.cancelInProgress = queued.meta.ID, cancel
+			p.m.Unlock()
+			_, err := p.processSubmission(ctx, queued.meta, queued.rejudge)
+			p.m.Lock()
+			p.cancelInProgress = nil
+			p.m.Unlock()
+			cancel()
+			if err != nil {
+				log.Println("ProcessSubmission returned error: ", err)
+			}
 		}
 	}
-	fmt.Println("defaultSubmissionProcessor has exited successfully.")
-	return nil
 }
 
 func (p *defaultProcessor) Quit() {
-	close(p.queue)
+	close(p.quit)
+}
+
+func (p *defaultProcessor) Cancel(id ID) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.cancelInProgress == nil || p.inProgress != id {
+		return false
+	}
+	p.cancelInProgress()
+	return true
 }
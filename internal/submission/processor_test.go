@@ -1,8 +1,11 @@
 package submission
 
 import (
+	"context"
+	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -11,6 +14,8 @@ import (
 	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
 )
 
+var cpp, _ = testcase.LookupLanguage(testcase.DefaultLanguageID)
+
 type inMemoryRunner struct {
 }
 
@@ -21,7 +26,7 @@ func NewInMemoryArchive() testcase.Archive {
 	return &imMemoryArchive{}
 }
 
-func (runner *inMemoryRunner) Run(executable string, info testcase.Info) testcase.Result {
+func (runner *inMemoryRunner) Run(ctx context.Context, command []string, info testcase.Info) testcase.Result {
 	return testcase.Result{Status: testcase.Accepted, Description: info.Name}
 }
 
@@ -42,21 +47,51 @@ func (archive *imMemoryArchive) Runner(problemName string) testcase.Runner {
 	return &inMemoryRunner{}
 }
 
-func TestProcessor_ProcessSolution(t *testing.T) {
+func (archive *imMemoryArchive) Config(problemName string) (testcase.ProblemConfig, error) {
+	return testcase.ProblemConfig{}, nil
+}
+
+func (archive *imMemoryArchive) ProblemFile(problemName, filename string) ([]byte, error) {
+	return nil, os.ErrNotExist
+}
+
+func (archive *imMemoryArchive) Fingerprint(problemName string) (string, error) {
+	return "in-memory", nil
+}
 
+// newTestProcessor processor of submissions stored in the "submissions" subdirectory of a new temporary
+// directory, a nil archive reads problems from its "problems" subdirectory. The caller removes the directory.
+func newTestProcessor(t *testing.T, archive testcase.Archive, events EventBus, cache testcase.CompilationCache) (*defaultProcessor, Storage, string) {
 	dirname, err := ioutil.TempDir(os.TempDir(), "testprocessor-*")
 	assert.NoError(t, err)
-	storage := NewDefaultStorage(dirname)
-	storage.Init()
+	storage := NewDefaultStorage(filepath.Join(dirname, "submissions"))
+	assert.NoError(t, storage.Init())
+	if archive == nil {
+		archive = testcase.NewArchive(filepath.Join(dirname, "problems"))
+	}
+	return NewProcessor(storage, archive, events, cache).(*defaultProcessor), storage, dirname
+}
 
-	testcaseArchive := NewInMemoryArchive()
-	proc := NewProcessor(storage, testcaseArchive)
+// writeTestProblem creates a problem with a single testcase t1 in the "problems" subdirectory of dirname
+func writeTestProblem(t *testing.T, dirname, problemName, input, output string) string {
+	problemDir := filepath.Join(dirname, "problems", problemName)
+	assert.NoError(t, os.MkdirAll(problemDir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(problemDir, "t1.in"), []byte(input), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(problemDir, "t1.out"), []byte(output), 0644))
+	return problemDir
+}
 
-	metadata := NewMetadata("problem1", testcase.ReleaseMode)
+func TestProcessor_ProcessSolution(t *testing.T) {
+	events := NewEventBus()
+	received, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+	proc, storage, dirname := newTestProcessor(t, NewInMemoryArchive(), events, nil)
+
+	metadata := NewMetadata("problem1", cpp, testcase.ReleaseMode)
 	sol := strings.NewReader(`#include <cstdio>
 	int main() { printf("1\n"); return 0; }
 	`)
-	storage.Upload(metadata, sol)
+	storage.Upload(&metadata, sol)
 
 	assert.Equal(t, Queued, metadata.Status)
 
@@ -83,6 +118,24 @@ func TestProcessor_ProcessSolution(t *testing.T) {
 	assert.Equal(t, "t17", metadata.CompletedTestCases[3].Info.Name)
 	assert.Equal(t, "t20", metadata.CompletedTestCases[4].Info.Name)
 
+	expectedStatuses := []Status{Queued, Compiling, RunningTests}
+	for _, status := range expectedStatuses {
+		e := <-received
+		assert.Equal(t, StatusChanged, e.Type)
+		assert.Equal(t, status, e.Status)
+		assert.Equal(t, metadata.ID, e.SubmissionID)
+	}
+	for i := 0; i < 5; i++ {
+		e := <-received
+		assert.Equal(t, TestCaseCompleted, e.Type)
+		assert.Equal(t, 5, e.TestCasesCount)
+		assert.Equal(t, i+1, e.AcceptedCount)
+		assert.NotNil(t, e.TestCase)
+	}
+	e := <-received
+	assert.Equal(t, StatusChanged, e.Type)
+	assert.Equal(t, AllTestsCompleted, e.Status)
+
 	proc.Quit()
 	storage.Destroy()
 	// for extra safety
@@ -90,3 +143,338 @@ func TestProcessor_ProcessSolution(t *testing.T) {
 		os.RemoveAll(dirname)
 	}
 }
+
+func TestProcessor_UnknownLanguage(t *testing.T) {
+	proc, storage, dirname := newTestProcessor(t, NewInMemoryArchive(), NewEventBus(), nil)
+	defer os.RemoveAll(dirname)
+
+	metadata := NewMetadata("problem1", testcase.Language{ID: "cobol", Extension: ".cob"}, testcase.ReleaseMode)
+	assert.Equal(t, metadata.ID.String()+".cob", metadata.SolutionFilename)
+	assert.NoError(t, storage.Upload(&metadata, strings.NewReader("DISPLAY 'HELLO'.")))
+
+	_, err := proc.processSubmission(context.Background(), metadata, false)
+	assert.EqualError(t, err, "unknown language 'cobol'")
+	metadata, ok := storage.Get(metadata.ID)
+	assert.True(t, ok)
+	assert.Equal(t, CompilationError, metadata.Status)
+	assert.Equal(t, "unknown language: cobol", string(metadata.CompilationOutput))
+}
+
+func TestProcessor_FailsWhenUnableToJudge(t *testing.T) {
+	events := NewEventBus()
+	received, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+	proc, storage, dirname := newTestProcessor(t, NewInMemoryArchive(), events, nil)
+	defer os.RemoveAll(dirname)
+
+	// the solution was never uploaded
+	metadata := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	_, err := proc.processSubmission(context.Background(), metadata, false)
+	assert.Error(t, err)
+	res, ok := storage.Get(metadata.ID)
+	assert.True(t, ok)
+	assert.Equal(t, InternalError, res.Status)
+	assert.True(t, res.Status.Final())
+	assert.Contains(t, string(res.CompilationOutput), "unable to judge the submission")
+	assert.Equal(t, Compiling, (<-received).Status)
+	assert.Equal(t, InternalError, (<-received).Status)
+}
+
+func TestProcessor_CompilationCache(t *testing.T) {
+	cacheDir, err := ioutil.TempDir(os.TempDir(), "testcache-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+	cache, err := testcase.NewFileCompilationCache(cacheDir, 1<<30)
+	assert.NoError(t, err)
+	proc, storage, dirname := newTestProcessor(t, NewInMemoryArchive(), NewEventBus(), cache)
+	defer os.RemoveAll(dirname)
+
+	// the same solution to another problem reuses the compiled files, but its tests run again
+	submit := func(problem string) Metadata {
+		metadata := NewMetadata(problem, cpp, testcase.ReleaseMode)
+		assert.NoError(t, storage.Upload(&metadata, strings.NewReader(`int main() { return 0; }`)))
+		res, err := proc.processSubmission(context.Background(), metadata, false)
+		assert.NoError(t, err)
+		assert.Equal(t, AllTestsCompleted, res.Status)
+		assert.Nil(t, res.ResultsFrom)
+		return res
+	}
+	assert.False(t, submit("problem1").CompilationCached)
+	assert.True(t, submit("problem2").CompilationCached)
+}
+
+func TestProcessor_ReusesResultsOfTheSameSolution(t *testing.T) {
+	proc, storage, dirname := newTestProcessor(t, NewInMemoryArchive(), NewEventBus(), nil)
+	defer os.RemoveAll(dirname)
+
+	submit := func(mode testcase.CompilationMode, source string) Metadata {
+		metadata := NewMetadata("problem1", cpp, mode)
+		assert.NoError(t, storage.Upload(&metadata, strings.NewReader(source)))
+		res, err := proc.processSubmission(context.Background(), metadata, false)
+		assert.NoError(t, err)
+		assert.Equal(t, AllTestsCompleted, res.Status)
+		return res
+	}
+	judged := submit(testcase.CoverageMode, "int main() { return 0; }\n")
+	assert.Nil(t, judged.ResultsFrom)
+	assert.NotNil(t, judged.Coverage)
+
+	reused := submit(testcase.CoverageMode, "int main() { return 0; }\n")
+	assert.Equal(t, &judged.ID, reused.ResultsFrom)
+	assert.Equal(t, judged.SolutionHash, reused.SolutionHash)
+	assert.Equal(t, judged.CompletedTestCases, reused.CompletedTestCases)
+	assert.Equal(t, judged.AcceptedCount, reused.AcceptedCount)
+	assert.Equal(t, judged.Coverage, reused.Coverage)
+	artifacts, err := storage.ListArtifacts(reused)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{CoverageArtifact}, artifacts)
+	// results of a reused submission point to the judged one
+	assert.Equal(t, &judged.ID, submit(testcase.CoverageMode, "int main() { return 0; }\n").ResultsFrom)
+
+	assert.Nil(t, submit(testcase.ReleaseMode, "int main() { return 0; }\n").ResultsFrom)
+	assert.Nil(t, submit(testcase.CoverageMode, "int main() { return 1; }\n").ResultsFrom)
+}
+
+func TestProcessor_RejudgeDoesNotReuseResults(t *testing.T) {
+	proc, storage, dirname := newTestProcessor(t, NewInMemoryArchive(), NewEventBus(), nil)
+	defer os.RemoveAll(dirname)
+
+	submit := func() Metadata {
+		metadata := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+		assert.NoError(t, storage.Upload(&metadata, strings.NewReader("int main() {}\n")))
+		res, err := proc.processSubmission(context.Background(), metadata, false)
+		assert.NoError(t, err)
+		return res
+	}
+	judged := submit()
+	reused := submit()
+	assert.Equal(t, &judged.ID, reused.ResultsFrom)
+
+	go proc.Process()
+	defer proc.Quit()
+	proc.Rejudge(reused)
+	for i := 0; i < 50; i++ {
+		if m, _ := storage.Get(reused.ID); m.Status == AllTestsCompleted && m.ResultsFrom == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	rejudged, ok := storage.Get(reused.ID)
+	assert.True(t, ok)
+	assert.Equal(t, AllTestsCompleted, rejudged.Status)
+	assert.Nil(t, rejudged.ResultsFrom)
+	assert.Equal(t, 5, rejudged.AcceptedCount)
+}
+
+func TestProcessor_RejudgesWhenProblemOrProfileChanges(t *testing.T) {
+	defer testcase.SetCompilationProfiles(testcase.DefaultCompilationProfiles())
+	proc, storage, dirname := newTestProcessor(t, nil, NewEventBus(), nil)
+	defer os.RemoveAll(dirname)
+	problemDir := writeTestProblem(t, dirname, "double", "21\n", "42\n")
+
+	submit := func() Metadata {
+		metadata := NewMetadata("double", cpp, testcase.ReleaseMode)
+		assert.NoError(t, storage.Upload(&metadata, strings.NewReader(`#include <cstdio>
+int main() { int x; scanf("%d", &x); printf("%d\n", 2 * x); }
+`)))
+		res, err := proc.processSubmission(context.Background(), metadata, false)
+		assert.NoError(t, err)
+		assert.Equal(t, AllTestsCompleted, res.Status)
+		assert.NotEmpty(t, res.JudgeKey)
+		return res
+	}
+	judged := submit()
+	assert.Equal(t, 1, judged.AcceptedCount)
+	assert.Equal(t, &judged.ID, submit().ResultsFrom)
+
+	// a fixed expected output
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(problemDir, "t1.out"), []byte("43\n"), 0644))
+	rejudged := submit()
+	assert.Nil(t, rejudged.ResultsFrom)
+	assert.NotEqual(t, judged.JudgeKey, rejudged.JudgeKey)
+	assert.Equal(t, 0, rejudged.AcceptedCount)
+	assert.Equal(t, testcase.WrongAnswer, rejudged.CompletedTestCases[0].Result.Status)
+	assert.Equal(t, &rejudged.ID, submit().ResultsFrom)
+
+	// different flags of the same compilation profile
+	profiles := testcase.DefaultCompilationProfiles()
+	for i := range profiles {
+		if profiles[i].ID == testcase.ReleaseMode {
+			profiles[i].Flags = append(profiles[i].Flags, "-DREJUDGE")
+		}
+	}
+	assert.NoError(t, testcase.SetCompilationProfiles(profiles))
+	assert.Nil(t, submit().ResultsFrom)
+}
+
+func TestProcessor_CompilationTimeout(t *testing.T) {
+	assert.NoError(t, testcase.SetCompilationLimits(testcase.CompilationLimits{TimeoutSeconds: 1}))
+	defer testcase.SetCompilationLimits(testcase.DefaultCompilationLimits())
+	proc, storage, dirname := newTestProcessor(t, NewInMemoryArchive(), NewEventBus(), nil)
+	defer os.RemoveAll(dirname)
+
+	metadata := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	assert.NoError(t, storage.Upload(&metadata, strings.NewReader("#include </dev/urandom>\n")))
+	res, err := proc.processSubmission(context.Background(), metadata, false)
+	assert.Equal(t, testcase.ErrCompilationTimeout, err)
+	assert.Equal(t, CompilationTimeout, res.Status)
+	assert.Equal(t, "CompilationTimeout", res.Status.String())
+}
+
+func TestProcessor_WarningsOfSuccessfulCompilation(t *testing.T) {
+	proc, storage, dirname := newTestProcessor(t, NewInMemoryArchive(), NewEventBus(), nil)
+	defer os.RemoveAll(dirname)
+
+	metadata := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	assert.NoError(t, storage.Upload(&metadata, strings.NewReader("int main() {\n  int x = 1 / 0;\n}\n")))
+	res, err := proc.processSubmission(context.Background(), metadata, false)
+	assert.NoError(t, err)
+	assert.Equal(t, AllTestsCompleted, res.Status)
+	assert.Equal(t, 1, len(res.Diagnostics))
+	assert.Equal(t, "warning", res.Diagnostics[0].Severity)
+	assert.Equal(t, 2, res.Diagnostics[0].Line)
+	assert.Equal(t, "-Wdiv-by-zero", res.Diagnostics[0].Option)
+}
+
+func TestProcessor_Coverage(t *testing.T) {
+	proc, storage, dirname := newTestProcessor(t, nil, NewEventBus(), nil)
+	defer os.RemoveAll(dirname)
+	writeTestProblem(t, dirname, "sign", "5\n", "positive\n")
+
+	metadata := NewMetadata("sign", cpp, testcase.CoverageMode)
+	assert.NoError(t, storage.Upload(&metadata, strings.NewReader(`#include <cstdio>
+int main() {
+  int x;
+  scanf("%d", &x);
+  if (x > 0)
+    printf("positive\n");
+  else
+    printf("not positive\n");
+}
+`)))
+	res, err := proc.processSubmission(context.Background(), metadata, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.AcceptedCount)
+	if assert.NotNil(t, res.Coverage) {
+		assert.Equal(t, res.Coverage.LinesTotal-1, res.Coverage.LinesCovered)
+		assert.True(t, res.Coverage.BranchesTaken < res.Coverage.BranchesTotal)
+	}
+
+	content, err := storage.DownloadArtifact(res, CoverageArtifact)
+	assert.NoError(t, err)
+	defer content.Close()
+	var report testcase.CoverageReport
+	assert.NoError(t, json.NewDecoder(content).Decode(&report))
+	assert.Equal(t, 1, len(report.Files))
+	assert.Equal(t, "<stdin>", report.Files[0].File)
+	for _, l := range report.Files[0].Lines {
+		assert.Equal(t, l.Line != 8, l.Count > 0, "line %d", l.Line)
+	}
+}
+
+func TestProcessor_StaticAnalysisDoesNotAffectVerdict(t *testing.T) {
+	defer testcase.SetCompilationProfiles(testcase.DefaultCompilationProfiles())
+	assert.NoError(t, testcase.SetCompilationProfiles([]testcase.CompilationProfile{{
+		ID:        "Lint",
+		Compilers: map[string]string{testcase.FamilyCpp: "g++"},
+		Standards: map[string]string{testcase.FamilyCpp: "c++17"},
+		Analyzers: []testcase.StaticAnalyzer{{
+			Name:    "fake",
+			Command: []string{"/bin/sh", "-c", `echo "$0:1:5: error: looks wrong [fake-check]"; exit 2`, "{source}"},
+		}},
+	}}))
+	proc, storage, dirname := newTestProcessor(t, NewInMemoryArchive(), NewEventBus(), nil)
+	defer os.RemoveAll(dirname)
+
+	metadata := NewMetadata("problem1", cpp, "Lint")
+	assert.NoError(t, storage.Upload(&metadata, strings.NewReader("int main() {}\n")))
+	res, err := proc.processSubmission(context.Background(), metadata, false)
+	assert.NoError(t, err)
+	assert.Equal(t, AllTestsCompleted, res.Status)
+	assert.Equal(t, 5, res.AcceptedCount)
+	assert.Equal(t, []testcase.Finding{{Tool: "fake", Check: "fake-check", Severity: "error", File: "solution.cpp", Line: 1, Column: 5, Message: "looks wrong"}}, res.StaticAnalysis)
+}
+
+func TestProcessor_QuitSavesPendingUpdates(t *testing.T) {
+	proc, storage, dirname := newTestProcessor(t, NewInMemoryArchive(), NewEventBus(), nil)
+	defer os.RemoveAll(dirname)
+	proc.writer = newBatchWriter(storage, 100, time.Hour)
+
+	processed := make(chan struct{})
+	go func() {
+		proc.Process()
+		close(processed)
+	}()
+	metadata := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	metadata.Status = RunningTests
+	metadata.AcceptedCount = 3
+	proc.writer.Update(metadata)
+
+	proc.Quit()
+	select {
+	case <-processed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Process didn't return after Quit")
+	}
+	reloaded := NewDefaultStorage(filepath.Join(dirname, "submissions"))
+	assert.NoError(t, reloaded.LoadAll())
+	persisted, ok := reloaded.Get(metadata.ID)
+	assert.True(t, ok)
+	assert.Equal(t, 3, persisted.AcceptedCount)
+}
+
+// blockingRunner runs tests until they are cancelled
+type blockingRunner struct {
+	started chan struct{}
+}
+
+func (runner *blockingRunner) Run(ctx context.Context, command []string, info testcase.Info) testcase.Result {
+	select {
+	case runner.started <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	return testcase.Result{Status: testcase.InternalError, Description: "cancelled"}
+}
+
+type blockingArchive struct {
+	imMemoryArchive
+	runner *blockingRunner
+}
+
+func (archive *blockingArchive) Runner(problemName string) testcase.Runner {
+	return archive.runner
+}
+
+func TestProcessor_CancelAbortsTests(t *testing.T) {
+	archive := &blockingArchive{runner: &blockingRunner{started: make(chan struct{}, 1)}}
+	proc, storage, dirname := newTestProcessor(t, archive, NewEventBus(), nil)
+	defer os.RemoveAll(dirname)
+
+	// uploaded before Process loads the storage, which removes temporary files of saves in progress
+	metadata := NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	assert.NoError(t, storage.Upload(&metadata, strings.NewReader("int main() {}\n")))
+	go proc.Process()
+	defer proc.Quit()
+	assert.False(t, proc.Cancel(metadata.ID))
+	proc.Submit(metadata)
+	select {
+	case <-archive.runner.started:
+	case <-time.After(30 * time.Second):
+		t.Fatal("tests didn't start")
+	}
+	assert.False(t, proc.Cancel(NewMetadata("problem1", cpp, testcase.ReleaseMode).ID))
+	assert.True(t, proc.Cancel(metadata.ID))
+
+	for i := 0; i < 50; i++ {
+		if m, _ := storage.Get(metadata.ID); m.Status == AllTestsCompleted {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	res, ok := storage.Get(metadata.ID)
+	assert.True(t, ok)
+	assert.Equal(t, AllTestsCompleted, res.Status)
+	assert.Equal(t, 5, len(res.CompletedTestCases))
+	assert.Equal(t, 0, res.AcceptedCount)
+}
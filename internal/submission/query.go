@@ -0,0 +1,153 @@
+package submission
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+const (
+	// DefaultQueryLimit number of submissions on a page if Query.Limit is not set
+	DefaultQueryLimit = 50
+	// MaxQueryLimit maximal number of submissions on a page
+	MaxQueryLimit = 500
+)
+
+// ErrInvalidCursor returned by Storage.Query when Query.Cursor is not a cursor returned by it
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Query selects a page of submissions from the Storage, zero values of fields match all submissions
+type Query struct {
+	ProblemName     string
+	Status          Status
+	CompilationMode testcase.CompilationMode
+	Author          string
+	Language        string
+	// SolutionHash finds submissions of the same solution, see Metadata.SolutionHash
+	SolutionHash string
+	// SubmittedAfter and SubmittedBefore bound the time of the submission, inclusive and exclusive
+	SubmittedAfter  time.Time
+	SubmittedBefore time.Time
+	// Oldest sorts the oldest submissions first, the most recent ones are first by default
+	Oldest bool
+	// Limit maximal number of submissions on the page, DefaultQueryLimit if 0
+	Limit int
+	// Cursor Page.NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// Page submissions selected by a Query
+type Page struct {
+	Submissions []Metadata `json:"submissions"`
+	// NextCursor cursor of the next page, empty if this is the last one
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Validate checks if the query can be executed
+func (q Query) Validate() error {
+	if q.Limit < 0 || q.Limit > MaxQueryLimit {
+		return fmt.Errorf("limit must be between 1 and %d", MaxQueryLimit)
+	}
+	if _, err := q.cursor(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (q Query) limit() int {
+	if q.Limit == 0 {
+		return DefaultQueryLimit
+	}
+	return q.Limit
+}
+
+// Matches returns true if the submission matches filters of the query, the cursor is not taken into account
+func (q Query) Matches(m Metadata) bool {
+	return (q.ProblemName == "" || m.ProblemName == q.ProblemName) &&
+		(q.Status == 0 || m.Status == q.Status) &&
+		(q.CompilationMode == "" || m.CompilationMode == q.CompilationMode) &&
+		(q.Author == "" || m.Author == q.Author) &&
+		(q.Language == "" || m.Language == q.Language) &&
+		(q.SolutionHash == "" || m.SolutionHash == q.SolutionHash) &&
+		(q.SubmittedAfter.IsZero() || !m.SubmittedAt.Before(q.SubmittedAfter)) &&
+		(q.SubmittedBefore.IsZero() || m.SubmittedAt.Before(q.SubmittedBefore))
+}
+
+// pageKey position of a submission in the order of pages, ties of time are broken by ID
+type pageKey struct {
+	submittedAt int64
+	id          string
+}
+
+func keyOf(m Metadata) pageKey {
+	return pageKey{submittedAt: m.SubmittedAt.UnixNano(), id: m.ID.String()}
+}
+
+func (k pageKey) less(other pageKey) bool {
+	if k.submittedAt != other.submittedAt {
+		return k.submittedAt < other.submittedAt
+	}
+	return k.id < other.id
+}
+
+func (k pageKey) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(k.submittedAt, 10) + ":" + k.id))
+}
+
+// cursor decoded Cursor of the query, nil for the first page
+func (q Query) cursor() (*pageKey, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	submittedAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &pageKey{submittedAt: submittedAt, id: parts[1]}, nil
+}
+
+// before returns true if a comes before b in the order of the query
+func (q Query) before(a, b pageKey) bool {
+	if q.Oldest {
+		return a.less(b)
+	}
+	return b.less(a)
+}
+
+// queryInMemory executes the query on all submissions
+func queryInMemory(all []Metadata, q Query) (Page, error) {
+	if err := q.Validate(); err != nil {
+		return Page{}, err
+	}
+	after, _ := q.cursor()
+	res := make([]Metadata, 0)
+	for _, m := range all {
+		if q.Matches(m) && (after == nil || q.before(*after, keyOf(m))) {
+			res = append(res, m)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return q.before(keyOf(res[i]), keyOf(res[j])) })
+	return newPage(res, q.limit()), nil
+}
+
+// newPage cuts the page from submissions sorted in the order of the query, there may be one more than limit
+func newPage(sorted []Metadata, limit int) Page {
+	if len(sorted) <= limit {
+		return Page{Submissions: sorted}
+	}
+	return Page{Submissions: sorted[:limit], NextCursor: keyOf(sorted[limit-1]).encode()}
+}
@@ -0,0 +1,129 @@
+package submission
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+func newTestStorages(t *testing.T) map[string]Storage {
+	files, _ := newTestDefaultStorage(t)
+	sqlite, _ := newTestSQLiteStorage(t)
+	return map[string]Storage{"files": files, "sqlite": sqlite}
+}
+
+// querySubmissions 10 submissions of two problems and two authors, each second one is in the coverage mode
+func querySubmissions() []Metadata {
+	submissions := testSubmissions(10)
+	for i := range submissions {
+		if i%2 == 1 {
+			submissions[i].CompilationMode = testcase.CoverageMode
+		}
+		if i >= 5 {
+			submissions[i].ProblemName = "otherProblem"
+			submissions[i].Author = "alice"
+		}
+	}
+	submissions[9].Status = CompilationError
+	return submissions
+}
+
+func ids(submissions []Metadata) []ID {
+	res := make([]ID, len(submissions))
+	for i, m := range submissions {
+		res[i] = m.ID
+	}
+	return res
+}
+
+func TestStorage_QueryFilters(t *testing.T) {
+	submissions := querySubmissions()
+	from := submissions[0].SubmittedAt
+	tests := []struct {
+		name     string
+		query    Query
+		expected []int
+	}{
+		{"all", Query{}, []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}},
+		{"problem", Query{ProblemName: "aProblem"}, []int{4, 3, 2, 1, 0}},
+		{"status", Query{Status: CompilationError}, []int{9}},
+		{"mode", Query{CompilationMode: testcase.CoverageMode, Oldest: true}, []int{1, 3, 5, 7, 9}},
+		{"author", Query{Author: "alice", CompilationMode: testcase.ReleaseMode}, []int{8, 6}},
+		{"dates", Query{SubmittedAfter: from.Add(2 * time.Second), SubmittedBefore: from.Add(4 * time.Second)}, []int{3, 2}},
+		{"no match", Query{ProblemName: "unknown"}, []int{}},
+	}
+	for name, store := range newTestStorages(t) {
+		for _, m := range submissions {
+			assert.NoError(t, store.Save(m))
+		}
+		for _, tc := range tests {
+			page, err := store.Query(tc.query)
+			assert.NoError(t, err, name+" "+tc.name)
+			expected := make([]Metadata, len(tc.expected))
+			for i, idx := range tc.expected {
+				expected[i] = submissions[idx]
+			}
+			assert.Equal(t, ids(expected), ids(page.Submissions), name+" "+tc.name)
+			assert.Empty(t, page.NextCursor, name+" "+tc.name)
+		}
+		store.Destroy()
+	}
+}
+
+func TestStorage_QueryPages(t *testing.T) {
+	submissions := querySubmissions()
+	// submissions with the same time are paged by ID
+	submissions[4].SubmittedAt = submissions[3].SubmittedAt
+	submissions[5].SubmittedAt = submissions[3].SubmittedAt
+	for name, store := range newTestStorages(t) {
+		for _, m := range submissions {
+			assert.NoError(t, store.Save(m))
+		}
+		for _, oldest := range []bool{false, true} {
+			var paged []ID
+			query := Query{Limit: 3, Oldest: oldest}
+			for pages := 1; ; pages++ {
+				page, err := store.Query(query)
+				assert.NoError(t, err)
+				assert.LessOrEqual(t, len(page.Submissions), 3)
+				paged = append(paged, ids(page.Submissions)...)
+				if page.NextCursor == "" {
+					assert.Equal(t, 4, pages, name)
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			all, err := store.Query(Query{Oldest: oldest})
+			assert.NoError(t, err)
+			assert.Equal(t, ids(all.Submissions), paged, name)
+			assert.Len(t, paged, 10)
+		}
+		store.Destroy()
+	}
+}
+
+func TestStorage_QueryInvalid(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		_, err := store.Query(Query{Cursor: "not a cursor"})
+		assert.Equal(t, ErrInvalidCursor, err, name)
+		_, err = store.Query(Query{Limit: MaxQueryLimit + 1})
+		assert.Error(t, err, name)
+		store.Destroy()
+	}
+}
+
+func TestSQLiteStorage_QueryUpdated(t *testing.T) {
+	store, _ := newTestSQLiteStorage(t)
+	defer store.Destroy()
+	m := testSubmissions(1)[0]
+	m.Status = Queued
+	assert.NoError(t, store.Save(m))
+	m.Status = RunningTests
+	store.Update(m)
+
+	page, err := store.Query(Query{ProblemName: m.ProblemName})
+	assert.NoError(t, err)
+	assert.Equal(t, RunningTests, page.Submissions[0].Status)
+}
@@ -0,0 +1,163 @@
+package submission
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy rules for removing old submissions, zero value of a limit means no limit.
+// Submissions which are still processed are never removed.
+type RetentionPolicy struct {
+	// MaxAgeDays submissions older than that are removed
+	MaxAgeDays int `json:"maxAgeDays"`
+	// MaxPerProblem only that many most recent submissions of each problem are kept
+	MaxPerProblem int `json:"maxPerProblem"`
+	// MaxPerAuthor only that many most recent submissions of each author are kept, submissions without an author are not limited
+	MaxPerAuthor int `json:"maxPerAuthor"`
+	// KeepBest keeps the submission with the most accepted tests of every author of every problem
+	KeepBest bool `json:"keepBest"`
+	// KeepLatestAccepted keeps the most recent submission which passed all tests of every author of every problem
+	KeepLatestAccepted bool `json:"keepLatestAccepted"`
+	// IntervalMinutes how often the policy is enforced
+	IntervalMinutes int `json:"intervalMinutes"`
+}
+
+// DefaultRetentionPolicy keeps everything, limits are enabled in the configuration
+func DefaultRetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{KeepBest: true, KeepLatestAccepted: true, IntervalMinutes: 60}
+}
+
+// Validate checks if the policy can be enforced
+func (p RetentionPolicy) Validate() error {
+	if p.MaxAgeDays < 0 || p.MaxPerProblem < 0 || p.MaxPerAuthor < 0 {
+		return errors.New("retention limits can't be negative")
+	}
+	if p.Enabled() && p.IntervalMinutes <= 0 {
+		return errors.New("retention interval must be positive")
+	}
+	return nil
+}
+
+// Enabled returns true if the policy may remove any submissions
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAgeDays > 0 || p.MaxPerProblem > 0 || p.MaxPerAuthor > 0
+}
+
+func allAccepted(m Metadata) bool {
+	return m.Status == AllTestsCompleted && m.TestCasesCount > 0 && m.AcceptedCount == m.TestCasesCount
+}
+
+// protected submissions kept by KeepBest and KeepLatestAccepted, submissions must be sorted the most recent first
+func (p RetentionPolicy) protected(submissions []Metadata) map[ID]bool {
+	type group struct{ problem, author string }
+	best := make(map[group]Metadata)
+	latestAccepted := make(map[group]Metadata)
+	for _, m := range submissions {
+		g := group{m.ProblemName, m.Author}
+		if m.Status == AllTestsCompleted && m.AcceptedCount > 0 {
+			if b, ok := best[g]; !ok || m.AcceptedCount > b.AcceptedCount {
+				best[g] = m
+			}
+		}
+		if _, ok := latestAccepted[g]; !ok && allAccepted(m) {
+			latestAccepted[g] = m
+		}
+	}
+	res := make(map[ID]bool)
+	if p.KeepBest {
+		for _, m := range best {
+			res[m.ID] = true
+		}
+	}
+	if p.KeepLatestAccepted {
+		for _, m := range latestAccepted {
+			res[m.ID] = true
+		}
+	}
+	return res
+}
+
+// Expired selects submissions which should be removed at the time now. Submissions whose results
+// are reused by submissions which are kept (see Metadata.ResultsFrom) are kept too.
+func (p RetentionPolicy) Expired(submissions []Metadata, now time.Time) []Metadata {
+	sorted := make([]Metadata, len(submissions))
+	copy(sorted, submissions)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].SubmittedAt.After(sorted[j].SubmittedAt) })
+
+	protected := p.protected(sorted)
+	perProblem := make(map[string]int)
+	perAuthor := make(map[string]int)
+	expired := make(map[ID]bool)
+	for _, m := range sorted {
+		perProblem[m.ProblemName]++
+		if m.Author != "" {
+			perAuthor[m.Author]++
+		}
+		if !m.Status.Final() || protected[m.ID] {
+			continue
+		}
+		if (p.MaxAgeDays > 0 && now.Sub(m.SubmittedAt) > time.Duration(p.MaxAgeDays)*24*time.Hour) ||
+			(p.MaxPerProblem > 0 && perProblem[m.ProblemName] > p.MaxPerProblem) ||
+			(p.MaxPerAuthor > 0 && m.Author != "" && perAuthor[m.Author] > p.MaxPerAuthor) {
+			expired[m.ID] = true
+		}
+	}
+	// ResultsFrom always refers to the submission which was judged, never to one which reused its results
+	for _, m := range sorted {
+		if m.ResultsFrom != nil && !expired[m.ID] {
+			delete(expired, *m.ResultsFrom)
+		}
+	}
+	res := make([]Metadata, 0)
+	for _, m := range sorted {
+		if expired[m.ID] {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// EnforceRetention removes submissions expired according to the policy, returns the number of removed ones.
+// Submissions which can't be removed are logged and skipped, the error reports how many of them there were.
+func EnforceRetention(store Storage, policy RetentionPolicy, now time.Time) (int, error) {
+	removed, failed := 0, 0
+	var firstErr error
+	for _, m := range policy.Expired(store.List(), now) {
+		if err := store.Remove(m.ID); err != nil {
+			log.Println("retention: unable to remove submission", m.ID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+			continue
+		}
+		removed++
+	}
+	if failed > 0 {
+		return removed, fmt.Errorf("unable to remove %d expired submissions, the first error: %v", failed, firstErr)
+	}
+	return removed, nil
+}
+
+// RunRetention enforces the policy every IntervalMinutes until quit is closed
+func RunRetention(store Storage, policy RetentionPolicy, quit <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(policy.IntervalMinutes) * time.Minute)
+	defer ticker.Stop()
+	for {
+		removed, err := EnforceRetention(store, policy, time.Now())
+		if err != nil {
+			log.Println("unable to enforce retention policy:", err)
+		}
+		if removed > 0 {
+			log.Printf("Removed %d submissions according to the retention policy\n", removed)
+		}
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
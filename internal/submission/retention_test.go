@@ -0,0 +1,155 @@
+package submission
+
+import (
+	"errors"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// retentionSubmissions submissions of "aProblem" submitted a day apart, the last one is the most recent
+func retentionSubmissions(count int, now time.Time) []Metadata {
+	submissions := testSubmissions(count)
+	for i := range submissions {
+		submissions[i].SubmittedAt = now.Add(-time.Duration(count-i) * 24 * time.Hour)
+		submissions[i].TestCasesCount = 4
+		submissions[i].AcceptedCount = 1
+	}
+	return submissions
+}
+
+func TestRetentionPolicy_Expired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		policy   RetentionPolicy
+		prepare  func(submissions []Metadata)
+		expected []int
+	}{
+		{"disabled", RetentionPolicy{}, nil, []int{}},
+		{"max age", RetentionPolicy{MaxAgeDays: 2}, nil, []int{2, 1, 0}},
+		{"max per problem", RetentionPolicy{MaxPerProblem: 2}, nil, []int{2, 1, 0}},
+		{"max per problem of each problem", RetentionPolicy{MaxPerProblem: 2}, func(s []Metadata) {
+			s[0].ProblemName = "other"
+		}, []int{2, 1}},
+		{"max per author", RetentionPolicy{MaxPerAuthor: 1}, func(s []Metadata) {
+			s[1].Author, s[3].Author, s[4].Author = "alice", "alice", "bob"
+		}, []int{1}},
+		{"in progress are kept", RetentionPolicy{MaxPerProblem: 2}, func(s []Metadata) {
+			s[0].Status = RunningTests
+			s[1].Status = Queued
+		}, []int{2}},
+		{"keep best", RetentionPolicy{MaxPerProblem: 1, KeepBest: true}, func(s []Metadata) {
+			s[1].AcceptedCount = 3
+			s[2].AcceptedCount = 3
+		}, []int{3, 1, 0}},
+		{"keep latest accepted", RetentionPolicy{MaxAgeDays: 1, KeepLatestAccepted: true}, func(s []Metadata) {
+			s[0].AcceptedCount = 4
+			s[2].AcceptedCount = 4
+		}, []int{3, 1, 0}},
+		{"keep per author", RetentionPolicy{MaxAgeDays: 1, KeepBest: true, KeepLatestAccepted: true}, func(s []Metadata) {
+			s[0].Author = "alice"
+			s[1].AcceptedCount = 4
+			s[2].Status = CompilationError
+			s[2].AcceptedCount = 0
+		}, []int{3, 2}},
+		{"keep results reused by kept submissions", RetentionPolicy{MaxPerProblem: 2}, func(s []Metadata) {
+			s[4].ResultsFrom = &s[0].ID
+			s[2].ResultsFrom = &s[1].ID
+		}, []int{2, 1}},
+	}
+	for _, tc := range tests {
+		submissions := retentionSubmissions(5, now)
+		if tc.prepare != nil {
+			tc.prepare(submissions)
+		}
+		expected := make([]ID, len(tc.expected))
+		for i, idx := range tc.expected {
+			expected[i] = submissions[idx].ID
+		}
+		assert.Equal(t, expected, ids(tc.policy.Expired(submissions, now)), tc.name)
+	}
+}
+
+func TestRetentionPolicy_Validate(t *testing.T) {
+	assert.NoError(t, DefaultRetentionPolicy().Validate())
+	assert.False(t, DefaultRetentionPolicy().Enabled())
+	assert.Error(t, RetentionPolicy{MaxAgeDays: -1}.Validate())
+	assert.Error(t, RetentionPolicy{MaxAgeDays: 1}.Validate())
+	assert.NoError(t, RetentionPolicy{MaxAgeDays: 1, IntervalMinutes: 10}.Validate())
+}
+
+func TestEnforceRetention_RemovesFiles(t *testing.T) {
+	now := time.Now()
+	submissions := retentionSubmissions(3, now)
+	hash := HashContent([]byte("int main() {}"))
+	for name, store := range newTestStorages(t) {
+		for _, m := range submissions {
+			assert.NoError(t, store.Upload(&m, strings.NewReader("int main() {}")))
+			assert.NoError(t, store.UploadArtifact(m, CoverageArtifact, strings.NewReader("{}")))
+		}
+		removed, err := EnforceRetention(store, RetentionPolicy{MaxPerProblem: 1}, now)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 2, removed, name)
+		assert.Equal(t, []ID{submissions[2].ID}, ids(store.List()), name)
+
+		var dataDir string
+		switch s := store.(type) {
+		case *defaultStorage:
+			dataDir = s.dataDirectory
+			assert.NoFileExists(t, path.Join(dataDir, submissions[0].ID.String()+metaFileExtension), name)
+		case *sqliteStorage:
+			dataDir = s.dataDirectory
+		}
+		files, err := ioutil.ReadDir(path.Join(dataDir, "aProblem"))
+		assert.NoError(t, err)
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		assert.Equal(t, []string{submissions[2].ID.String() + "." + CoverageArtifact}, names, name)
+		// the remaining submission still references the solution
+		assert.FileExists(t, path.Join(dataDir, BlobDirectory, hash[:2], hash), name)
+		store.Destroy()
+	}
+}
+
+// failingRemoveStorage can't remove one of the submissions
+type failingRemoveStorage struct {
+	Storage
+	unremovable ID
+}
+
+func (s *failingRemoveStorage) Remove(id ID) error {
+	if id == s.unremovable {
+		return errors.New("permission denied")
+	}
+	return s.Storage.Remove(id)
+}
+
+func TestEnforceRetention_SkipsSubmissionsWhichCantBeRemoved(t *testing.T) {
+	now := time.Now()
+	submissions := retentionSubmissions(4, now)
+	for name, store := range newTestStorages(t) {
+		for _, m := range submissions {
+			assert.NoError(t, store.Upload(&m, strings.NewReader("int main() {}")))
+		}
+		failing := &failingRemoveStorage{Storage: store, unremovable: submissions[2].ID}
+		removed, err := EnforceRetention(failing, RetentionPolicy{MaxPerProblem: 1}, now)
+		assert.EqualError(t, err, "unable to remove 1 expired submissions, the first error: permission denied", name)
+		assert.Equal(t, 2, removed, name)
+		assert.Equal(t, []ID{submissions[3].ID, submissions[2].ID}, ids(store.List()), name)
+		store.Destroy()
+	}
+}
+
+func TestStorage_RemoveUnknown(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		assert.Error(t, store.Remove(testSubmissions(1)[0].ID), name)
+		store.Destroy()
+	}
+}
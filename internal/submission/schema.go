@@ -0,0 +1,106 @@
+package submission
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// CurrentSchemaVersion version of the Metadata format written by Storage, see migrations
+const CurrentSchemaVersion = 1
+
+// ErrNewerSchemaVersion returned when metadata were written by a newer version of the server
+var ErrNewerSchemaVersion = errors.New("metadata schema version is newer than supported")
+
+// record JSON object of persisted Metadata, as decoded with json.Decoder.UseNumber
+type record map[string]interface{}
+
+// migration upgrades a record by one schema version
+type migration func(r record) error
+
+// migrations[v] upgrades records of version v to version v+1.
+// Formats must never be changed in place: rename or restructure fields in a new migration
+// and increase CurrentSchemaVersion, the tests keep a sample of every historical format.
+var migrations = []migration{
+	migrateUnversioned,
+}
+
+// migrateUnversioned upgrades records written before schema versions were introduced. The first of them
+// had no language, they were C++ submissions, and might have 0 workers on single-CPU machines.
+func migrateUnversioned(r record) error {
+	if language, _ := r["language"].(string); language == "" {
+		r["language"] = testcase.DefaultLanguageID
+	}
+	if workers, _ := r["workerCount"].(json.Number); workers == "" || workers == "0" {
+		r["workerCount"] = json.Number("1")
+	}
+	return nil
+}
+
+// DecodeMetadata decodes persisted metadata of any schema version, upgrading them to CurrentSchemaVersion
+func DecodeMetadata(data []byte) (Metadata, error) {
+	var metadata Metadata
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var r record
+	if err := dec.Decode(&r); err != nil {
+		return metadata, err
+	}
+	if r == nil {
+		return metadata, errors.New("metadata is not a JSON object")
+	}
+	version := 0
+	if v, ok := r["schemaVersion"]; ok {
+		number, ok := v.(json.Number)
+		parsed, err := number.Int64()
+		if !ok || err != nil || parsed < 0 {
+			return metadata, fmt.Errorf("invalid schema version %v", v)
+		}
+		version = int(parsed)
+	}
+	if version > CurrentSchemaVersion {
+		return metadata, fmt.Errorf("%w: %d > %d", ErrNewerSchemaVersion, version, CurrentSchemaVersion)
+	}
+	for ; version < CurrentSchemaVersion; version++ {
+		if err := migrations[version](r); err != nil {
+			return metadata, fmt.Errorf("migration from schema version %d: %v", version, err)
+		}
+	}
+	r["schemaVersion"] = CurrentSchemaVersion
+	upgraded, err := json.Marshal(r)
+	if err != nil {
+		return metadata, err
+	}
+	err = json.Unmarshal(upgraded, &metadata)
+	return metadata, err
+}
+
+// encodeMetadata marshals the metadata with the current schema version, indented if indent is set
+func encodeMetadata(metadata Metadata, indent bool) ([]byte, error) {
+	metadata.SchemaVersion = CurrentSchemaVersion
+	if indent {
+		return json.MarshalIndent(metadata, "", "\t")
+	}
+	return json.Marshal(metadata)
+}
+
+// UpgradeAll loads all submissions from the storage and saves them back in the current schema version.
+// Returns the number of rewritten submissions.
+func UpgradeAll(store Storage) (int, error) {
+	if err := store.Init(); err != nil {
+		return 0, err
+	}
+	if err := store.LoadAll(); err != nil {
+		return 0, err
+	}
+	submissions := store.List()
+	for i, metadata := range submissions {
+		if err := store.Save(metadata); err != nil {
+			return i, err
+		}
+	}
+	return len(submissions), nil
+}
@@ -0,0 +1,109 @@
+package submission
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+func readMetadataSample(t *testing.T, name string) []byte {
+	content, err := ioutil.ReadFile(path.Join("testdata", "metadata", name))
+	assert.NoError(t, err)
+	return content
+}
+
+func TestDecodeMetadata_HistoricalFormats(t *testing.T) {
+	baseline, err := DecodeMetadata(readMetadataSample(t, "v0_baseline.meta"))
+	assert.NoError(t, err)
+	assert.Equal(t, CurrentSchemaVersion, baseline.SchemaVersion)
+	assert.Equal(t, "0b5d7e43-5f3c-4c86-9a2e-53a8b0f0c001", baseline.ID.String())
+	assert.Equal(t, testcase.DefaultLanguageID, baseline.Language)
+	assert.Equal(t, 1, baseline.WorkerCount)
+	assert.Equal(t, testcase.AnalyzeClangMode, baseline.CompilationMode)
+	assert.Equal(t, AllTestsCompleted, baseline.Status)
+	assert.Equal(t, 912345678*time.Nanosecond, baseline.TotalProcessingTime)
+	assert.Equal(t, 5*time.Second, baseline.CompletedTestCases[0].Info.TimeLimit)
+	assert.Equal(t, testcase.Accepted, baseline.CompletedTestCases[0].Result.Status)
+	assert.Equal(t, 123456789, baseline.SubmittedAt.Nanosecond())
+
+	languages, err := DecodeMetadata(readMetadataSample(t, "v0_languages.meta"))
+	assert.NoError(t, err)
+	assert.Equal(t, "python3", languages.Language)
+	assert.Equal(t, testcase.ArchiveFormat("zip"), languages.SourceArchive)
+	assert.Equal(t, "src/main.py", languages.EntryFile)
+	assert.Equal(t, CompilationTimeout, languages.Status)
+	assert.Equal(t, "compilation aborted", string(languages.CompilationOutput))
+	assert.Equal(t, 4, languages.WorkerCount)
+
+	current, err := DecodeMetadata(readMetadataSample(t, "v1.meta"))
+	assert.NoError(t, err)
+	assert.Equal(t, "cpp17", current.Language)
+	assert.Equal(t, "alice", current.Author)
+	assert.Equal(t, testcase.CoverageMode, current.CompilationMode)
+	assert.Equal(t, 5, current.Coverage.LinesCovered)
+}
+
+func TestDecodeMetadata_RoundTrip(t *testing.T) {
+	m := NewMetadata("problem", cpp, testcase.ReleaseMode)
+	m.Status = AllTestsCompleted
+	m.Author = "bob"
+	content, err := encodeMetadata(m, false)
+	assert.NoError(t, err)
+	decoded, err := DecodeMetadata(content)
+	assert.NoError(t, err)
+	assert.Equal(t, m.ID, decoded.ID)
+	assert.True(t, m.SubmittedAt.Equal(decoded.SubmittedAt))
+	m.SubmittedAt = decoded.SubmittedAt
+	assert.Equal(t, m, decoded)
+}
+
+func TestDecodeMetadata_Invalid(t *testing.T) {
+	_, err := DecodeMetadata([]byte(`{"schemaVersion": 99, "id": "0b5d7e43-5f3c-4c86-9a2e-53a8b0f0c001"}`))
+	assert.True(t, errors.Is(err, ErrNewerSchemaVersion))
+	_, err = DecodeMetadata([]byte(`{"schemaVersion": "one"}`))
+	assert.EqualError(t, err, "invalid schema version one")
+	_, err = DecodeMetadata([]byte(`null`))
+	assert.Error(t, err)
+	_, err = DecodeMetadata([]byte(`{"status": "Renamed"}`))
+	assert.Error(t, err)
+}
+
+func TestUpgradeAll(t *testing.T) {
+	tmpstoragedir := "tmpstoragedir"
+	defer os.RemoveAll(tmpstoragedir)
+	assert.NoError(t, os.MkdirAll(tmpstoragedir, 0755))
+	for _, sample := range []string{"v0_baseline.meta", "v0_languages.meta", "v1.meta"} {
+		metadata, err := DecodeMetadata(readMetadataSample(t, sample))
+		assert.NoError(t, err)
+		filename := path.Join(tmpstoragedir, metadata.ID.String()+metaFileExtension)
+		assert.NoError(t, ioutil.WriteFile(filename, readMetadataSample(t, sample), 0644))
+	}
+	newer := "0b5d7e43-5f3c-4c86-9a2e-53a8b0f0c004" + metaFileExtension
+	assert.NoError(t, ioutil.WriteFile(path.Join(tmpstoragedir, newer),
+		[]byte(`{"schemaVersion": 99, "id": "0b5d7e43-5f3c-4c86-9a2e-53a8b0f0c004"}`), 0644))
+
+	upgraded, err := UpgradeAll(NewDefaultStorage(tmpstoragedir))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, upgraded)
+
+	files, err := ioutil.ReadDir(tmpstoragedir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 4)
+	for _, f := range files {
+		content := readFile(t, path.Join(tmpstoragedir, f.Name()))
+		if f.Name() == newer {
+			// records of newer versions are left intact
+			assert.Contains(t, content, `"schemaVersion": 99`)
+			continue
+		}
+		assert.True(t, strings.HasPrefix(content, "{\n\t\"schemaVersion\": 1,"), f.Name())
+		assert.Contains(t, content, `"language": "`)
+	}
+}
@@ -0,0 +1,96 @@
+package submission
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Artifacts of submissions, and solutions stored before the blob store, are kept as files
+// in dataDir/<problem name>/ by every Storage
+
+// openSolution opens a solution stored before the blob store was introduced, as <problem>/<SolutionFilename>
+func openSolution(dataDir string, meta Metadata) (io.ReadCloser, error) {
+	solutionFile, err := os.Open(path.Join(dataDir, meta.ProblemName, meta.SolutionFilename))
+	if err != nil {
+		return nil, err
+	}
+	return solutionFile, nil
+}
+
+func artifactPath(dataDir string, meta Metadata, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid artifact name '%s'", name)
+	}
+	return path.Join(dataDir, meta.ProblemName, meta.ID.String()+"."+name), nil
+}
+
+func writeArtifact(dataDir string, meta Metadata, name string, content io.Reader) error {
+	artifactPath, err := artifactPath(dataDir, meta, name)
+	if err != nil {
+		return err
+	}
+	if err = ensureDirectoryExists(path.Dir(artifactPath)); err != nil {
+		return err
+	}
+	f, err := os.Create(artifactPath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// listArtifacts names of all artifacts of the submission
+func listArtifacts(dataDir string, meta Metadata) ([]string, error) {
+	files, err := ioutil.ReadDir(path.Join(dataDir, meta.ProblemName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	prefix := meta.ID.String() + "."
+	for _, f := range files {
+		if f.Name() != meta.SolutionFilename && strings.HasPrefix(f.Name(), prefix) && !isTempFile(f.Name()) {
+			res = append(res, strings.TrimPrefix(f.Name(), prefix))
+		}
+	}
+	return res, nil
+}
+
+// removeSolutionFiles removes the solution and all artifacts of the submission
+func removeSolutionFiles(dataDir string, meta Metadata) error {
+	dir := path.Join(dataDir, meta.ProblemName)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	prefix := meta.ID.String() + "."
+	for _, f := range files {
+		if f.Name() == meta.SolutionFilename || strings.HasPrefix(f.Name(), prefix) {
+			if err = os.Remove(path.Join(dir, f.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func openArtifact(dataDir string, meta Metadata, name string) (io.ReadCloser, error) {
+	artifactPath, err := artifactPath(dataDir, meta, name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(artifactPath)
+}
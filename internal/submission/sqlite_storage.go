@@ -0,0 +1,359 @@
+package submission
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	// registers the "sqlite3" database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDatabaseFilename name of the database file in the data directory of the SQLite storage
+const SQLiteDatabaseFilename = "submissions.db"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS submissions (
+	id               TEXT PRIMARY KEY,
+	problem_name     TEXT NOT NULL,
+	status           TEXT NOT NULL,
+	language         TEXT NOT NULL,
+	submitted_at     INTEGER NOT NULL,
+	metadata         TEXT NOT NULL,
+	compilation_mode TEXT NOT NULL,
+	author           TEXT NOT NULL,
+	solution_hash    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS submissions_problem_name ON submissions (problem_name, submitted_at);
+CREATE INDEX IF NOT EXISTS submissions_status ON submissions (status, submitted_at);
+CREATE INDEX IF NOT EXISTS submissions_submitted_at ON submissions (submitted_at);
+CREATE INDEX IF NOT EXISTS submissions_compilation_mode ON submissions (compilation_mode, submitted_at);
+CREATE INDEX IF NOT EXISTS submissions_author ON submissions (author, submitted_at);
+CREATE INDEX IF NOT EXISTS submissions_solution_hash ON submissions (solution_hash, problem_name);
+`
+
+// sqliteStorage keeps metadata in an SQLite database and solutions in the blob store, like defaultStorage.
+// Only submissions changed with Update, which are being processed, are kept in memory.
+// References to blobs are counted by the database.
+type sqliteStorage struct {
+	watchers
+
+	dataDirectory string
+	db            *sql.DB
+	blobs         blobStore
+
+	// updated submissions which weren't saved yet, by ID
+	updated map[string]Metadata
+	m       sync.Mutex
+}
+
+// NewSQLiteStorage constructor of the Storage which keeps metadata in SQLiteDatabaseFilename in dataDir
+func NewSQLiteStorage(dataDir string) Storage {
+	return &sqliteStorage{
+		dataDirectory: dataDir,
+		blobs:         newBlobStore(dataDir),
+		updated:       make(map[string]Metadata),
+	}
+}
+
+func (store *sqliteStorage) Init() error {
+	if err := ensureDirectoryExists(store.dataDirectory); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", path.Join(store.dataDirectory, SQLiteDatabaseFilename)+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return err
+	}
+	// SQLite allows a single writer anyway, one connection avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return err
+	}
+	store.db = db
+	return nil
+}
+
+func (store *sqliteStorage) Destroy() error {
+	if store.db != nil {
+		store.db.Close()
+	}
+	return os.RemoveAll(store.dataDirectory)
+}
+
+func (store *sqliteStorage) Upload(meta *Metadata, solution io.Reader) error {
+	store.m.Lock()
+	defer store.m.Unlock()
+	hash, err := store.blobs.Put(solution)
+	if err != nil {
+		return err
+	}
+	meta.SolutionHash = hash
+	if err = insertSubmission(store.db, *meta); err != nil {
+		return err
+	}
+	delete(store.updated, meta.ID.String())
+	store.notify(ChangeSaved, *meta)
+	return nil
+}
+
+func (store *sqliteStorage) Download(meta Metadata) (io.ReadCloser, error) {
+	if meta.SolutionHash != "" {
+		return store.blobs.Open(meta.SolutionHash)
+	}
+	return openSolution(store.dataDirectory, meta)
+}
+
+func (store *sqliteStorage) UploadArtifact(meta Metadata, name string, content io.Reader) error {
+	return writeArtifact(store.dataDirectory, meta, name, content)
+}
+
+func (store *sqliteStorage) DownloadArtifact(meta Metadata, name string) (io.ReadCloser, error) {
+	return openArtifact(store.dataDirectory, meta, name)
+}
+
+func (store *sqliteStorage) ListArtifacts(meta Metadata) ([]string, error) {
+	return listArtifacts(store.dataDirectory, meta)
+}
+
+// insertSubmission writes the metadata with db, which is the database or a transaction
+func insertSubmission(db interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, metadata Metadata) error {
+	content, err := encodeMetadata(metadata, false)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO submissions
+		(id, problem_name, status, language, submitted_at, metadata, compilation_mode, author, solution_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		metadata.ID.String(), metadata.ProblemName, metadata.Status.String(), metadata.Language,
+		metadata.SubmittedAt.UnixNano(), string(content), string(metadata.CompilationMode), metadata.Author,
+		metadata.SolutionHash)
+	return err
+}
+
+func (store *sqliteStorage) Save(metadata Metadata) error {
+	store.m.Lock()
+	defer store.m.Unlock()
+	if err := insertSubmission(store.db, metadata); err != nil {
+		return err
+	}
+	delete(store.updated, metadata.ID.String())
+	store.notify(ChangeSaved, metadata)
+	return nil
+}
+
+func (store *sqliteStorage) Update(metadata Metadata) {
+	store.m.Lock()
+	defer store.m.Unlock()
+	store.updated[metadata.ID.String()] = metadata
+	store.notify(ChangeUpdated, metadata)
+}
+
+func (store *sqliteStorage) Get(id ID) (Metadata, bool) {
+	store.m.Lock()
+	defer store.m.Unlock()
+	if metadata, ok := store.updated[id.String()]; ok {
+		return metadata.clone(), true
+	}
+	var content string
+	err := store.db.QueryRow(`SELECT metadata FROM submissions WHERE id = ?`, id.String()).Scan(&content)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("unable to read submission", id, err)
+		}
+		return Metadata{}, false
+	}
+	metadata, err := DecodeMetadata([]byte(content))
+	if err != nil {
+		log.Println("unable to decode submission", id, err)
+		return Metadata{}, false
+	}
+	return metadata, true
+}
+
+func (store *sqliteStorage) Remove(id ID) error {
+	store.m.Lock()
+	defer store.m.Unlock()
+	submissions, err := selectSubmissions(store.db, `SELECT metadata FROM submissions WHERE id = ?`, id.String())
+	if err != nil {
+		return err
+	}
+	if len(submissions) == 0 {
+		return fmt.Errorf("submission %s not found", id)
+	}
+	// the row goes last, so the files are not orphaned if their removal fails
+	if err = removeSolutionFiles(store.dataDirectory, submissions[0]); err != nil {
+		return err
+	}
+	if _, err = store.db.Exec(`DELETE FROM submissions WHERE id = ?`, id.String()); err != nil {
+		return err
+	}
+	removed := store.withUpdates(submissions)[0]
+	delete(store.updated, id.String())
+	store.notify(ChangeRemoved, removed)
+	hash := removed.SolutionHash
+	if hash == "" {
+		return nil
+	}
+	var refs int
+	if err = store.db.QueryRow(`SELECT COUNT(*) FROM submissions WHERE solution_hash = ?`, hash).Scan(&refs); err != nil {
+		return err
+	}
+	if refs == 0 {
+		return store.blobs.Remove(hash)
+	}
+	return nil
+}
+
+// List all submissions, the most recent first
+func (store *sqliteStorage) List() []Metadata {
+	store.m.Lock()
+	defer store.m.Unlock()
+	res, err := selectSubmissions(store.db, `SELECT metadata FROM submissions ORDER BY submitted_at DESC`)
+	if err != nil {
+		log.Println("unable to list submissions", err)
+	}
+	return store.withUpdates(res)
+}
+
+// Query runs the query in the database, updated submissions replace the stored ones but are
+// filtered by the stored values
+func (store *sqliteStorage) Query(q Query) (Page, error) {
+	if err := q.Validate(); err != nil {
+		return Page{}, err
+	}
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, values ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+	if q.ProblemName != "" {
+		where("problem_name = ?", q.ProblemName)
+	}
+	if q.Status != 0 {
+		where("status = ?", q.Status.String())
+	}
+	if q.CompilationMode != "" {
+		where("compilation_mode = ?", string(q.CompilationMode))
+	}
+	if q.Author != "" {
+		where("author = ?", q.Author)
+	}
+	if q.Language != "" {
+		where("language = ?", q.Language)
+	}
+	if q.SolutionHash != "" {
+		where("solution_hash = ?", q.SolutionHash)
+	}
+	if !q.SubmittedAfter.IsZero() {
+		where("submitted_at >= ?", q.SubmittedAfter.UnixNano())
+	}
+	if !q.SubmittedBefore.IsZero() {
+		where("submitted_at < ?", q.SubmittedBefore.UnixNano())
+	}
+	order, comparison := "DESC", "<"
+	if q.Oldest {
+		order, comparison = "ASC", ">"
+	}
+	if after, _ := q.cursor(); after != nil {
+		where("(submitted_at "+comparison+" ? OR (submitted_at = ? AND id "+comparison+" ?))",
+			after.submittedAt, after.submittedAt, after.id)
+	}
+	query := `SELECT metadata FROM submissions`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(` ORDER BY submitted_at %s, id %s LIMIT %d`, order, order, q.limit()+1)
+
+	store.m.Lock()
+	defer store.m.Unlock()
+	res, err := selectSubmissions(store.db, query, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	return newPage(store.withUpdates(res), q.limit()), nil
+}
+
+// withUpdates replaces submissions with their updated versions, must be called with the lock held
+func (store *sqliteStorage) withUpdates(submissions []Metadata) []Metadata {
+	for i, metadata := range submissions {
+		if updated, ok := store.updated[metadata.ID.String()]; ok {
+			submissions[i] = updated.clone()
+		}
+	}
+	return submissions
+}
+
+// selectSubmissions decodes metadata selected by the query, undecodable rows are logged and skipped
+func selectSubmissions(db *sql.DB, query string, args ...interface{}) ([]Metadata, error) {
+	res := make([]Metadata, 0)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var content string
+		var metadata Metadata
+		if err = rows.Scan(&content); err == nil {
+			metadata, err = DecodeMetadata([]byte(content))
+		}
+		if err != nil {
+			log.Println("unable to read submission", err)
+			continue
+		}
+		res = append(res, metadata)
+	}
+	return res, rows.Err()
+}
+
+// LoadAll nothing to load, metadata are read from the database when needed
+func (store *sqliteStorage) LoadAll() error {
+	var count int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM submissions`).Scan(&count); err != nil {
+		return err
+	}
+	log.Printf("%d submissions in the database\n", count)
+	return nil
+}
+
+// MigrateToSQLite copies metadata of all submissions stored by the default Storage in dataDir into
+// the SQLite storage in the same directory. Solutions are already in place, .meta files are left intact.
+// Returns the number of migrated submissions.
+func MigrateToSQLite(dataDir string) (int, error) {
+	src := NewDefaultStorage(dataDir)
+	if err := src.Init(); err != nil {
+		return 0, err
+	}
+	if err := src.LoadAll(); err != nil {
+		return 0, err
+	}
+	dst := NewSQLiteStorage(dataDir).(*sqliteStorage)
+	if err := dst.Init(); err != nil {
+		return 0, err
+	}
+	defer dst.db.Close()
+	tx, err := dst.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	submissions := src.List()
+	for _, metadata := range submissions {
+		if err = insertSubmission(tx, metadata); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(submissions), nil
+}
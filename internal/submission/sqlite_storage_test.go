@@ -0,0 +1,144 @@
+package submission
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	guuid "github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+func newTestSQLiteStorage(t *testing.T) (Storage, string) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "testsqlite-*")
+	assert.NoError(t, err)
+	store := NewSQLiteStorage(dirname)
+	assert.NoError(t, store.Init())
+	return store, dirname
+}
+
+func testSubmissions(count int) []Metadata {
+	res := make([]Metadata, count)
+	now := time.Now()
+	for i := range res {
+		res[i] = Metadata{
+			ID:                 ID(guuid.New()),
+			SubmittedAt:        now.Add(time.Duration(i) * time.Second),
+			SolutionFilename:   fmt.Sprintf("sol%d.cpp", i),
+			Language:           testcase.DefaultLanguageID,
+			Status:             AllTestsCompleted,
+			ProblemName:        "aProblem",
+			ExecutableFilename: fmt.Sprintf("a%d.tsk", i),
+			CompilationMode:    testcase.ReleaseMode,
+		}
+	}
+	return res
+}
+
+func TestSQLiteStorage_UploadSaveGet(t *testing.T) {
+	store, dirname := newTestSQLiteStorage(t)
+	defer store.Destroy()
+
+	m := NewMetadata("testproblem", cpp, testcase.ReleaseMode)
+	assert.NoError(t, store.Upload(&m, strings.NewReader("this is a solution")))
+	solution, err := store.Download(m)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadAll(solution)
+	solution.Close()
+	assert.Equal(t, "this is a solution", string(content))
+	assert.FileExists(t, dirname+"/"+SQLiteDatabaseFilename)
+
+	m.Status = AllTestsCompleted
+	m.CompletedTestCases = []testcase.CompletedTestCase{{Info: testcase.Info{Name: "t1"}, Result: testcase.Result{Status: testcase.Accepted}}}
+	assert.NoError(t, store.Save(m))
+	res, ok := store.Get(m.ID)
+	assert.True(t, ok)
+	assert.Equal(t, m.ID, res.ID)
+	assert.Equal(t, AllTestsCompleted, res.Status)
+	assert.Equal(t, "t1", res.CompletedTestCases[0].Info.Name)
+
+	_, ok = store.Get(ID(guuid.New()))
+	assert.False(t, ok)
+}
+
+func TestSQLiteStorage_UpdateIsNotPersisted(t *testing.T) {
+	store, dirname := newTestSQLiteStorage(t)
+	defer store.Destroy()
+	m := testSubmissions(1)[0]
+	m.Status = Queued
+	assert.NoError(t, store.Save(m))
+
+	m.Status = RunningTests
+	store.Update(m)
+	res, _ := store.Get(m.ID)
+	assert.Equal(t, RunningTests, res.Status)
+	assert.Equal(t, RunningTests, store.List()[0].Status)
+
+	reopened := NewSQLiteStorage(dirname)
+	assert.NoError(t, reopened.Init())
+	res, _ = reopened.Get(m.ID)
+	assert.Equal(t, Queued, res.Status)
+
+	m.Status = AllTestsCompleted
+	assert.NoError(t, store.Save(m))
+	res, _ = reopened.Get(m.ID)
+	assert.Equal(t, AllTestsCompleted, res.Status)
+}
+
+func TestSQLiteStorage_ListRemove(t *testing.T) {
+	store, _ := newTestSQLiteStorage(t)
+	defer store.Destroy()
+	submissions := testSubmissions(3)
+	for _, m := range submissions {
+		assert.NoError(t, store.Save(m))
+	}
+	assert.NoError(t, store.LoadAll())
+
+	list := store.List()
+	assert.Equal(t, 3, len(list))
+	assert.Equal(t, "sol2.cpp", list[0].SolutionFilename)
+	assert.Equal(t, "sol1.cpp", list[1].SolutionFilename)
+	assert.Equal(t, "sol0.cpp", list[2].SolutionFilename)
+
+	assert.NoError(t, store.Remove(submissions[1].ID))
+	list = store.List()
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, "sol2.cpp", list[0].SolutionFilename)
+	assert.Equal(t, "sol0.cpp", list[1].SolutionFilename)
+}
+
+func TestMigrateToSQLite(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "testmigrate-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dirname)
+	files := NewDefaultStorage(dirname)
+	assert.NoError(t, files.Init())
+	submissions := testSubmissions(5)
+	for i := range submissions {
+		assert.NoError(t, files.Upload(&submissions[i], strings.NewReader("int main() {}")))
+	}
+
+	migrated, err := MigrateToSQLite(dirname)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, migrated)
+	// migrating again doesn't duplicate submissions
+	migrated, err = MigrateToSQLite(dirname)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, migrated)
+
+	store := NewSQLiteStorage(dirname)
+	assert.NoError(t, store.Init())
+	list := store.List()
+	assert.Equal(t, 5, len(list))
+	for i, m := range files.List() {
+		assert.Equal(t, m.ID, list[i].ID)
+		assert.True(t, m.SubmittedAt.Equal(list[i].SubmittedAt))
+	}
+	solution, err := store.Download(submissions[3])
+	assert.NoError(t, err)
+	solution.Close()
+}
@@ -13,11 +13,13 @@ func _() {
 	_ = x[CompilationError-3]
 	_ = x[RunningTests-4]
 	_ = x[AllTestsCompleted-5]
+	_ = x[CompilationTimeout-6]
+	_ = x[InternalError-7]
 }
 
-const _Status_name = "QueuedCompilingCompilationErrorRunningTestsAllTestsCompleted"
+const _Status_name = "QueuedCompilingCompilationErrorRunningTestsAllTestsCompletedCompilationTimeoutInternalError"
 
-var _Status_index = [...]uint8{0, 6, 15, 31, 43, 60}
+var _Status_index = [...]uint8{0, 6, 15, 31, 43, 60, 78, 91}
 
 func (i Status) String() string {
 	i -= 1
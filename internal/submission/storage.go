@@ -1,7 +1,8 @@
 package submission
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -10,27 +11,54 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
+// QuarantineDirectory directory in the data directory where LoadAll moves unreadable metadata files
+const QuarantineDirectory = ".quarantine"
+
 // Storage Persistent storage for Submissions
 type Storage interface {
 	Init() error
 	Destroy() error // !! destroys all the data stored !!
 
-	Upload(meta Metadata, solution io.Reader) error
+	// Upload stores the solution in the blob store, sets meta.SolutionHash and saves the metadata
+	Upload(meta *Metadata, solution io.Reader) error
 	Download(meta Metadata) (solution io.ReadCloser, err error)
+	// UploadArtifact stores a file produced while processing the submission, e.g. a coverage report
+	UploadArtifact(meta Metadata, name string, content io.Reader) error
+	DownloadArtifact(meta Metadata, name string) (content io.ReadCloser, err error)
+	// ListArtifacts names of all artifacts stored for the submission
+	ListArtifacts(meta Metadata) ([]string, error)
 
 	Save(Metadata) error
+	// Update changes in-memory view of the submission without persisting it
+	Update(Metadata)
 	Get(id ID) (Metadata, bool)
+	// Remove deletes metadata, the solution and artifacts of the submission
 	Remove(id ID) error
 
 	List() []Metadata
+	// Query returns a page of submissions matching the query
+	Query(q Query) (Page, error)
 	LoadAll() error
+
+	// Watch returns a channel with all changes of submissions from now on, changes are dropped for watchers
+	// which don't keep up. stop must be called when the watcher is no longer interested.
+	Watch() (changes <-chan Change, stop func())
 }
 
+// Metadata returned by a Storage are copies, which can be read and modified without synchronization.
+// Metadata passed to Save and Update must not be modified afterwards.
+
 // SubmissionStorage object holding data about submissions
 type defaultStorage struct {
+	watchers
+
 	data map[string]Metadata
+	// refs number of submissions referencing each blob
+	refs  map[string]int
+	blobs blobStore
 
 	dataDirectory string
 	m             sync.Mutex
@@ -40,6 +68,8 @@ type defaultStorage struct {
 func NewDefaultStorage(dataDir string) Storage {
 	return &defaultStorage{
 		data:          make(map[string]Metadata, 0),
+		refs:          make(map[string]int),
+		blobs:         newBlobStore(dataDir),
 		dataDirectory: dataDir,
 	}
 }
@@ -49,48 +79,77 @@ func (store *defaultStorage) Init() error {
 }
 
 // Upload new SubmissionMetadata object with unique ID
-func (store *defaultStorage) Upload(meta Metadata, solution io.Reader) error {
-	solutionsDirectory := path.Join(store.dataDirectory, meta.ProblemName)
-	if err := ensureDirectoryExists(solutionsDirectory); err != nil {
-		return err
-	}
-
-	submittedSolutionFile, err := os.Create(path.Join(solutionsDirectory, meta.SolutionFilename))
-	if err != nil {
-		return err
-	}
-	defer submittedSolutionFile.Close()
-
-	_, err = io.Copy(submittedSolutionFile, solution)
+func (store *defaultStorage) Upload(meta *Metadata, solution io.Reader) error {
+	store.m.Lock()
+	defer store.m.Unlock()
+	hash, err := store.blobs.Put(solution)
 	if err != nil {
 		return err
 	}
-	if err = store.Save(meta); err != nil {
-		return err
-	}
-	return nil
+	meta.SolutionHash = hash
+	return store.save(*meta)
 }
 
 func (store *defaultStorage) Download(meta Metadata) (solution io.ReadCloser, err error) {
-	solutionFile, err := os.Open(path.Join(store.dataDirectory, meta.ProblemName, meta.SolutionFilename))
-	if err != nil {
-		return nil, err
+	if meta.SolutionHash != "" {
+		return store.blobs.Open(meta.SolutionHash)
 	}
-	return solutionFile, nil
+	return openSolution(store.dataDirectory, meta)
+}
+
+func (store *defaultStorage) UploadArtifact(meta Metadata, name string, content io.Reader) error {
+	return writeArtifact(store.dataDirectory, meta, name, content)
+}
+
+func (store *defaultStorage) DownloadArtifact(meta Metadata, name string) (io.ReadCloser, error) {
+	return openArtifact(store.dataDirectory, meta, name)
+}
+
+func (store *defaultStorage) ListArtifacts(meta Metadata) ([]string, error) {
+	return listArtifacts(store.dataDirectory, meta)
 }
 
 func (store *defaultStorage) Save(metadata Metadata) error {
 	store.m.Lock()
 	defer store.m.Unlock()
-	store.data[metadata.ID.String()] = metadata
-	metadataFile, err := os.Create(path.Join(store.dataDirectory, metadata.ID.String()+metaFileExtension))
+	return store.save(metadata)
+}
+
+// put replaces the submission in memory and counts references to its solution, must be called with the lock held
+func (store *defaultStorage) put(metadata Metadata) {
+	id := metadata.ID.String()
+	if old, found := store.data[id]; found && old.SolutionHash != "" {
+		store.refs[old.SolutionHash]--
+	}
+	if metadata.SolutionHash != "" {
+		store.refs[metadata.SolutionHash]++
+	}
+	store.data[id] = metadata
+}
+
+// save must be called with the lock held
+func (store *defaultStorage) save(metadata Metadata) error {
+	content, err := encodeMetadata(metadata, true)
 	if err != nil {
 		return err
 	}
-	defer metadataFile.Close()
-	enc := json.NewEncoder(metadataFile)
-	enc.SetIndent("", "\t")
-	return enc.Encode(metadata)
+	store.put(metadata)
+	err = writeFileAtomic(path.Join(store.dataDirectory, metadata.ID.String()+metaFileExtension), func(w io.Writer) error {
+		_, err := w.Write(append(content, '\n'))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	store.notify(ChangeSaved, metadata)
+	return nil
+}
+
+func (store *defaultStorage) Update(metadata Metadata) {
+	store.m.Lock()
+	defer store.m.Unlock()
+	store.put(metadata)
+	store.notify(ChangeUpdated, metadata)
 }
 
 // ByTimestamp is a helper type to implement sorting
@@ -105,21 +164,54 @@ func (store *defaultStorage) List() []Metadata {
 	defer store.m.Unlock()
 	res := make([]Metadata, 0)
 	for _, elem := range store.data {
-		res = append(res, elem)
+		res = append(res, elem.clone())
 	}
 	sort.Sort(ByTimestamp(res))
 	return res
 }
 
+func (store *defaultStorage) Query(q Query) (Page, error) {
+	store.m.Lock()
+	defer store.m.Unlock()
+	all := make([]Metadata, 0, len(store.data))
+	for _, elem := range store.data {
+		all = append(all, elem)
+	}
+	page, err := queryInMemory(all, q)
+	for i := range page.Submissions {
+		page.Submissions[i] = page.Submissions[i].clone()
+	}
+	return page, err
+}
+
 func (store *defaultStorage) Remove(id ID) error {
 	store.m.Lock()
 	defer store.m.Unlock()
+	metadata, found := store.data[id.String()]
+	if !found {
+		return fmt.Errorf("submission %s not found", id)
+	}
+	// metadata go last, so the files are not orphaned if their removal fails
+	if err := removeSolutionFiles(store.dataDirectory, metadata); err != nil {
+		return err
+	}
+	if err := os.Remove(path.Join(store.dataDirectory, id.String()+metaFileExtension)); err != nil {
+		return err
+	}
 	delete(store.data, id.String())
-	return os.Remove(path.Join(store.dataDirectory, id.String()+metaFileExtension))
+	store.notify(ChangeRemoved, metadata)
+	if hash := metadata.SolutionHash; hash != "" {
+		if store.refs[hash]--; store.refs[hash] <= 0 {
+			delete(store.refs, hash)
+			return store.blobs.Remove(hash)
+		}
+	}
+	return nil
 }
 
+// LoadAll loads all .meta files into memory. Files which can't be decoded are moved into QuarantineDirectory
+// and logged, temporary files left by interrupted saves are removed.
 func (store *defaultStorage) LoadAll() error {
-
 	files, err := ioutil.ReadDir(store.dataDirectory)
 	if err != nil {
 		return err
@@ -127,34 +219,83 @@ func (store *defaultStorage) LoadAll() error {
 	store.m.Lock()
 	defer store.m.Unlock()
 
+	quarantined := 0
 	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		filename := path.Join(store.dataDirectory, f.Name())
+		if isTempFile(f.Name()) {
+			log.Println("removing temporary file of an interrupted save:", filename)
+			if err = os.Remove(filename); err != nil {
+				return err
+			}
+			continue
+		}
 		if !strings.HasSuffix(f.Name(), metaFileExtension) {
 			continue
 		}
-		metadataFile, err := os.Open(path.Join(store.dataDirectory, f.Name()))
-		if err != nil {
-			return err
+		metadata, err := readMetadataFile(filename)
+		if errors.Is(err, ErrNewerSchemaVersion) {
+			log.Printf("skipping %s: %v\n", filename, err)
+			continue
 		}
-		defer metadataFile.Close()
-		var metadata Metadata
-		if err = json.NewDecoder(metadataFile).Decode(&metadata); err != nil {
-			log.Println("Json deconding failed")
-			return err
+		if err != nil {
+			log.Printf("unable to read %s, moving it into %s: %v\n", filename, QuarantineDirectory, err)
+			if err = quarantine(store.dataDirectory, f.Name()); err != nil {
+				return err
+			}
+			quarantined++
+			continue
 		}
-		store.data[metadata.ID.String()] = metadata
+		store.put(metadata)
 	}
 	log.Printf("Loaded %d submissions into memory\n", len(store.data))
+	if quarantined > 0 {
+		log.Printf("%d unreadable metadata files were moved into %s\n", quarantined, path.Join(store.dataDirectory, QuarantineDirectory))
+	}
 	return nil
 }
 
+// readMetadataFile decodes the .meta file and checks that it's named after the submission
+func readMetadataFile(filename string) (Metadata, error) {
+	var metadata Metadata
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return metadata, err
+	}
+	if metadata, err = DecodeMetadata(content); err != nil {
+		return metadata, err
+	}
+	if expected := metadata.ID.String() + metaFileExtension; path.Base(filename) != expected {
+		return metadata, fmt.Errorf("metadata of submission %s in a file which should be named %s", metadata.ID, expected)
+	}
+	return metadata, nil
+}
+
+// quarantine moves the file from dataDir into QuarantineDirectory, keeping the file which is already there
+func quarantine(dataDir, name string) error {
+	dir := path.Join(dataDir, QuarantineDirectory)
+	if err := ensureDirectoryExists(dir); err != nil {
+		return err
+	}
+	target := path.Join(dir, name)
+	if _, err := os.Stat(target); err == nil {
+		target = fmt.Sprintf("%s.%d", target, time.Now().UnixNano())
+	}
+	return os.Rename(path.Join(dataDir, name), target)
+}
+
 func (store *defaultStorage) Destroy() error {
 	return os.RemoveAll(store.dataDirectory)
 }
 
 func (store *defaultStorage) Get(id ID) (Metadata, bool) {
+	store.m.Lock()
+	defer store.m.Unlock()
 	v, found := store.data[id.String()]
 	if !found {
 		return Metadata{}, false
 	}
-	return v, true
+	return v.clone(), true
 }
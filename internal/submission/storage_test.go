@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -20,6 +21,15 @@ func readFile(t *testing.T, filename string) string {
 	return string(res)
 }
 
+// newTestDefaultStorage initialized default storage in a new temporary directory, which the caller removes
+func newTestDefaultStorage(t *testing.T) (Storage, string) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "teststorage-*")
+	assert.NoError(t, err)
+	sp := NewDefaultStorage(dirname)
+	assert.NoError(t, sp.Init())
+	return sp, dirname
+}
+
 func TestDefaultStorage_Upload(t *testing.T) {
 	tmpstoragedir := "tmpstoragedir"
 	defer os.RemoveAll(tmpstoragedir)
@@ -28,12 +38,13 @@ func TestDefaultStorage_Upload(t *testing.T) {
 	assert.NoError(t, sp.Init())
 
 	content := "this is a solution"
-	m := NewMetadata("testproblem", testcase.ReleaseMode)
-	err := sp.Upload(m, strings.NewReader(content))
+	m := NewMetadata("testproblem", cpp, testcase.ReleaseMode)
+	err := sp.Upload(&m, strings.NewReader(content))
 
 	assert.NoError(t, err)
 	assert.Equal(t, "testproblem", m.ProblemName)
-	assert.Equal(t, content, readFile(t, path.Join(tmpstoragedir, "testproblem", m.SolutionFilename)))
+	assert.Equal(t, HashContent([]byte(content)), m.SolutionHash)
+	assert.Equal(t, content, readFile(t, path.Join(tmpstoragedir, BlobDirectory, m.SolutionHash[:2], m.SolutionHash)))
 	assert.NoError(t, err)
 	assert.Equal(t, 0, m.AcceptedCount)
 	assert.Equal(t, 0, m.TestCasesCount)
@@ -162,3 +173,157 @@ func TestDefaultStorage_Remove(t *testing.T) {
 	assert.Equal(t, "sol2.cpp", list[0].SolutionFilename)
 	assert.Equal(t, "sol0.cpp", list[1].SolutionFilename)
 }
+
+func TestDefaultStorage_Artifacts(t *testing.T) {
+	sp, tmpstoragedir := newTestDefaultStorage(t)
+	defer os.RemoveAll(tmpstoragedir)
+	m := NewMetadata("testproblem", cpp, testcase.CoverageMode)
+
+	assert.NoError(t, sp.UploadArtifact(m, "coverage.json", strings.NewReader("{}")))
+	content, err := sp.DownloadArtifact(m, "coverage.json")
+	assert.NoError(t, err)
+	defer content.Close()
+	data, err := ioutil.ReadAll(content)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+
+	names, err := sp.ListArtifacts(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"coverage.json"}, names)
+
+	_, err = sp.DownloadArtifact(m, "missing.json")
+	assert.True(t, os.IsNotExist(err))
+	assert.EqualError(t, sp.UploadArtifact(m, "../x", strings.NewReader("")), "invalid artifact name '../x'")
+}
+
+func TestDefaultStorage_SaveIsAtomic(t *testing.T) {
+	sp, tmpstoragedir := newTestDefaultStorage(t)
+	defer os.RemoveAll(tmpstoragedir)
+	m := testSubmissions(1)[0]
+	assert.NoError(t, sp.Save(m))
+	m.Status = CompilationError
+	assert.NoError(t, sp.Save(m))
+
+	files, err := ioutil.ReadDir(tmpstoragedir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, m.ID.String()+metaFileExtension, files[0].Name())
+	assert.Contains(t, readFile(t, path.Join(tmpstoragedir, files[0].Name())), `"status": "CompilationError"`)
+}
+
+func TestDefaultStorage_LoadAllQuarantinesUnreadableFiles(t *testing.T) {
+	sp, tmpstoragedir := newTestDefaultStorage(t)
+	defer os.RemoveAll(tmpstoragedir)
+	submissions := testSubmissions(3)
+	for _, m := range submissions {
+		assert.NoError(t, sp.Save(m))
+	}
+	truncated := submissions[1].ID.String() + metaFileExtension
+	content := readFile(t, path.Join(tmpstoragedir, truncated))
+	assert.NoError(t, ioutil.WriteFile(path.Join(tmpstoragedir, truncated), []byte(content[:len(content)/2]), 0644))
+	renamed := ID(guuid.New()).String() + metaFileExtension
+	assert.NoError(t, ioutil.WriteFile(path.Join(tmpstoragedir, renamed), []byte(content), 0644))
+	leftover := submissions[2].ID.String() + metaFileExtension + tempFileMarker + "123"
+	assert.NoError(t, ioutil.WriteFile(path.Join(tmpstoragedir, leftover), []byte("{"), 0644))
+
+	sp2 := NewDefaultStorage(tmpstoragedir)
+	assert.NoError(t, sp2.Init())
+	assert.NoError(t, sp2.LoadAll())
+
+	assert.Equal(t, []ID{submissions[2].ID, submissions[0].ID}, ids(sp2.List()))
+	assert.FileExists(t, path.Join(tmpstoragedir, QuarantineDirectory, truncated))
+	assert.FileExists(t, path.Join(tmpstoragedir, QuarantineDirectory, renamed))
+	assert.NoFileExists(t, path.Join(tmpstoragedir, truncated))
+	assert.NoFileExists(t, path.Join(tmpstoragedir, leftover))
+}
+
+func TestStorage_ReadsReturnCopies(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		m := testSubmissions(1)[0]
+		m.CompletedTestCases = []testcase.CompletedTestCase{{Info: testcase.Info{Name: "t1"}}}
+		m.CompilationOutput = []byte("warning")
+		assert.NoError(t, store.Save(m), name)
+		store.Update(m)
+
+		got, _ := store.Get(m.ID)
+		got.CompletedTestCases[0].Info.Name = "changed"
+		got.CompilationOutput[0] = 'W'
+		listed := store.List()
+		listed[0].CompletedTestCases[0].Result.Status = testcase.WrongAnswer
+		page, err := store.Query(Query{})
+		assert.NoError(t, err, name)
+		page.Submissions[0].CompletedTestCases[0].Result.Description = "changed"
+
+		got, _ = store.Get(m.ID)
+		assert.Equal(t, m.CompletedTestCases, got.CompletedTestCases, name)
+		assert.Equal(t, "warning", string(got.CompilationOutput), name)
+		store.Destroy()
+	}
+}
+
+// TestStorage_ConcurrentAccess is meant to be run with -race, submissions are processed
+// like the Processor does while other goroutines read and modify what they read
+func TestStorage_ConcurrentAccess(t *testing.T) {
+	const writers, readers, testcases = 4, 4, 20
+	for name, store := range newTestStorages(t) {
+		submissions := testSubmissions(writers)
+		var wg sync.WaitGroup
+		for i := range submissions {
+			wg.Add(1)
+			go func(m Metadata) {
+				defer wg.Done()
+				assert.NoError(t, store.Save(m), name)
+				m.CompletedTestCases = nil
+				for j := 0; j < testcases; j++ {
+					m.CompletedTestCases = append(m.CompletedTestCases, testcase.CompletedTestCase{Info: testcase.Info{Name: fmt.Sprint(j)}})
+					m.AcceptedCount++
+					store.Update(m)
+				}
+				assert.NoError(t, store.Save(m), name)
+			}(submissions[i])
+		}
+		done := make(chan struct{})
+		var readersWg sync.WaitGroup
+		for i := 0; i < readers; i++ {
+			readersWg.Add(1)
+			go func(i int) {
+				defer readersWg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					if m, found := store.Get(submissions[i%writers].ID); found {
+						for j := range m.CompletedTestCases {
+							m.CompletedTestCases[j].Result.Description = "read"
+						}
+					}
+					for _, m := range store.List() {
+						m.CompilationOutput = append(m.CompilationOutput, 'x')
+					}
+					if _, err := store.Query(Query{Limit: 2}); err != nil {
+						t.Error(err)
+					}
+				}
+			}(i)
+		}
+		changes, stop := store.Watch()
+		wg.Wait()
+		close(done)
+		readersWg.Wait()
+		stop()
+		for range changes {
+		}
+
+		for _, m := range submissions {
+			saved, found := store.Get(m.ID)
+			assert.True(t, found, name)
+			assert.Equal(t, testcases, saved.AcceptedCount, name)
+			assert.Len(t, saved.CompletedTestCases, testcases, name)
+			assert.NoError(t, store.Remove(m.ID), name)
+		}
+		assert.Empty(t, store.List(), name)
+		store.Destroy()
+	}
+}
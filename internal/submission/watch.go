@@ -0,0 +1,53 @@
+package submission
+
+import "log"
+
+// ChangeType kind of a change of a submission in the Storage
+type ChangeType string
+
+const (
+	// ChangeSaved the submission was uploaded or saved
+	ChangeSaved ChangeType = "saved"
+	// ChangeUpdated the in-memory view of the submission changed, see Storage.Update
+	ChangeUpdated ChangeType = "updated"
+	// ChangeRemoved the submission was removed
+	ChangeRemoved ChangeType = "removed"
+)
+
+const changeBufferSize = 256
+
+// Change of a submission delivered to watchers of the Storage
+type Change struct {
+	Type ChangeType
+	// Submission copy of the submission after the change, the last version for ChangeRemoved
+	Submission Metadata
+}
+
+// watchers delivers changes to watchers of a Storage, implementations embed it and notify it
+// about every change while holding their lock, so changes are delivered in order
+type watchers struct {
+	subscribers fanOut
+}
+
+// Watch returns a channel with all changes from now on, stop must be called when the watcher
+// is no longer interested
+func (w *watchers) Watch() (<-chan Change, func()) {
+	sub := make(chan Change, changeBufferSize)
+	return sub, w.subscribers.subscribe(func(v interface{}) bool {
+		change := v.(Change)
+		change.Submission = change.Submission.clone()
+		select {
+		case sub <- change:
+			return true
+		default:
+			return false
+		}
+	}, func() { close(sub) })
+}
+
+// notify never blocks, changes are dropped for watchers which don't keep up
+func (w *watchers) notify(changeType ChangeType, submission Metadata) {
+	if dropped := w.subscribers.publish(Change{Type: changeType, Submission: submission}); dropped > 0 {
+		log.Println("storage:", dropped, "watchers are too slow, dropping change", changeType, "of", submission.ID)
+	}
+}
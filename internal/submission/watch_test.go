@@ -0,0 +1,59 @@
+package submission
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_Watch(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		changes, stop := store.Watch()
+		m := testSubmissions(1)[0]
+		assert.NoError(t, store.Upload(&m, strings.NewReader("int main() {}")), name)
+		m.Status = RunningTests
+		store.Update(m)
+		m.Status = AllTestsCompleted
+		assert.NoError(t, store.Save(m), name)
+		assert.NoError(t, store.Remove(m.ID), name)
+
+		for _, expected := range []struct {
+			changeType ChangeType
+			status     Status
+		}{{ChangeSaved, AllTestsCompleted}, {ChangeUpdated, RunningTests}, {ChangeSaved, AllTestsCompleted}, {ChangeRemoved, AllTestsCompleted}} {
+			change := <-changes
+			assert.Equal(t, expected.changeType, change.Type, name)
+			assert.Equal(t, expected.status, change.Submission.Status, name)
+			assert.Equal(t, m.ID, change.Submission.ID, name)
+			assert.Equal(t, m.SolutionHash, change.Submission.SolutionHash, name)
+		}
+
+		stop()
+		stop()
+		_, open := <-changes
+		assert.False(t, open, name)
+		// changes after stop are not delivered
+		assert.NoError(t, store.Save(m), name)
+		store.Destroy()
+	}
+}
+
+func TestStorage_WatchDropsChangesOfSlowWatchers(t *testing.T) {
+	for name, store := range newTestStorages(t) {
+		slow, stopSlow := store.Watch()
+		defer stopSlow()
+		m := testSubmissions(1)[0]
+		for i := 0; i < changeBufferSize+10; i++ {
+			m.AcceptedCount = i
+			store.Update(m)
+		}
+		fast, stopFast := store.Watch()
+		store.Update(m)
+		assert.Len(t, slow, changeBufferSize, name)
+		assert.Equal(t, 0, (<-slow).Submission.AcceptedCount, name)
+		assert.Equal(t, ChangeUpdated, (<-fast).Type, name)
+		stopFast()
+		store.Destroy()
+	}
+}
@@ -1,11 +1,16 @@
 package testcase
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,10 +18,19 @@ type Archive interface {
 	Problems() ([]string, error)
 	Testcases(problemName string) (testcases []Info, err error)
 	Runner(problemName string) Runner
+	// Config configuration of the problem, zero value if the problem has none
+	Config(problemName string) (ProblemConfig, error)
+	// ProblemFile content of a file in the problem directory, e.g. a grader source
+	ProblemFile(problemName, filename string) ([]byte, error)
+	// Fingerprint hash of all the data of the problem: test data, configuration and grader files.
+	// It changes whenever any of them changes.
+	Fingerprint(problemName string) (string, error)
 }
 
 type defaultArchive struct {
 	dataDir string
+	// fileHashes hashes of problem files by path, size and modification time, so unchanged test data isn't read again
+	fileHashes sync.Map
 }
 
 func NewArchive(problemsDirectory string) Archive {
@@ -87,3 +101,55 @@ func (a *defaultArchive) Runner(problemName string) Runner {
 	return NewRunner(problemName,
 		DirectoryBasedDataStreamsProvider(path.Join(a.dataDir, problemName)))
 }
+
+func (a *defaultArchive) Config(problemName string) (ProblemConfig, error) {
+	return readProblemConfig(filepath.Join(a.dataDir, problemName))
+}
+
+func (a *defaultArchive) Fingerprint(problemName string) (string, error) {
+	problemDir := filepath.Join(a.dataDir, problemName)
+	h := sha256.New()
+	// filepath.Walk visits files in lexical order, so the fingerprint is stable
+	err := filepath.Walk(problemDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		fileHash, err := a.fileHash(filePath, info)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %s\n", strings.TrimPrefix(filePath, problemDir), fileHash)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (a *defaultArchive) fileHash(filePath string, info os.FileInfo) (string, error) {
+	key := fmt.Sprintf("%s %d %d", filePath, info.Size(), info.ModTime().UnixNano())
+	if cached, ok := a.fileHashes.Load(key); ok {
+		return cached.(string), nil
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	fileHash := hex.EncodeToString(h.Sum(nil))
+	a.fileHashes.Store(key, fileHash)
+	return fileHash, nil
+}
+
+func (a *defaultArchive) ProblemFile(problemName, filename string) ([]byte, error) {
+	filePath, err := problemFilePath(filepath.Join(a.dataDir, problemName), filename)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(filePath)
+}
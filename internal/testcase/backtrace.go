@@ -0,0 +1,143 @@
+package testcase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CrashBacktraces configuration of backtraces collected when a solution crashes
+type CrashBacktraces struct {
+	Enabled bool `json:"enabled"`
+	// Debugger command which runs the program and prints a gdb-style backtrace ("#0  0x... in f (x=1) at file:line"),
+	// {command} is replaced with the command running the solution. Input of the test is passed on stdin.
+	Debugger []string `json:"debugger"`
+}
+
+// DefaultCrashBacktraces backtraces printed by gdb, disabled unless enabled in the config file:
+// every crashed test runs again under the debugger
+func DefaultCrashBacktraces() CrashBacktraces {
+	return CrashBacktraces{
+		Enabled:  false,
+		Debugger: []string{"gdb", "-batch", "-nx", "-q", "-ex", "run", "-ex", "bt", "--args", "{command}"},
+	}
+}
+
+// Validate checks if the configuration can be used
+func (c CrashBacktraces) Validate() error {
+	if c.Enabled && len(c.Debugger) == 0 {
+		return errors.New("crash backtraces are enabled, but there is no debugger command")
+	}
+	return nil
+}
+
+var (
+	crashBacktraces      = DefaultCrashBacktraces()
+	crashBacktracesMutex sync.RWMutex
+)
+
+// SetCrashBacktraces replaces the configuration of crash backtraces, e.g. with one read from the config file
+func SetCrashBacktraces(c CrashBacktraces) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	crashBacktracesMutex.Lock()
+	defer crashBacktracesMutex.Unlock()
+	crashBacktraces = c
+	return nil
+}
+
+// CurrentCrashBacktraces configuration of crash backtraces in use
+func CurrentCrashBacktraces() CrashBacktraces {
+	crashBacktracesMutex.RLock()
+	defer crashBacktracesMutex.RUnlock()
+	return crashBacktraces
+}
+
+// withDebugInformation flags of a compilation profile, with -g when backtraces are enabled,
+// so that they show source lines
+func withDebugInformation(flags []string) []string {
+	if !CurrentCrashBacktraces().Enabled || contains(flags, "-g") {
+		return flags
+	}
+	return append(append([]string{}, flags...), "-g")
+}
+
+// StackFrame frame of the backtrace of a crashed solution
+type StackFrame struct {
+	Function string `json:"function"`
+	// File and Line location in the source, empty if the solution has no debug information
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Library where the function is defined, if it's not in the solution
+	Library string `json:"library,omitempty"`
+}
+
+func (f StackFrame) String() string {
+	switch {
+	case f.File != "":
+		return fmt.Sprintf("%s at %s:%d", f.Function, f.File, f.Line)
+	case f.Library != "":
+		return fmt.Sprintf("%s from %s", f.Function, f.Library)
+	}
+	return f.Function
+}
+
+// framePattern matches frames printed by gdb, e.g. "#1  0x0000555555555151 in main () at solution.cpp:7"
+var framePattern = regexp.MustCompile(`^#\d+\s+(?:0x[0-9a-fA-F]+ in )?(\S+) \(.*?\)(?: at (\S+):(\d+)| from (\S+))?\s*$`)
+
+// parseBacktrace extracts stack frames from output of the debugger, other lines are skipped
+func parseBacktrace(output []byte) []StackFrame {
+	var res []StackFrame
+	for _, line := range strings.Split(string(output), "\n") {
+		m := framePattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		frame := StackFrame{Function: m[1], File: m[2], Library: m[4]}
+		frame.Line, _ = strconv.Atoi(m[3])
+		res = append(res, frame)
+	}
+	return res
+}
+
+// CollectBacktrace runs the command with input under the debugger and returns the backtrace of the crash.
+// Returns nil without an error if backtraces are disabled or the debugger is not installed.
+// The debugger gets the time limit of the test and 10 more seconds to start.
+func CollectBacktrace(command []string, info Info, input io.Reader) ([]StackFrame, error) {
+	config := CurrentCrashBacktraces()
+	if !config.Enabled {
+		return nil, nil
+	}
+	args := expandCommand(config.Debugger, nil, map[string][]string{"{command}": command})
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), info.TimeLimit+10*time.Second)
+	defer cancel()
+	output := &limitedBuffer{limit: 64 << 10}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = input
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := runInProcessGroup(ctx, cmd)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s was aborted after %v", args[0], info.TimeLimit+10*time.Second)
+	}
+	if _, exited := err.(*exec.ExitError); err != nil && !exited {
+		return nil, err
+	}
+	frames := parseBacktrace(output.Bytes())
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no backtrace in output of %s: %s", args[0], bytes.TrimSpace(output.Bytes()))
+	}
+	return frames, nil
+}
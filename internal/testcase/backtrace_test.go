@@ -0,0 +1,65 @@
+//go:build linux
+// +build linux
+
+package testcase
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const gdbOutput = `
+Program received signal SIGSEGV, Segmentation fault.
+0x0000000000401745 in crash (p=0x0) at solution.cpp:4
+4	  return *p;
+#0  0x0000000000401745 in crash (p=0x0) at solution.cpp:4
+#1  main () at solution.cpp:9
+#2  0x00007ffff7829d90 in __libc_start_call_main () from /lib/x86_64-linux-gnu/libc.so.6
+#3  0x0000000000401665 in _start ()
+`
+
+func TestParseBacktrace(t *testing.T) {
+	assert.Equal(t, []StackFrame{
+		{Function: "crash", File: "solution.cpp", Line: 4},
+		{Function: "main", File: "solution.cpp", Line: 9},
+		{Function: "__libc_start_call_main", Library: "/lib/x86_64-linux-gnu/libc.so.6"},
+		{Function: "_start"},
+	}, parseBacktrace([]byte(gdbOutput)))
+	assert.Equal(t, "crash at solution.cpp:4", StackFrame{Function: "crash", File: "solution.cpp", Line: 4}.String())
+}
+
+func TestRunner_CrashBacktrace(t *testing.T) {
+	defer SetCrashBacktraces(DefaultCrashBacktraces())
+	// pretends to be gdb: prints the input and the command as frames
+	assert.NoError(t, SetCrashBacktraces(CrashBacktraces{Enabled: true, Debugger: []string{
+		"/bin/sh", "-c", `read x; echo "#0  0x0000000000401745 in crash (p=0x0) at solution.cpp:$x"; echo "#1  $(basename "$1") ()"`, "sh", "{command}"}}))
+
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+	command, out, err := CompileSolution(strings.NewReader("int main() { volatile int *p = 0; return *p; }"), mustLookupLanguage(t, "cpp17"), ReleaseMode, dir)
+	assert.NoError(t, err, string(out))
+	runner := NewRunner("crash", func(info Info) (Streams, error) {
+		return Streams{Input: strings.NewReader("4\n"), Output: strings.NewReader(""), Close: func() error { return nil }}, nil
+	})
+
+	res := runner.Run(context.Background(), command, NewInfo("t1", 5*time.Second, 0))
+	assert.Equal(t, RuntimeError, res.Status)
+	assert.Equal(t, "SIGSEGV", res.Signal)
+	assert.Contains(t, res.Description, "program crashed with SIGSEGV (segmentation fault) on test input file 't1'")
+	assert.Equal(t, []StackFrame{{Function: "crash", File: "solution.cpp", Line: 4}, {Function: "solution.tsk"}}, res.Backtrace)
+
+	assert.NoError(t, SetCrashBacktraces(CrashBacktraces{Enabled: false}))
+	res = runner.Run(context.Background(), command, NewInfo("t1", 5*time.Second, 0))
+	assert.Equal(t, "SIGSEGV", res.Signal)
+	assert.Nil(t, res.Backtrace)
+}
+
+func TestCrashBacktraces_Validate(t *testing.T) {
+	assert.Error(t, SetCrashBacktraces(CrashBacktraces{Enabled: true}))
+	assert.NoError(t, DefaultCrashBacktraces().Validate())
+}
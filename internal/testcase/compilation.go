@@ -1,87 +1,310 @@
 package testcase
 
-//go:generate stringer -type=CompilationMode
-
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
-// CompilationMode compilation mode
-type CompilationMode int
+// CompilationMode ID of the CompilationProfile used to compile C and C++ solutions
+type CompilationMode string
 
+// IDs of the default compilation profiles
 const (
 	// Release this is release compilation mode with optimization
-	ReleaseMode CompilationMode = iota + 1
+	ReleaseMode CompilationMode = "ReleaseMode"
 	// AnalyzeClang this is mode which maximizes possibility of finding bugs
-	AnalyzeClangMode
+	AnalyzeClangMode CompilationMode = "AnalyzeClangMode"
 	//AnalyzeGplusplus
-	AnalyzeGplusplusMode
+	AnalyzeGplusplusMode CompilationMode = "AnalyzeGplusplusMode"
+	// CoverageMode instruments the solution to collect line and branch coverage of the tests
+	CoverageMode CompilationMode = "CoverageMode"
 )
 
+// legacyCompilationModes modes used to be numbered in this order
+var legacyCompilationModes = []CompilationMode{ReleaseMode, AnalyzeClangMode, AnalyzeGplusplusMode}
+
+const executableFilename = "solution.tsk"
+
+// expandCommand replaces placeholders in the command template, see Language for their meaning
+func expandCommand(template []string, vars map[string]string, lists map[string][]string) []string {
+	res := make([]string, 0, len(template))
+	for _, arg := range template {
+		if list, ok := lists[arg]; ok {
+			res = append(res, list...)
+			continue
+		}
+		for name, value := range vars {
+			arg = strings.Replace(arg, "{"+name+"}", value, -1)
+		}
+		res = append(res, arg)
+	}
+	return res
+}
+
+// sourceFiles files of the solution in the build directory, paths are relative to it
+type sourceFiles struct {
+	// entry source file with the entry point, e.g. main()
+	entry string
+	// units all translation units of a multi-file solution, nil for single file solutions
+	units []string
+}
+
+func singleSourceFile(lang Language) sourceFiles {
+	return sourceFiles{entry: lang.SourceFile()}
+}
+
+// compileTemplate template of the compilation command, nil if the language has no compilation step
+func (lang Language) compileTemplate(files sourceFiles) []string {
+	if files.units != nil && lang.CompileMultiple != nil {
+		return lang.CompileMultiple
+	}
+	return lang.Compile
+}
+
+// commandArgs expands the command template of the language for a solution located in dir
+func commandArgs(template []string, lang Language, mode CompilationMode, dir, executable string, files sourceFiles) ([]string, error) {
+	vars := map[string]string{
+		"source":     filepath.Join(dir, filepath.FromSlash(files.entry)),
+		"executable": executable,
+		"dir":        dir,
+		"std":        lang.Standard,
+	}
+	units := files.units
+	if units == nil {
+		units = []string{files.entry}
+	}
+	lists := map[string][]string{"{sources}": units}
+	if lang.UsesCompilationMode() {
+		profile, ok := LookupCompilationProfile(mode)
+		if !ok {
+			return nil, errors.New("unknown compilation mode selected")
+		}
+		if !profile.Supports(lang) {
+			return nil, fmt.Errorf("compilation mode '%s' does not support %s", mode, lang.Name)
+		}
+		vars["compiler"] = profile.Compilers[lang.Family]
+		if vars["std"] == "" {
+			vars["std"] = profile.Standards[lang.Family]
+		}
+		lists["{flags}"] = withDebugInformation(profile.Flags)
+	}
+	return expandCommand(template, vars, lists), nil
+}
+
 // TODO: Add and test if "-lasan" works
-func CompilationCommand(mode CompilationMode, executableFile string) (*exec.Cmd, error) {
-	var cmd *exec.Cmd
-	switch mode {
-	case ReleaseMode:
-		cmd = exec.Command("g++", "-std=c++17", "-static", "-O3", "-x", "c++", "-", "-lm", "-o", executableFile)
-	case AnalyzeClangMode:
-		cmd = exec.Command("clang++", "-std=c++14", "-Wall", "-Werror", "-O1", "-g", "-fsanitize=address",
-			"-fno-omit-frame-pointer", "-x", "c++", "-", "-lm", "-o", executableFile)
-	case AnalyzeGplusplusMode:
-		cmd = exec.Command("g++", "-std=c++17", "-Wall", "-Werror", "-O1", "-g", "-fsanitize=address",
-			"-fno-omit-frame-pointer", "-x", "c++", "-", "-lm", "-o", executableFile)
-	default:
-		return nil, errors.New("unknown compilation mode selected")
+// CompilationCommand command compiling the solution located in dir, nil if the language has no compilation step.
+// The compiler runs in dir, with memory limited according to CurrentCompilationLimits.
+func CompilationCommand(lang Language, mode CompilationMode, dir string) (*exec.Cmd, error) {
+	return compilationCommand(lang, mode, dir, singleSourceFile(lang))
+}
+
+func compilationCommand(lang Language, mode CompilationMode, dir string, files sourceFiles) (*exec.Cmd, error) {
+	args, err := commandArgs(lang.compileTemplate(files), lang, mode, dir, filepath.Join(dir, executableFilename), files)
+	if err != nil || len(args) == 0 {
+		return nil, err
+	}
+	args = withMemoryLimit(args, int64(CurrentCompilationLimits().MemoryLimitMB)<<20)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	if profile, ok := LookupCompilationProfile(mode); ok && lang.UsesCompilationMode() && len(profile.Env) > 0 {
+		cmd.Env = append(os.Environ(), profile.Env...)
 	}
 	return cmd, nil
 }
 
-func CompileSolution(solution io.Reader, mode CompilationMode, executableFile string) (output []byte, err error) {
-	cmd, err := CompilationCommand(mode, executableFile)
+// RunCommand command running the solution prepared in dir by CompileSolution
+func RunCommand(lang Language, mode CompilationMode, dir string) ([]string, error) {
+	return runCommand(lang, mode, dir, singleSourceFile(lang))
+}
+
+func runCommand(lang Language, mode CompilationMode, dir string, files sourceFiles) ([]string, error) {
+	return commandArgs(lang.Run, lang, mode, dir, filepath.Join(dir, executableFilename), files)
+}
+
+// Solution sources of a submission: a single file, or an archive of files if Archive is set
+type Solution struct {
+	Source  []byte
+	Archive ArchiveFormat
+	// Entry path of the file with the entry point, chosen automatically if empty
+	Entry string
+	// SourceFilename name of the single source file, Language.SourceFile() if empty
+	SourceFilename string
+	// Extra files provided by the problem, e.g. a grader, compiled together with the solution, see WithGrader
+	Extra map[string][]byte
+}
+
+// prepareSources writes or unpacks the solution into dir
+func prepareSources(solution Solution, lang Language, dir string) (sourceFiles, error) {
+	var names []string
+	if solution.Archive == "" {
+		filename := solution.SourceFilename
+		if filename == "" {
+			filename = lang.SourceFile()
+		}
+		if err := writeFile(filepath.Join(dir, filename), bytes.NewReader(solution.Source)); err != nil {
+			return sourceFiles{}, err
+		}
+		if len(solution.Extra) == 0 {
+			return sourceFiles{entry: filename}, nil
+		}
+		names = []string{filename}
+		if solution.Entry == "" {
+			solution.Entry = filename
+		}
+	} else {
+		var err error
+		if names, err = UnpackSourceArchive(solution.Source, solution.Archive, dir); err != nil {
+			return sourceFiles{}, err
+		}
+	}
+	// extra files are written last, so they can't be replaced by the contestant
+	for name, content := range solution.Extra {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return sourceFiles{}, err
+		}
+		if err := ioutil.WriteFile(filename, content, 0644); err != nil {
+			return sourceFiles{}, err
+		}
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return lang.archivedSourceFiles(names, solution.Entry)
+}
+
+// CompileSolution writes the solution into dir and compiles it if the language requires it.
+// Returns command which runs the solution. ErrCompilationTimeout is returned if the compiler
+// doesn't finish in time, its output is truncated to the limit from CurrentCompilationLimits.
+func CompileSolution(solution io.Reader, lang Language, mode CompilationMode, dir string) (command []string, output []byte, err error) {
+	source, err := ioutil.ReadAll(solution)
 	if err != nil {
-		return []byte{}, err
+		return nil, []byte{}, err
 	}
-	//fmt.Println(solution)
-	cmd.Stdin = solution
-	//log.Println("About to execute command:", cmd.String())
-	output, err = cmd.CombinedOutput()
+	return Compile(Solution{Source: source}, lang, mode, dir)
+}
+
+// Compile works like CompileSolution. Archives are unpacked into dir and, like solutions with Extra files,
+// all their translation units are compiled together.
+func Compile(solution Solution, lang Language, mode CompilationMode, dir string) (command []string, output []byte, err error) {
+	files, err := prepareSources(solution, lang, dir)
 	if err != nil {
-		if output != nil {
-			output = []byte(err.Error() + ". Output: " + string(output))
-		} else {
+		return nil, []byte(err.Error()), err
+	}
+	return compileSources(lang, mode, dir, files)
+}
 
+func compileSources(lang Language, mode CompilationMode, dir string, files sourceFiles) (command []string, output []byte, err error) {
+	command, err = runCommand(lang, mode, dir, files)
+	if err != nil {
+		return nil, []byte{}, err
+	}
+	cmd, err := compilationCommand(lang, mode, dir, files)
+	if err != nil {
+		return nil, []byte{}, err
+	}
+	if cmd == nil {
+		return command, []byte{}, nil
+	}
+	if files.units == nil {
+		// single file solutions may be read by the compiler from stdin
+		source, err := os.Open(filepath.Join(dir, files.entry))
+		if err != nil {
+			return nil, []byte{}, err
 		}
-		return output, errors.New("compilation failed with " + err.Error())
+		defer source.Close()
+		cmd.Stdin = source
 	}
-	return output, nil
+	limits := CurrentCompilationLimits()
+	combined := &limitedBuffer{limit: limits.OutputLimitKB << 10}
+	cmd.Stdout = combined
+	cmd.Stderr = combined
+	ctx := context.Background()
+	if limits.Timeout() > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout())
+		defer cancel()
+	}
+	//log.Println("About to execute command:", cmd.String())
+	err = runInProcessGroup(ctx, cmd)
+	output = combined.Bytes()
+	if ctx.Err() == context.DeadlineExceeded {
+		output = []byte(fmt.Sprintf("compilation was aborted after %v. Output: %s", limits.Timeout(), output))
+		return nil, output, ErrCompilationTimeout
+	}
+	if err != nil {
+		output = []byte(err.Error() + ". Output: " + string(output))
+		return nil, output, errors.New("compilation failed with " + err.Error())
+	}
+	return command, output, nil
 }
 
+func writeFile(filename string, content io.Reader) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, content)
+	return err
+}
+
+// FullCompilationCommadFor compilation command of C++ solutions in given mode
 func FullCompilationCommadFor(cm CompilationMode) string {
-	cmd, err := CompilationCommand(cm, "a.out")
+	return FullCommandFor(DefaultLanguageID, cm)
+}
+
+// FullCommandFor command used to compile solutions in given language and mode,
+// or to run them if the language has no compilation step
+func FullCommandFor(languageID string, cm CompilationMode) string {
+	lang, ok := LookupLanguage(languageID)
+	if !ok {
+		return "unknown language " + languageID
+	}
+	template, prefix := lang.Compile, ""
+	if template == nil {
+		template, prefix = lang.Run, "no compilation, run with: "
+	}
+	args, err := commandArgs(template, lang, cm, ".", "a.out", singleSourceFile(lang))
 	if err != nil {
 		return "unable to convert"
 	}
-	return cmd.String()
+	return prefix + strings.Join(args, " ")
 }
 
+// UnmarshalJSON accepts profile IDs and numbers stored by old versions
 func (cm *CompilationMode) UnmarshalJSON(data []byte) error {
 	var s string
-	err := json.Unmarshal(data, &s)
-	if err != nil {
-		return err
+	if err := json.Unmarshal(data, &s); err == nil {
+		*cm = CompilationMode(s)
+		return nil
 	}
-	for i := 0; i <= len(_CompilationMode_index); i++ {
-		if CompilationMode(i).String() == s {
-			*cm = CompilationMode(i)
-			return nil
-		}
+	var legacy int
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return errors.New("invalid CompilationMode value")
+	}
+	if legacy < 1 || legacy > len(legacyCompilationModes) {
+		return fmt.Errorf("invalid legacy CompilationMode value %d", legacy)
 	}
-	return errors.New("invalid CompilationMode status value")
+	*cm = legacyCompilationModes[legacy-1]
+	return nil
+}
+
+func (cm CompilationMode) String() string {
+	return string(cm)
 }
 
 func (cm CompilationMode) MarshalJSON() ([]byte, error) {
-	return json.Marshal(cm.String())
+	return json.Marshal(string(cm))
 }
@@ -0,0 +1,277 @@
+package testcase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CompilationCache content-addressed cache of compiled solutions, see CompilationCacheKey
+type CompilationCache interface {
+	// Restore copies files compiled earlier into dir and returns the compiler output, false if there is no such entry
+	Restore(key, dir string) ([]byte, bool)
+	// Store remembers files compiled in dir, except the sources (paths relative to dir)
+	Store(key, dir string, sources []string, output []byte) error
+}
+
+const (
+	cacheFilesDir   = "files"
+	cacheOutputFile = "output"
+	cacheTmpPrefix  = "tmp-"
+)
+
+type cacheEntry struct {
+	size     int64
+	lastUsed time.Time
+}
+
+type fileCompilationCache struct {
+	dir        string
+	maxBytes   int64
+	entries    map[string]*cacheEntry
+	totalBytes int64
+	m          sync.Mutex
+}
+
+// NewFileCompilationCache constructor of the CompilationCache kept in dir,
+// least recently used entries are evicted when the cache grows over maxBytes
+func NewFileCompilationCache(dir string, maxBytes int64) (CompilationCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &fileCompilationCache{dir: dir, maxBytes: maxBytes, entries: make(map[string]*cacheEntry)}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		entryDir := filepath.Join(dir, info.Name())
+		if strings.HasPrefix(info.Name(), cacheTmpPrefix) {
+			// left by an interrupted Store
+			os.RemoveAll(entryDir)
+			continue
+		}
+		if !info.IsDir() {
+			continue
+		}
+		size, err := dirSize(entryDir)
+		if err != nil {
+			return nil, err
+		}
+		c.entries[info.Name()] = &cacheEntry{size: size, lastUsed: info.ModTime()}
+		c.totalBytes += size
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.evict()
+	return c, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// copyFiles copies regular files of src into dst, except ones listed in skip
+func copyFiles(src, dst string, skip ...string) error {
+	infos, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || contains(skip, info.Name()) {
+			continue
+		}
+		if err = copyFile(filepath.Join(src, info.Name()), filepath.Join(dst, info.Name()), info.Mode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *fileCompilationCache) Restore(key, dir string) ([]byte, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entryDir := filepath.Join(c.dir, key)
+	output, err := ioutil.ReadFile(filepath.Join(entryDir, cacheOutputFile))
+	if err == nil {
+		err = copyFiles(filepath.Join(entryDir, cacheFilesDir), dir)
+	}
+	if err != nil {
+		log.Println("compilation cache: dropping broken entry", key, err)
+		c.remove(key)
+		return nil, false
+	}
+	entry.lastUsed = time.Now()
+	os.Chtimes(entryDir, entry.lastUsed, entry.lastUsed)
+	return output, true
+}
+
+func (c *fileCompilationCache) Store(key, dir string, sources []string, output []byte) error {
+	tmpDir, err := ioutil.TempDir(c.dir, cacheTmpPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err = os.Mkdir(filepath.Join(tmpDir, cacheFilesDir), 0755); err != nil {
+		return err
+	}
+	if err = copyFiles(dir, filepath.Join(tmpDir, cacheFilesDir), sources...); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(tmpDir, cacheOutputFile), output, 0644); err != nil {
+		return err
+	}
+	size, err := dirSize(tmpDir)
+	if err != nil {
+		return err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	if _, ok := c.entries[key]; ok {
+		// compiled concurrently by someone else
+		return nil
+	}
+	if err = os.Rename(tmpDir, filepath.Join(c.dir, key)); err != nil {
+		return err
+	}
+	c.entries[key] = &cacheEntry{size: size, lastUsed: time.Now()}
+	c.totalBytes += size
+	c.evict()
+	return nil
+}
+
+func (c *fileCompilationCache) remove(key string) {
+	if err := os.RemoveAll(filepath.Join(c.dir, key)); err != nil {
+		log.Println("compilation cache: unable to remove entry", key, err)
+	}
+	c.totalBytes -= c.entries[key].size
+	delete(c.entries, key)
+}
+
+// evict removes least recently used entries until the cache fits in maxBytes, must be called with c.m held
+func (c *fileCompilationCache) evict() {
+	if c.totalBytes <= c.maxBytes {
+		return
+	}
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return c.entries[keys[i]].lastUsed.Before(c.entries[keys[j]].lastUsed) })
+	for _, key := range keys {
+		if c.totalBytes <= c.maxBytes {
+			return
+		}
+		c.remove(key)
+	}
+}
+
+// CompilationCacheKey hash of everything which affects the result of the compilation:
+// sources, language, compilation profile and version of the compiler
+func CompilationCacheKey(solution Solution, lang Language, mode CompilationMode) (string, error) {
+	h := sha256.New()
+	h.Write(solution.Source)
+	enc := json.NewEncoder(h)
+	if err := enc.Encode([]string{string(solution.Archive), solution.Entry, solution.SourceFilename}); err != nil {
+		return "", err
+	}
+	// encoding/json sorts map keys, so the encoding of Extra is stable
+	if err := enc.Encode(solution.Extra); err != nil {
+		return "", err
+	}
+	if err := enc.Encode(lang); err != nil {
+		return "", err
+	}
+	if lang.UsesCompilationMode() {
+		profile, _ := LookupCompilationProfile(mode)
+		if err := enc.Encode(profile); err != nil {
+			return "", err
+		}
+		if err := enc.Encode(withDebugInformation(profile.Flags)); err != nil {
+			return "", err
+		}
+	}
+	h.Write([]byte(CompilerVersion(lang, mode)))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CompileSolutionCached works like Compile, but reuses files compiled earlier from the same sources
+// with the same profile and compiler. cached is true if the compiler was not invoked.
+// A nil cache disables caching. Solutions which collect coverage are never cached, their data files
+// are written next to the compiled files, at the path known during the compilation.
+func CompileSolutionCached(cache CompilationCache, solution Solution, lang Language, mode CompilationMode, dir string) (command []string, output []byte, cached bool, err error) {
+	if cache == nil || lang.Compile == nil || CoverageEnabled(lang, mode) {
+		command, output, err = Compile(solution, lang, mode, dir)
+		return command, output, false, err
+	}
+	key, err := CompilationCacheKey(solution, lang, mode)
+	if err != nil {
+		return nil, []byte{}, false, err
+	}
+	files, err := prepareSources(solution, lang, dir)
+	if err != nil {
+		return nil, []byte(err.Error()), false, err
+	}
+	if output, ok := cache.Restore(key, dir); ok {
+		command, err = runCommand(lang, mode, dir, files)
+		return command, output, true, err
+	}
+	command, output, err = compileSources(lang, mode, dir, files)
+	if err != nil {
+		return command, output, false, err
+	}
+	if err := cache.Store(key, dir, append([]string{files.entry}, files.units...), output); err != nil {
+		log.Println("compilation cache: unable to store", key, err)
+	}
+	return command, output, false, nil
+}
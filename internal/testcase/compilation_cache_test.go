@@ -0,0 +1,145 @@
+package testcase
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func storeEntry(t *testing.T, cache CompilationCache, key string, content string) {
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "solution.cpp"), []byte("source"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, executableFilename), []byte(content), 0755))
+	assert.NoError(t, cache.Store(key, dir, []string{"solution.cpp"}, []byte("warning: "+key)))
+}
+
+func TestCompilationCache_StoreRestore(t *testing.T) {
+	cacheDir := tempBuildDir(t)
+	defer os.RemoveAll(cacheDir)
+	cache, err := NewFileCompilationCache(cacheDir, 1<<20)
+	assert.NoError(t, err)
+
+	_, ok := cache.Restore("k1", tempBuildDir(t))
+	assert.False(t, ok)
+
+	storeEntry(t, cache, "k1", "executable")
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+	output, ok := cache.Restore("k1", dir)
+	assert.True(t, ok)
+	assert.Equal(t, "warning: k1", string(output))
+	assert.Equal(t, "executable", readFile(t, filepath.Join(dir, executableFilename)))
+	assert.NoFileExists(t, filepath.Join(dir, "solution.cpp"))
+	info, err := os.Stat(filepath.Join(dir, executableFilename))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// entries survive restarts
+	cache, err = NewFileCompilationCache(cacheDir, 1<<20)
+	assert.NoError(t, err)
+	_, ok = cache.Restore("k1", dir)
+	assert.True(t, ok)
+}
+
+func TestCompilationCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cacheDir := tempBuildDir(t)
+	defer os.RemoveAll(cacheDir)
+	entry := strings.Repeat("x", 1000)
+	cache, err := NewFileCompilationCache(cacheDir, 2500)
+	assert.NoError(t, err)
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+
+	storeEntry(t, cache, "k1", entry)
+	storeEntry(t, cache, "k2", entry)
+	_, ok := cache.Restore("k1", dir)
+	assert.True(t, ok)
+	storeEntry(t, cache, "k3", entry)
+
+	_, ok = cache.Restore("k2", dir)
+	assert.False(t, ok, "least recently used entry should be evicted")
+	_, ok = cache.Restore("k1", dir)
+	assert.True(t, ok)
+	_, ok = cache.Restore("k3", dir)
+	assert.True(t, ok)
+	assert.NoDirExists(t, filepath.Join(cacheDir, "k2"))
+}
+
+func TestCompilationCacheKey(t *testing.T) {
+	cpp := mustLookupLanguage(t, DefaultLanguageID)
+	key := func(source string, lang Language, mode CompilationMode) string {
+		k, err := CompilationCacheKey(Solution{Source: []byte(source)}, lang, mode)
+		assert.NoError(t, err)
+		return k
+	}
+	k := key("int main() {}", cpp, ReleaseMode)
+	assert.Equal(t, k, key("int main() {}", cpp, ReleaseMode))
+	assert.NotEqual(t, k, key("int main() { }", cpp, ReleaseMode))
+	assert.NotEqual(t, k, key("int main() {}", cpp, AnalyzeGplusplusMode))
+	assert.NotEqual(t, k, key("int main() {}", mustLookupLanguage(t, "cpp11"), ReleaseMode))
+}
+
+func TestCompileSolutionCached(t *testing.T) {
+	cacheDir := tempBuildDir(t)
+	defer os.RemoveAll(cacheDir)
+	cache, err := NewFileCompilationCache(cacheDir, 1<<30)
+	assert.NoError(t, err)
+	source := `#include <cstdio>
+	int main() { printf("42\n"); return 0; }`
+	cpp := mustLookupLanguage(t, DefaultLanguageID)
+
+	for i, expectCached := range []bool{false, true} {
+		dir := tempBuildDir(t)
+		defer os.RemoveAll(dir)
+		command, _, cached, err := CompileSolutionCached(cache, Solution{Source: []byte(source)}, cpp, ReleaseMode, dir)
+		assert.NoError(t, err)
+		assert.Equal(t, expectCached, cached, "compilation %d", i)
+		assert.Equal(t, []string{filepath.Join(dir, executableFilename)}, command)
+		assert.Equal(t, source, readFile(t, filepath.Join(dir, "solution.cpp")))
+		result := runTestWithTmpOutput(command, NewInfo("t", 10*time.Second, 0),
+			Streams{Input: strings.NewReader(""), Output: strings.NewReader("42\n")})
+		assert.Equal(t, Accepted, result.Status)
+	}
+}
+
+func TestCompilationCacheKey_Archive(t *testing.T) {
+	cpp := mustLookupLanguage(t, DefaultLanguageID)
+	single, err := CompilationCacheKey(Solution{Source: []byte("content")}, cpp, ReleaseMode)
+	assert.NoError(t, err)
+	archive, err := CompilationCacheKey(Solution{Source: []byte("content"), Archive: ZipArchive}, cpp, ReleaseMode)
+	assert.NoError(t, err)
+	entry, err := CompilationCacheKey(Solution{Source: []byte("content"), Archive: ZipArchive, Entry: "a.cpp"}, cpp, ReleaseMode)
+	assert.NoError(t, err)
+	assert.NotEqual(t, single, archive)
+	assert.NotEqual(t, archive, entry)
+}
+
+func TestCompilationCacheKey_CompilerUpgradedWhileRunning(t *testing.T) {
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+	compiler := filepath.Join(dir, "fake-g++")
+	installed := time.Now().Add(-time.Hour)
+	writeFakeCompiler(t, compiler, "fake 1.0", installed)
+	assert.NoError(t, SetCompilationProfiles(append(DefaultCompilationProfiles(), CompilationProfile{
+		ID:        "Fake",
+		Name:      "Fake compiler",
+		Compilers: map[string]string{FamilyCpp: compiler},
+		Standards: map[string]string{FamilyCpp: "c++17"},
+	})))
+	defer resetToolchains()
+	cpp := mustLookupLanguage(t, DefaultLanguageID)
+	solution := Solution{Source: []byte("int main() {}")}
+
+	before, err := CompilationCacheKey(solution, cpp, "Fake")
+	assert.NoError(t, err)
+	writeFakeCompiler(t, compiler, "fake 1.1", installed.Add(time.Minute))
+	after, err := CompilationCacheKey(solution, cpp, "Fake")
+	assert.NoError(t, err)
+	assert.NotEqual(t, before, after, "files compiled by the old compiler must not be reused")
+}
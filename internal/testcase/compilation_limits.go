@@ -0,0 +1,92 @@
+package testcase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCompilationTimeout returned by CompileSolution when the compiler runs longer than CompilationLimits allow
+var ErrCompilationTimeout = errors.New("compilation timed out")
+
+// CompilationLimits resources available to the compiler, zero value of a field means no limit
+type CompilationLimits struct {
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// MemoryLimitMB limit of the data segment of the compiler (ulimit -d), not enforced on Windows
+	MemoryLimitMB int `json:"memoryLimitMB"`
+	// OutputLimitKB diagnostics exceeding the limit are truncated
+	OutputLimitKB int `json:"outputLimitKB"`
+}
+
+// DefaultCompilationLimits limits used when none are configured
+func DefaultCompilationLimits() CompilationLimits {
+	return CompilationLimits{TimeoutSeconds: 30, MemoryLimitMB: 2048, OutputLimitKB: 64}
+}
+
+// Validate checks if the limits can be used
+func (l CompilationLimits) Validate() error {
+	if l.TimeoutSeconds < 0 || l.MemoryLimitMB < 0 || l.OutputLimitKB < 0 {
+		return errors.New("compilation limits can't be negative")
+	}
+	return nil
+}
+
+// Timeout of the compilation, 0 if there is none
+func (l CompilationLimits) Timeout() time.Duration {
+	return time.Duration(l.TimeoutSeconds) * time.Second
+}
+
+var (
+	compilationLimits      = DefaultCompilationLimits()
+	compilationLimitsMutex sync.RWMutex
+)
+
+// SetCompilationLimits replaces limits of the compiler, e.g. with ones read from the config file
+func SetCompilationLimits(limits CompilationLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	compilationLimitsMutex.Lock()
+	defer compilationLimitsMutex.Unlock()
+	compilationLimits = limits
+	return nil
+}
+
+// CurrentCompilationLimits limits applied to the compiler
+func CurrentCompilationLimits() CompilationLimits {
+	compilationLimitsMutex.RLock()
+	defer compilationLimitsMutex.RUnlock()
+	return compilationLimits
+}
+
+// limitedBuffer keeps at most limit bytes written to it and silently discards the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit <= 0 {
+		return b.buf.Write(p)
+	}
+	room := b.limit - b.buf.Len()
+	if room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Bytes written to the buffer, with a note if some were discarded
+func (b *limitedBuffer) Bytes() []byte {
+	if !b.truncated {
+		return b.buf.Bytes()
+	}
+	return append(b.buf.Bytes(), []byte(fmt.Sprintf("\n... output truncated to %d bytes", b.limit))...)
+}
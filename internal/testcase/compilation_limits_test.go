@@ -0,0 +1,72 @@
+package testcase
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 5}
+	n, err := b.Write([]byte("abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = b.Write([]byte("defgh"))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "abcde\n... output truncated to 5 bytes", string(b.Bytes()))
+
+	unlimited := &limitedBuffer{}
+	unlimited.Write([]byte("abcdefgh"))
+	assert.Equal(t, "abcdefgh", string(unlimited.Bytes()))
+}
+
+func TestCompilationLimits_Invalid(t *testing.T) {
+	assert.Error(t, SetCompilationLimits(CompilationLimits{TimeoutSeconds: -1}))
+	assert.Equal(t, DefaultCompilationLimits(), CurrentCompilationLimits())
+}
+
+func TestCompilation_Timeout(t *testing.T) {
+	assert.NoError(t, SetCompilationLimits(CompilationLimits{TimeoutSeconds: 1}))
+	defer SetCompilationLimits(DefaultCompilationLimits())
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+
+	start := time.Now()
+	_, out, err := CompileSolution(strings.NewReader("#include </dev/urandom>\n"), mustLookupLanguage(t, DefaultLanguageID), ReleaseMode, dir)
+	assert.Equal(t, ErrCompilationTimeout, err)
+	assert.Contains(t, string(out), "compilation was aborted after 1s")
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
+
+func TestCompilation_MemoryLimit(t *testing.T) {
+	assert.NoError(t, SetCompilationLimits(CompilationLimits{MemoryLimitMB: 10}))
+	defer SetCompilationLimits(DefaultCompilationLimits())
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+
+	_, out, err := CompileSolution(strings.NewReader("#include <map>\nint main() {}\n"), mustLookupLanguage(t, DefaultLanguageID), ReleaseMode, dir)
+	assert.EqualError(t, err, "compilation failed with exit status 1")
+	assert.Contains(t, string(out), "memory exhausted")
+}
+
+func TestCompilation_OutputLimit(t *testing.T) {
+	assert.NoError(t, SetCompilationLimits(CompilationLimits{OutputLimitKB: 1}))
+	defer SetCompilationLimits(DefaultCompilationLimits())
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+
+	solution := "int main() {\n"
+	for i := 0; i < 100; i++ {
+		solution += fmt.Sprintf("undeclared_variable_%d++;\n", i)
+	}
+	solution += "}\n"
+	_, out, err := CompileSolution(strings.NewReader(solution), mustLookupLanguage(t, DefaultLanguageID), ReleaseMode, dir)
+	assert.Error(t, err)
+	assert.Contains(t, string(out), "... output truncated to 1024 bytes")
+	assert.True(t, len(out) < 1200)
+}
@@ -0,0 +1,135 @@
+package testcase
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// CompilationProfile describes how C and C++ solutions are compiled in a CompilationMode
+type CompilationProfile struct {
+	ID          CompilationMode `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	// Compilers compiler binary for every supported language family, e.g. {"c": "gcc", "cpp": "g++"}
+	Compilers map[string]string `json:"compilers"`
+	Flags     []string          `json:"flags"`
+	// Standards default language standard for every language family, used when Language.Standard is empty
+	Standards map[string]string `json:"standards"`
+	// Env additional environment variables of the compiler, in "KEY=value" form
+	Env []string `json:"env,omitempty"`
+	// Coverage gcov command which reports coverage of solutions instrumented by Flags (--coverage),
+	// it must support gcov's --json-format, e.g. ["gcov"]. Empty if the profile doesn't collect coverage.
+	Coverage []string `json:"coverage,omitempty"`
+	// Analyzers run on sources of the solution, their findings don't affect the verdict, see StaticAnalysis
+	Analyzers []StaticAnalyzer `json:"analyzers,omitempty"`
+}
+
+var analyzeFlags = []string{"-Wall", "-Werror", "-O1", "-g", "-fsanitize=address", "-fno-omit-frame-pointer"}
+
+// DefaultCompilationProfiles profiles available when none are configured
+func DefaultCompilationProfiles() []CompilationProfile {
+	return []CompilationProfile{
+		{
+			ID:          ReleaseMode,
+			Name:        "Release",
+			Description: "release compilation mode with optimization",
+			Compilers:   map[string]string{FamilyC: "gcc", FamilyCpp: "g++"},
+			Flags:       []string{"-static", "-O3"},
+			Standards:   map[string]string{FamilyC: "c11", FamilyCpp: "c++17"},
+		},
+		{
+			ID:          AnalyzeClangMode,
+			Name:        "Analyze (clang)",
+			Description: "maximizes possibility of finding bugs: all warnings are errors, address sanitizer",
+			Compilers:   map[string]string{FamilyC: "clang", FamilyCpp: "clang++"},
+			Flags:       analyzeFlags,
+			Standards:   map[string]string{FamilyC: "c11", FamilyCpp: "c++14"},
+			Analyzers:   DefaultStaticAnalyzers(),
+		},
+		{
+			ID:          AnalyzeGplusplusMode,
+			Name:        "Analyze (g++)",
+			Description: "maximizes possibility of finding bugs: all warnings are errors, address sanitizer",
+			Compilers:   map[string]string{FamilyC: "gcc", FamilyCpp: "g++"},
+			Flags:       analyzeFlags,
+			Standards:   map[string]string{FamilyC: "c11", FamilyCpp: "c++17"},
+			Analyzers:   DefaultStaticAnalyzers(),
+		},
+		{
+			ID:          CoverageMode,
+			Name:        "Coverage",
+			Description: "no optimization, shows how many times every line was executed by the tests",
+			Compilers:   map[string]string{FamilyC: "gcc", FamilyCpp: "g++"},
+			Flags:       []string{"-O0", "--coverage"},
+			Standards:   map[string]string{FamilyC: "c11", FamilyCpp: "c++17"},
+			Coverage:    []string{"gcov"},
+		},
+	}
+}
+
+// Validate checks if the profile can be used
+func (p CompilationProfile) Validate() error {
+	if p.ID == "" {
+		return errors.New("compilation profile without id")
+	}
+	if len(p.Compilers) == 0 {
+		return fmt.Errorf("compilation profile '%s' has no compilers", p.ID)
+	}
+	for _, a := range p.Analyzers {
+		if a.Name == "" || len(a.Command) == 0 {
+			return fmt.Errorf("compilation profile '%s' has an analyzer without name or command", p.ID)
+		}
+	}
+	return nil
+}
+
+// Supports returns true if solutions of the language can be compiled with this profile
+func (p CompilationProfile) Supports(lang Language) bool {
+	_, ok := p.Compilers[lang.Family]
+	return ok
+}
+
+var (
+	compilationProfiles      = DefaultCompilationProfiles()
+	compilationProfilesMutex sync.RWMutex
+)
+
+// SetCompilationProfiles replaces available compilation profiles, e.g. with ones read from the config file
+func SetCompilationProfiles(profiles []CompilationProfile) error {
+	seen := make(map[CompilationMode]bool)
+	for _, p := range profiles {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		if seen[p.ID] {
+			return fmt.Errorf("duplicated compilation profile '%s'", p.ID)
+		}
+		seen[p.ID] = true
+	}
+	compilationProfilesMutex.Lock()
+	defer compilationProfilesMutex.Unlock()
+	compilationProfiles = profiles
+	return nil
+}
+
+// CompilationProfiles all available compilation profiles, in the configured order
+func CompilationProfiles() []CompilationProfile {
+	compilationProfilesMutex.RLock()
+	defer compilationProfilesMutex.RUnlock()
+	res := make([]CompilationProfile, len(compilationProfiles))
+	copy(res, compilationProfiles)
+	return res
+}
+
+// LookupCompilationProfile finds the profile of given compilation mode
+func LookupCompilationProfile(mode CompilationMode) (CompilationProfile, bool) {
+	compilationProfilesMutex.RLock()
+	defer compilationProfilesMutex.RUnlock()
+	for _, p := range compilationProfiles {
+		if p.ID == mode {
+			return p, true
+		}
+	}
+	return CompilationProfile{}, false
+}
@@ -0,0 +1,54 @@
+package testcase
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompilationProfiles_SetAndLookup(t *testing.T) {
+	defer SetCompilationProfiles(DefaultCompilationProfiles())
+
+	custom := CompilationProfile{
+		ID:        "Debug",
+		Name:      "Debug",
+		Compilers: map[string]string{FamilyCpp: "g++"},
+		Flags:     []string{"-O0", "-g", "-DDEBUG"},
+		Standards: map[string]string{FamilyCpp: "c++11"},
+		Env:       []string{"LC_ALL=C"},
+	}
+	assert.NoError(t, SetCompilationProfiles([]CompilationProfile{custom}))
+
+	profiles := CompilationProfiles()
+	assert.Equal(t, 1, len(profiles))
+	_, ok := LookupCompilationProfile(ReleaseMode)
+	assert.False(t, ok)
+	p, ok := LookupCompilationProfile("Debug")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"-O0", "-g", "-DDEBUG"}, p.Flags)
+
+	assert.Equal(t, "g++ -std=c++11 -O0 -g -DDEBUG -x c++ - -lm -o a.out", FullCompilationCommadFor("Debug"))
+	assert.Equal(t, "g++ -std=c++17 -O0 -g -DDEBUG -x c++ - -lm -o a.out", FullCommandFor("cpp17", "Debug"))
+	assert.Equal(t, "unable to convert", FullCommandFor("c11", "Debug"))
+
+	cmd, err := CompilationCommand(mustLookupLanguage(t, "cpp14"), "Debug", os.TempDir())
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join(cmd.Env, "\n"), "LC_ALL=C")
+
+	_, err = CompilationCommand(mustLookupLanguage(t, "c11"), "Debug", os.TempDir())
+	assert.EqualError(t, err, "compilation mode 'Debug' does not support C11")
+}
+
+func TestCompilationProfiles_Invalid(t *testing.T) {
+	defer SetCompilationProfiles(DefaultCompilationProfiles())
+
+	assert.Error(t, SetCompilationProfiles([]CompilationProfile{{Name: "no id", Compilers: map[string]string{FamilyC: "gcc"}}}))
+	assert.Error(t, SetCompilationProfiles([]CompilationProfile{{ID: "NoCompilers"}}))
+	dup := CompilationProfile{ID: "A", Compilers: map[string]string{FamilyC: "gcc"}}
+	assert.Error(t, SetCompilationProfiles([]CompilationProfile{dup, dup}))
+
+	_, ok := LookupCompilationProfile(ReleaseMode)
+	assert.True(t, ok)
+}
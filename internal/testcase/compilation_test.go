@@ -3,6 +3,8 @@ package testcase
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,26 +20,75 @@ func TempFileName(prefix, suffix string) string {
 	return filepath.Join(os.TempDir(), prefix+hex.EncodeToString(randBytes)+suffix)
 }
 
+func tempBuildDir(t *testing.T) string {
+	dir := TempFileName("testcase", ".build")
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	return dir
+}
+
+func mustLookupLanguage(t *testing.T, id string) Language {
+	lang, ok := LookupLanguage(id)
+	assert.True(t, ok)
+	return lang
+}
+
+func readFile(t *testing.T, filename string) string {
+	res, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	return string(res)
+}
+
 func TestCompilation_ReleaseMode_CorrectFile(t *testing.T) {
 	solution := strings.NewReader(`#include <cstdio>
 	int main() { printf("OK!"); return 0; }`)
-	tmpFileName := TempFileName("testcase", ".tsk")
-	out, err := CompileSolution(solution, ReleaseMode, tmpFileName)
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+	command, out, err := CompileSolution(solution, mustLookupLanguage(t, DefaultLanguageID), ReleaseMode, dir)
 	assert.NoError(t, err)
 	assert.Equal(t, "", string(out))
-	os.Remove(tmpFileName)
+	assert.Equal(t, []string{filepath.Join(dir, "solution.tsk")}, command)
+	assert.FileExists(t, filepath.Join(dir, "solution.tsk"))
+	assert.FileExists(t, filepath.Join(dir, "solution.cpp"))
 }
 
 func TestCompilation_ReleaseMode_SyntaxError(t *testing.T) {
 	solution := strings.NewReader(`#include <cstdio>
 	int main() { xxx return 0; }`)
-	tmpFileName := TempFileName("testcase", ".tsk")
-	out, err := CompileSolution(solution, ReleaseMode, tmpFileName)
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+	_, out, err := CompileSolution(solution, mustLookupLanguage(t, DefaultLanguageID), ReleaseMode, dir)
 	assert.Error(t, err)
 	assert.EqualError(t, err, "compilation failed with exit status 1")
 	assert.Contains(t, string(out), "<stdin>:2:15: error")
 	assert.Contains(t, string(out), "was not declared in this scope")
-	os.Remove(tmpFileName)
+}
+
+func TestCompilation_C(t *testing.T) {
+	solution := strings.NewReader(`#include <stdio.h>
+	int main(void) { printf("OK!"); return 0; }`)
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+	command, out, err := CompileSolution(solution, mustLookupLanguage(t, "c11"), AnalyzeGplusplusMode, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(out))
+	assert.Equal(t, []string{filepath.Join(dir, "solution.tsk")}, command)
+}
+
+func TestCompilation_InterpretedLanguageHasNoCompilationStep(t *testing.T) {
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+	command, out, err := CompileSolution(strings.NewReader("print(2 * int(input()))\n"), mustLookupLanguage(t, "python3"), ReleaseMode, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(out))
+	assert.Equal(t, []string{"python3", filepath.Join(dir, "solution.py")}, command)
+	assert.Equal(t, "print(2 * int(input()))\n", readFile(t, filepath.Join(dir, "solution.py")))
+}
+
+func TestCompilation_UnknownCompilationMode(t *testing.T) {
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+	_, _, err := CompileSolution(strings.NewReader(""), mustLookupLanguage(t, "cpp17"), CompilationMode("NoSuchMode"), dir)
+	assert.EqualError(t, err, "unknown compilation mode selected")
 }
 
 func TestCompilationMode_FullCommandFor(t *testing.T) {
@@ -53,6 +104,28 @@ func TestCompilationMode_FullCommandFor(t *testing.T) {
 	s = FullCompilationCommadFor(AnalyzeGplusplusMode)
 	assert.Contains(t, s, "-std=c++17 -Wall -Werror -O1 -g -fsanitize=address -fno-omit-frame-pointer -x c++ - -lm -o a.out")
 	assert.Contains(t, s, "g++")
+
+	s = FullCommandFor("cpp20", AnalyzeClangMode)
+	assert.Contains(t, s, "-std=c++20 -Wall -Werror -O1 -g -fsanitize=address -fno-omit-frame-pointer -x c++ - -lm -o a.out")
+
+	s = FullCommandFor("c11", ReleaseMode)
+	assert.Contains(t, s, "gcc -std=c11 -static -O3 -x c - -lm -o a.out")
+
+	assert.Equal(t, "no compilation, run with: python3 solution.py", FullCommandFor("python3", ReleaseMode))
+	assert.Equal(t, "unknown language cobol", FullCommandFor("cobol", ReleaseMode))
+}
+
+func TestCompilationMode_DebugInformationForBacktraces(t *testing.T) {
+	defer SetCrashBacktraces(DefaultCrashBacktraces())
+	cpp := mustLookupLanguage(t, DefaultLanguageID)
+	withoutDebugInformation, err := CompilationCacheKey(Solution{Source: []byte("int main() {}")}, cpp, ReleaseMode)
+	assert.NoError(t, err)
+	assert.NoError(t, SetCrashBacktraces(CrashBacktraces{Enabled: true, Debugger: []string{"gdb"}}))
+	withDebugInformation, err := CompilationCacheKey(Solution{Source: []byte("int main() {}")}, cpp, ReleaseMode)
+	assert.NoError(t, err)
+	assert.NotEqual(t, withoutDebugInformation, withDebugInformation)
+	assert.Contains(t, FullCompilationCommadFor(ReleaseMode), "-std=c++17 -static -O3 -g -x c++ - -lm -o a.out")
+	assert.Contains(t, FullCompilationCommadFor(AnalyzeClangMode), "-O1 -g -fsanitize=address")
 }
 
 func TestCompilatonMode_UnmarshallJSON(t *testing.T) {
@@ -83,3 +156,16 @@ func TestCompilatonMode_MarshallJSON(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []byte("\"AnalyzeGplusplusMode\""), out)
 }
+
+func TestCompilatonMode_UnmarshallLegacyJSON(t *testing.T) {
+	var cm CompilationMode
+	assert.NoError(t, json.Unmarshal([]byte("1"), &cm))
+	assert.Equal(t, ReleaseMode, cm)
+	assert.NoError(t, json.Unmarshal([]byte("3"), &cm))
+	assert.Equal(t, AnalyzeGplusplusMode, cm)
+	assert.Error(t, json.Unmarshal([]byte("4"), &cm))
+	assert.Error(t, json.Unmarshal([]byte("{}"), &cm))
+
+	assert.NoError(t, json.Unmarshal([]byte("\"CustomProfile\""), &cm))
+	assert.Equal(t, CompilationMode("CustomProfile"), cm)
+}
@@ -0,0 +1,195 @@
+package testcase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LineCoverage how many times a line of the source was executed
+type LineCoverage struct {
+	Line  int   `json:"line"`
+	Count int64 `json:"count"`
+	// Branches number of branches leaving the line, BranchesTaken how many of them were taken at least once
+	Branches      int `json:"branches,omitempty"`
+	BranchesTaken int `json:"branchesTaken,omitempty"`
+}
+
+// FileCoverage coverage of executable lines of a single source file, sorted by line number
+type FileCoverage struct {
+	File  string         `json:"file"`
+	Lines []LineCoverage `json:"lines"`
+}
+
+// CoverageSummary totals of a CoverageReport
+type CoverageSummary struct {
+	LinesTotal    int `json:"linesTotal"`
+	LinesCovered  int `json:"linesCovered"`
+	BranchesTotal int `json:"branchesTotal"`
+	BranchesTaken int `json:"branchesTaken"`
+}
+
+// LinePercent percentage of executed lines
+func (s CoverageSummary) LinePercent() float64 {
+	if s.LinesTotal == 0 {
+		return 0
+	}
+	return 100 * float64(s.LinesCovered) / float64(s.LinesTotal)
+}
+
+// CoverageReport coverage of the solution sources aggregated over all test runs
+type CoverageReport struct {
+	Files []FileCoverage `json:"files"`
+}
+
+// Summary totals over all files of the report
+func (r CoverageReport) Summary() CoverageSummary {
+	var s CoverageSummary
+	for _, f := range r.Files {
+		for _, l := range f.Lines {
+			s.LinesTotal++
+			if l.Count > 0 {
+				s.LinesCovered++
+			}
+			s.BranchesTotal += l.Branches
+			s.BranchesTaken += l.BranchesTaken
+		}
+	}
+	return s
+}
+
+// CoverageEnabled returns true if solutions in the language compiled in the mode collect coverage
+func CoverageEnabled(lang Language, mode CompilationMode) bool {
+	if !lang.UsesCompilationMode() {
+		return false
+	}
+	profile, ok := LookupCompilationProfile(mode)
+	return ok && len(profile.Coverage) > 0
+}
+
+// gcovFile subset of a file entry of "gcov --json-format"
+type gcovFile struct {
+	File  string `json:"file"`
+	Lines []struct {
+		LineNumber int   `json:"line_number"`
+		Count      int64 `json:"count"`
+		Branches   []struct {
+			Count int64 `json:"count"`
+			Throw bool  `json:"throw"`
+		} `json:"branches"`
+	} `json:"lines"`
+}
+
+// parseGcovJSON merges gcov JSON documents (one per data file) into a report.
+// Files outside of dir, e.g. system headers, are skipped. Exception edges are not counted as branches.
+func parseGcovJSON(r io.Reader, dir string) (CoverageReport, error) {
+	files := make(map[string]map[int]*LineCoverage)
+	dec := json.NewDecoder(r)
+	for {
+		var doc struct {
+			WorkingDirectory string     `json:"current_working_directory"`
+			Files            []gcovFile `json:"files"`
+		}
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return CoverageReport{}, fmt.Errorf("invalid gcov output: %v", err)
+		}
+		for _, f := range doc.Files {
+			name, ok := solutionSourceName(f.File, dir)
+			if !ok {
+				continue
+			}
+			lines, ok := files[name]
+			if !ok {
+				lines = make(map[int]*LineCoverage)
+				files[name] = lines
+			}
+			for _, l := range f.Lines {
+				lc, ok := lines[l.LineNumber]
+				if !ok {
+					lc = &LineCoverage{Line: l.LineNumber}
+					lines[l.LineNumber] = lc
+				}
+				// a line may belong to many functions, e.g. instances of a template
+				lc.Count += l.Count
+				for _, b := range l.Branches {
+					if b.Throw {
+						continue
+					}
+					lc.Branches++
+					if b.Count > 0 {
+						lc.BranchesTaken++
+					}
+				}
+			}
+		}
+	}
+	var report CoverageReport
+	for name, lines := range files {
+		fc := FileCoverage{File: name, Lines: make([]LineCoverage, 0, len(lines))}
+		for _, l := range lines {
+			fc.Lines = append(fc.Lines, *l)
+		}
+		sort.Slice(fc.Lines, func(i, j int) bool { return fc.Lines[i].Line < fc.Lines[j].Line })
+		report.Files = append(report.Files, fc)
+	}
+	sort.Slice(report.Files, func(i, j int) bool { return report.Files[i].File < report.Files[j].File })
+	return report, nil
+}
+
+// solutionSourceName path of the source relative to dir, false if it's not a source of the solution
+func solutionSourceName(file, dir string) (string, bool) {
+	if !filepath.IsAbs(file) {
+		return filepath.ToSlash(filepath.Clean(file)), true
+	}
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// CollectCoverage reports coverage gathered by all runs of the solution compiled in dir, see CoverageEnabled
+func CollectCoverage(lang Language, mode CompilationMode, dir string) (CoverageReport, error) {
+	profile, ok := LookupCompilationProfile(mode)
+	if !ok || len(profile.Coverage) == 0 || !lang.UsesCompilationMode() {
+		return CoverageReport{}, fmt.Errorf("compilation mode '%s' doesn't collect coverage of %s", mode, lang.ID)
+	}
+	dataFiles, err := filepath.Glob(filepath.Join(dir, "*.gcda"))
+	if err != nil {
+		return CoverageReport{}, err
+	}
+	if len(dataFiles) == 0 {
+		// the solution never exited normally, e.g. all tests exceeded the time limit
+		return CoverageReport{}, nil
+	}
+	for i := range dataFiles {
+		dataFiles[i] = filepath.Base(dataFiles[i])
+	}
+	args := append(append([]string{}, profile.Coverage...), append([]string{"--branch-probabilities", "--json-format", "--stdout"}, dataFiles...)...)
+	// gcov is limited like the compiler
+	ctx := context.Background()
+	if timeout := CurrentCompilationLimits().Timeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = runInProcessGroup(ctx, cmd); err != nil {
+		return CoverageReport{}, fmt.Errorf("%s failed with %v: %s", args[0], err, stderr.String())
+	}
+	return parseGcovJSON(&stdout, dir)
+}
@@ -0,0 +1,44 @@
+package testcase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const gcovOutput = `{"format_version": "1", "current_working_directory": "/build", "data_file": "a-main.gcda", "files": [
+	{"file": "main.cpp", "lines": [
+		{"line_number": 3, "count": 2, "branches": [{"count": 2, "throw": false}, {"count": 0, "throw": true}]},
+		{"line_number": 4, "count": 0, "branches": [{"count": 0, "throw": false}, {"count": 2, "throw": false}]}]},
+	{"file": "/usr/include/c++/12/bits/stl_vector.h", "lines": [{"line_number": 100, "count": 7, "branches": []}]}]}
+{"format_version": "1", "current_working_directory": "/build", "data_file": "a-lib.gcda", "files": [
+	{"file": "/build/lib/util.h", "lines": [
+		{"line_number": 1, "count": 1, "branches": []},
+		{"line_number": 1, "count": 3, "branches": []}]}]}
+`
+
+func TestParseGcovJSON(t *testing.T) {
+	report, err := parseGcovJSON(strings.NewReader(gcovOutput), "/build")
+	assert.NoError(t, err)
+	assert.Equal(t, CoverageReport{Files: []FileCoverage{
+		{File: "lib/util.h", Lines: []LineCoverage{{Line: 1, Count: 4}}},
+		{File: "main.cpp", Lines: []LineCoverage{
+			{Line: 3, Count: 2, Branches: 1, BranchesTaken: 1},
+			{Line: 4, Count: 0, Branches: 2, BranchesTaken: 1}}},
+	}}, report)
+	summary := report.Summary()
+	assert.Equal(t, CoverageSummary{LinesTotal: 3, LinesCovered: 2, BranchesTotal: 3, BranchesTaken: 2}, summary)
+	assert.InDelta(t, 66.67, summary.LinePercent(), 0.01)
+
+	_, err = parseGcovJSON(strings.NewReader("{"), "/build")
+	assert.Error(t, err)
+}
+
+func TestCoverageEnabled(t *testing.T) {
+	assert.True(t, CoverageEnabled(mustLookupLanguage(t, "cpp17"), CoverageMode))
+	assert.False(t, CoverageEnabled(mustLookupLanguage(t, "cpp17"), ReleaseMode))
+	assert.False(t, CoverageEnabled(mustLookupLanguage(t, "python3"), CoverageMode))
+	_, err := CollectCoverage(mustLookupLanguage(t, "cpp17"), ReleaseMode, ".")
+	assert.EqualError(t, err, "compilation mode 'ReleaseMode' doesn't collect coverage of cpp17")
+}
@@ -0,0 +1,56 @@
+package testcase
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic message reported by the compiler about a location in the source
+type Diagnostic struct {
+	// Severity one of: "fatal error", "error", "warning", "note"
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+	// Option compiler option which controls the diagnostic, e.g. "-Wunused-variable"
+	Option string       `json:"option,omitempty"`
+	Notes  []Diagnostic `json:"notes,omitempty"`
+}
+
+// IsError returns true if the diagnostic made the compilation fail
+func (d Diagnostic) IsError() bool {
+	return d.Severity == "error" || d.Severity == "fatal error"
+}
+
+// diagnosticPattern matches "file:line:column: severity: message [-Woption]" lines printed by GCC and Clang
+var diagnosticPattern = regexp.MustCompile(`(?:^|\s)(\S+?):(\d+):(?:(\d+):)? (fatal error|error|warning|note): (.*?)(?: \[(-[A-Za-z][^\]]*)\])?$`)
+
+// ParseDiagnostics extracts diagnostics from output of GCC or Clang.
+// Notes are attached to the preceding error or warning, lines which are not diagnostics are skipped.
+func ParseDiagnostics(output []byte) []Diagnostic {
+	var res []Diagnostic
+	for _, line := range strings.Split(string(output), "\n") {
+		m := diagnosticPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		d := Diagnostic{File: m[1], Severity: m[4], Message: strings.TrimSpace(m[5]), Option: m[6]}
+		d.Line, _ = strconv.Atoi(m[2])
+		d.Column, _ = strconv.Atoi(m[3])
+		if d.Severity == "note" && len(res) > 0 {
+			last := &res[len(res)-1]
+			last.Notes = append(last.Notes, d)
+			continue
+		}
+		res = append(res, d)
+	}
+	return res
+}
+
+// IsSolutionFile returns true if the file reported by the compiler is the solution source, see CompileSolution
+func (lang Language) IsSolutionFile(file string) bool {
+	return file == "<stdin>" || filepath.Base(file) == lang.SourceFile()
+}
@@ -0,0 +1,52 @@
+package testcase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiagnostics_Gcc(t *testing.T) {
+	output := `exit status 1. Output: <stdin>: In function 'int main()':
+<stdin>:5:3: error: 'xx' was not declared in this scope
+    5 |   xx++;
+      |   ^~
+<stdin>:6:5: error: invalid conversion from 'const char*' to 'int' [-fpermissive]
+<stdin>:2:7: note:   initializing argument 1 of 'int f(int)'
+<stdin>:4:7: warning: unused variable 'unused' [-Wunused-variable]
+cc1plus: all warnings being treated as errors
+`
+	diags := ParseDiagnostics([]byte(output))
+	assert.Equal(t, []Diagnostic{
+		{Severity: "error", File: "<stdin>", Line: 5, Column: 3, Message: "'xx' was not declared in this scope"},
+		{Severity: "error", File: "<stdin>", Line: 6, Column: 5, Message: "invalid conversion from 'const char*' to 'int'", Option: "-fpermissive",
+			Notes: []Diagnostic{{Severity: "note", File: "<stdin>", Line: 2, Column: 7, Message: "initializing argument 1 of 'int f(int)'"}}},
+		{Severity: "warning", File: "<stdin>", Line: 4, Column: 7, Message: "unused variable 'unused'", Option: "-Wunused-variable"},
+	}, diags)
+	assert.True(t, diags[0].IsError())
+	assert.False(t, diags[2].IsError())
+}
+
+func TestParseDiagnostics_Clang(t *testing.T) {
+	output := `<stdin>:3:9: warning: unused variable 'x' [-Wunused-variable]
+    int x;
+        ^
+/usr/include/stdio.h:10: fatal error: 'foo.h' file not found
+1 warning and 1 error generated.
+`
+	assert.Equal(t, []Diagnostic{
+		{Severity: "warning", File: "<stdin>", Line: 3, Column: 9, Message: "unused variable 'x'", Option: "-Wunused-variable"},
+		{Severity: "fatal error", File: "/usr/include/stdio.h", Line: 10, Message: "'foo.h' file not found"},
+	}, ParseDiagnostics([]byte(output)))
+}
+
+func TestParseDiagnostics_NoDiagnostics(t *testing.T) {
+	assert.Empty(t, ParseDiagnostics([]byte("/usr/bin/ld: cannot find -lfoo\ncollect2: error: ld returned 1 exit status\n")))
+	assert.Empty(t, ParseDiagnostics(nil))
+}
+
+func TestLanguage_IsSolutionFile(t *testing.T) {
+	assert.True(t, mustLookupLanguage(t, DefaultLanguageID).IsSolutionFile("<stdin>"))
+	assert.True(t, mustLookupLanguage(t, "java").IsSolutionFile("/tmp/build123/Main.java"))
+	assert.False(t, mustLookupLanguage(t, "java").IsSolutionFile("/tmp/build123/Other.java"))
+}
@@ -0,0 +1,204 @@
+package testcase
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Language describes how solutions written in a programming language are compiled and run.
+// Compile and Run are command templates which may contain following placeholders:
+//
+//	{source}     path to the solution source file, for archives the file with the entry point
+//	{sources}    all translation units of the solution relative to {dir}, expanded into separate arguments
+//	{executable} path where the compiled executable should be written
+//	{dir}        build directory, where the source file is located
+//	{compiler}   compiler binary selected by the CompilationMode (C and C++ only)
+//	{flags}      compiler flags selected by the CompilationMode, expanded into separate arguments (C and C++ only)
+//	{std}        language standard, the CompilationMode default is used if Language.Standard is empty
+type Language struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Family    string `json:"family"`
+	Extension string `json:"extension"`
+	// SourceFilename name of the source file in the build directory, "solution"+Extension if empty
+	SourceFilename string   `json:"sourceFilename,omitempty"`
+	Standard       string   `json:"standard,omitempty"`
+	Compile        []string `json:"compile,omitempty"` // nil if there is no compilation step
+	// CompileMultiple used instead of Compile for solutions submitted as archives, if set
+	CompileMultiple []string `json:"compileMultiple,omitempty"`
+	Run             []string `json:"run"`
+	// SourceExtensions extensions of translation units in archives, Extension if empty
+	SourceExtensions []string `json:"sourceExtensions,omitempty"`
+	// TimeLimitMultiplier and MemoryLimitMultiplier scale limits of test cases for slower languages
+	TimeLimitMultiplier   float64 `json:"timeLimitMultiplier"`
+	MemoryLimitMultiplier float64 `json:"memoryLimitMultiplier"`
+}
+
+const (
+	// FamilyC C language
+	FamilyC = "c"
+	// FamilyCpp C++ language
+	FamilyCpp = "cpp"
+
+	// DefaultLanguageID language of submissions which were created before languages were introduced
+	DefaultLanguageID = "cpp"
+)
+
+func cLanguage(id, name, std string) Language {
+	return Language{
+		ID: id, Name: name, Family: FamilyC, Extension: ".c", Standard: std,
+		Compile:             []string{"{compiler}", "-std={std}", "{flags}", "-x", "c", "-", "-lm", "-o", "{executable}"},
+		CompileMultiple:     []string{"{compiler}", "-std={std}", "{flags}", "-I{dir}", "{sources}", "-lm", "-o", "{executable}"},
+		Run:                 []string{"{executable}"},
+		TimeLimitMultiplier: 1, MemoryLimitMultiplier: 1,
+	}
+}
+
+func cppLanguage(id, name, std string) Language {
+	return Language{
+		ID: id, Name: name, Family: FamilyCpp, Extension: ".cpp", Standard: std,
+		Compile:             []string{"{compiler}", "-std={std}", "{flags}", "-x", "c++", "-", "-lm", "-o", "{executable}"},
+		CompileMultiple:     []string{"{compiler}", "-std={std}", "{flags}", "-I{dir}", "{sources}", "-lm", "-o", "{executable}"},
+		Run:                 []string{"{executable}"},
+		SourceExtensions:    []string{".cpp", ".cc", ".cxx"},
+		TimeLimitMultiplier: 1, MemoryLimitMultiplier: 1,
+	}
+}
+
+var languages = map[string]Language{
+	DefaultLanguageID: cppLanguage(DefaultLanguageID, "C++ (standard of the compilation mode)", ""),
+	"cpp11":           cppLanguage("cpp11", "C++11", "c++11"),
+	"cpp14":           cppLanguage("cpp14", "C++14", "c++14"),
+	"cpp17":           cppLanguage("cpp17", "C++17", "c++17"),
+	"cpp20":           cppLanguage("cpp20", "C++20", "c++20"),
+	"c11":             cLanguage("c11", "C11", "c11"),
+	"java": {
+		ID: "java", Name: "Java", Family: "java", Extension: ".java", SourceFilename: "Main.java",
+		Compile:             []string{"javac", "-encoding", "UTF-8", "-d", "{dir}", "{source}"},
+		CompileMultiple:     []string{"javac", "-encoding", "UTF-8", "-d", "{dir}", "{sources}"},
+		Run:                 []string{"java", "-Xss64m", "-cp", "{dir}", "Main"},
+		TimeLimitMultiplier: 2, MemoryLimitMultiplier: 2,
+	},
+	"go": {
+		ID: "go", Name: "Go", Family: "go", Extension: ".go",
+		Compile:             []string{"go", "build", "-o", "{executable}", "{source}"},
+		CompileMultiple:     []string{"go", "build", "-o", "{executable}", "{sources}"},
+		Run:                 []string{"{executable}"},
+		TimeLimitMultiplier: 1, MemoryLimitMultiplier: 1,
+	},
+	"rust": {
+		ID: "rust", Name: "Rust", Family: "rust", Extension: ".rs",
+		Compile:             []string{"rustc", "-O", "--edition", "2018", "-o", "{executable}", "{source}"},
+		Run:                 []string{"{executable}"},
+		TimeLimitMultiplier: 1, MemoryLimitMultiplier: 1,
+	},
+	"python3": {
+		ID: "python3", Name: "Python 3", Family: "python", Extension: ".py",
+		Run:                 []string{"python3", "{source}"},
+		TimeLimitMultiplier: 3, MemoryLimitMultiplier: 2,
+	},
+	"javascript": {
+		ID: "javascript", Name: "JavaScript (Node.js)", Family: "javascript", Extension: ".js",
+		Run:                 []string{"node", "{source}"},
+		TimeLimitMultiplier: 2, MemoryLimitMultiplier: 2,
+	},
+}
+
+// LookupLanguage finds registered language by its ID, empty ID means DefaultLanguageID
+func LookupLanguage(id string) (Language, bool) {
+	if id == "" {
+		id = DefaultLanguageID
+	}
+	lang, ok := languages[id]
+	return lang, ok
+}
+
+// Languages all registered languages sorted by name
+func Languages() []Language {
+	res := make([]Language, 0, len(languages))
+	for _, lang := range languages {
+		res = append(res, lang)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// UsesCompilationMode returns true if the CompilationMode affects how the solution is compiled
+func (lang Language) UsesCompilationMode() bool {
+	for _, arg := range lang.Compile {
+		if strings.Contains(arg, "{compiler}") || strings.Contains(arg, "{flags}") {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceFile name of the solution source file in the build directory
+func (lang Language) SourceFile() string {
+	if lang.SourceFilename != "" {
+		return lang.SourceFilename
+	}
+	return "solution" + lang.Extension
+}
+
+// usesEntry returns true if commands of multi-file solutions need to know the file with the entry point
+func (lang Language) usesEntry() bool {
+	for _, arg := range append(lang.compileTemplate(sourceFiles{units: []string{}}), lang.Run...) {
+		if strings.Contains(arg, "{source}") {
+			return true
+		}
+	}
+	return false
+}
+
+// archivedSourceFiles finds translation units and the entry file among files unpacked from an archive
+func (lang Language) archivedSourceFiles(names []string, entry string) (sourceFiles, error) {
+	extensions := lang.SourceExtensions
+	if len(extensions) == 0 {
+		extensions = []string{lang.Extension}
+	}
+	files := sourceFiles{units: make([]string, 0)}
+	present := make(map[string]bool)
+	for _, name := range names {
+		present[name] = true
+		for _, ext := range extensions {
+			if path.Ext(name) == ext {
+				files.units = append(files.units, name)
+			}
+		}
+	}
+	if len(files.units) == 0 {
+		return files, fmt.Errorf("archive has no %s source files (%s)", lang.Name, strings.Join(extensions, ", "))
+	}
+	switch {
+	case entry != "":
+		entry = path.Clean(entry)
+		if !present[entry] {
+			return files, fmt.Errorf("entry file '%s' is not in the archive", entry)
+		}
+		files.entry = entry
+	case present[lang.SourceFile()]:
+		files.entry = lang.SourceFile()
+	case present["main"+lang.Extension]:
+		files.entry = "main" + lang.Extension
+	case len(files.units) == 1:
+		files.entry = files.units[0]
+	case lang.usesEntry():
+		return files, fmt.Errorf("archive has %d source files, choose the entry file", len(files.units))
+	}
+	return files, nil
+}
+
+// ScaleLimits applies limit multipliers of the language to the test case
+func (lang Language) ScaleLimits(info Info) Info {
+	if lang.TimeLimitMultiplier > 0 {
+		info.TimeLimit = time.Duration(float64(info.TimeLimit) * lang.TimeLimitMultiplier)
+	}
+	if lang.MemoryLimitMultiplier > 0 {
+		info.MemoryLimit = int(float64(info.MemoryLimit) * lang.MemoryLimitMultiplier)
+	}
+	return info
+}
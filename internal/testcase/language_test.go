@@ -0,0 +1,50 @@
+package testcase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanguage_LookupDefault(t *testing.T) {
+	lang, ok := LookupLanguage("")
+	assert.True(t, ok)
+	assert.Equal(t, DefaultLanguageID, lang.ID)
+	assert.Equal(t, "solution.cpp", lang.SourceFile())
+
+	_, ok = LookupLanguage("cobol")
+	assert.False(t, ok)
+}
+
+func TestLanguage_UsesCompilationMode(t *testing.T) {
+	for _, id := range []string{"cpp", "cpp17", "c11"} {
+		lang, _ := LookupLanguage(id)
+		assert.True(t, lang.UsesCompilationMode(), id)
+	}
+	for _, id := range []string{"java", "go", "rust", "python3", "javascript"} {
+		lang, _ := LookupLanguage(id)
+		assert.False(t, lang.UsesCompilationMode(), id)
+	}
+}
+
+func TestLanguage_ScaleLimits(t *testing.T) {
+	java, _ := LookupLanguage("java")
+	assert.Equal(t, "Main.java", java.SourceFile())
+	info := java.ScaleLimits(NewInfo("t1", 3*time.Second, 1000))
+	assert.Equal(t, 6*time.Second, info.TimeLimit)
+	assert.Equal(t, 2000, info.MemoryLimit)
+
+	cpp, _ := LookupLanguage("cpp17")
+	info = cpp.ScaleLimits(NewInfo("t1", 3*time.Second, 1000))
+	assert.Equal(t, 3*time.Second, info.TimeLimit)
+	assert.Equal(t, 1000, info.MemoryLimit)
+}
+
+func TestLanguages_SortedByName(t *testing.T) {
+	all := Languages()
+	assert.Equal(t, len(languages), len(all))
+	for i := 1; i < len(all); i++ {
+		assert.True(t, all[i-1].Name < all[i].Name)
+	}
+}
@@ -0,0 +1,96 @@
+package testcase
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ProblemConfigFilename name of the optional configuration file in the problem directory
+const ProblemConfigFilename = "problem.json"
+
+// ProblemConfig configuration of a problem, read from ProblemConfigFilename in its directory
+type ProblemConfig struct {
+	// Graders for function-only solutions, by language family
+	Graders map[string]Grader `json:"graders,omitempty"`
+}
+
+// Grader files provided by the problem which are compiled together with the contestant's solution.
+// The grader has the entry point (e.g. main()) and calls functions implemented by the contestant.
+type Grader struct {
+	// Files grader sources and headers, paths relative to the problem directory
+	Files []string `json:"files"`
+	// Entry file with the entry point, needed only by languages which run a single file, e.g. "grader.py"
+	Entry string `json:"entry,omitempty"`
+	// Stub template of the solution offered for download, path relative to the problem directory
+	Stub string `json:"stub,omitempty"`
+	// SolutionFilename name of the contestant's source file next to grader files, Language.SourceFile() if empty
+	SolutionFilename string `json:"solutionFilename,omitempty"`
+}
+
+// Grader for the language, false if solutions in this language are complete programs
+func (c ProblemConfig) Grader(lang Language) (Grader, bool) {
+	g, ok := c.Graders[lang.Family]
+	return g, ok
+}
+
+// SolutionFilenameFor name of the contestant's source file in given language
+func (g Grader) SolutionFilenameFor(lang Language) string {
+	if g.SolutionFilename != "" {
+		return g.SolutionFilename
+	}
+	return lang.SourceFile()
+}
+
+// problemFilePath path of a file in the problem directory, files outside of it are rejected
+func problemFilePath(problemDir, name string) (string, error) {
+	clean := path.Clean(strings.Replace(name, "\\", "/", -1))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("file '%s' is outside of the problem directory", name)
+	}
+	return filepath.Join(problemDir, filepath.FromSlash(clean)), nil
+}
+
+func readProblemConfig(problemDir string) (ProblemConfig, error) {
+	var config ProblemConfig
+	content, err := ioutil.ReadFile(filepath.Join(problemDir, ProblemConfigFilename))
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err = json.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("invalid %s: %v", ProblemConfigFilename, err)
+	}
+	return config, nil
+}
+
+// WithGrader adds grader files of the problem to the solution, if the problem has a grader for the language
+func WithGrader(archive Archive, problemName string, lang Language, solution Solution) (Solution, error) {
+	config, err := archive.Config(problemName)
+	if err != nil {
+		return solution, err
+	}
+	grader, ok := config.Grader(lang)
+	if !ok {
+		return solution, nil
+	}
+	solution.Extra = make(map[string][]byte, len(grader.Files))
+	for _, name := range grader.Files {
+		content, err := archive.ProblemFile(problemName, name)
+		if err != nil {
+			return solution, fmt.Errorf("unable to read grader file: %v", err)
+		}
+		solution.Extra[path.Clean(name)] = content
+	}
+	solution.SourceFilename = grader.SolutionFilenameFor(lang)
+	if grader.Entry != "" {
+		solution.Entry = grader.Entry
+	}
+	return solution, nil
+}
@@ -0,0 +1,97 @@
+package testcase
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeProblem(t *testing.T, files map[string]string) (Archive, func()) {
+	dir := tempBuildDir(t)
+	for name, content := range files {
+		filename := filepath.Join(dir, "problem", name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(content), 0644))
+	}
+	return NewArchive(dir), func() { os.RemoveAll(dir) }
+}
+
+var graderProblem = map[string]string{
+	ProblemConfigFilename: `{"graders": {
+		"cpp": {"files": ["grader/grader.cpp", "grader/twice.h"], "stub": "grader/stub.cpp"},
+		"python": {"files": ["grader.py"], "entry": "grader.py", "solutionFilename": "twice.py"}
+	}}`,
+	"grader/grader.cpp": "#include <cstdio>\n#include \"twice.h\"\nint main() { int x; scanf(\"%d\", &x); printf(\"%d\\n\", twice(x)); }\n",
+	"grader/twice.h":    "int twice(int x);\n",
+	"grader/stub.cpp":   "#include \"grader/twice.h\"\nint twice(int x) {\n  return 0;\n}\n",
+	"grader.py":         "from twice import twice\nprint(twice(int(input())))\n",
+	"t1.in":             "21\n",
+	"t1.out":            "42\n",
+}
+
+func TestArchive_Config(t *testing.T) {
+	archive, cleanup := writeProblem(t, graderProblem)
+	defer cleanup()
+
+	config, err := archive.Config("problem")
+	assert.NoError(t, err)
+	grader, ok := config.Grader(mustLookupLanguage(t, "cpp17"))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"grader/grader.cpp", "grader/twice.h"}, grader.Files)
+	assert.Equal(t, "solution.cpp", grader.SolutionFilenameFor(mustLookupLanguage(t, "cpp17")))
+	_, ok = config.Grader(mustLookupLanguage(t, "java"))
+	assert.False(t, ok)
+
+	stub, err := archive.ProblemFile("problem", grader.Stub)
+	assert.NoError(t, err)
+	assert.Contains(t, string(stub), "int twice(int x)")
+	_, err = archive.ProblemFile("problem", "../../etc/passwd")
+	assert.EqualError(t, err, "file '../../etc/passwd' is outside of the problem directory")
+
+	config, err = archive.Config("no-such-problem")
+	assert.NoError(t, err)
+	assert.Empty(t, config.Graders)
+}
+
+func TestArchive_InvalidConfig(t *testing.T) {
+	archive, cleanup := writeProblem(t, map[string]string{ProblemConfigFilename: "{"})
+	defer cleanup()
+	_, err := archive.Config("problem")
+	assert.Error(t, err)
+}
+
+func TestCompile_WithGrader(t *testing.T) {
+	archive, cleanup := writeProblem(t, graderProblem)
+	defer cleanup()
+	solutions := map[string]string{
+		"cpp17":   "#include \"grader/twice.h\"\nint twice(int x) { return 2 * x; }\n",
+		"python3": "def twice(x):\n    return 2 * x\n",
+	}
+	for id, source := range solutions {
+		lang := mustLookupLanguage(t, id)
+		solution, err := WithGrader(archive, "problem", lang, Solution{Source: []byte(source)})
+		assert.NoError(t, err)
+		dir := tempBuildDir(t)
+		defer os.RemoveAll(dir)
+
+		command, out, err := Compile(solution, lang, ReleaseMode, dir)
+		assert.NoError(t, err, string(out))
+		result := runTestWithTmpOutput(command, NewInfo("t1", 10*time.Second, 0),
+			Streams{Input: strings.NewReader("21\n"), Output: strings.NewReader("42\n")})
+		assert.Equal(t, Accepted, result.Status, "%s: %s", id, result.Description)
+	}
+}
+
+func TestWithGrader_NoGrader(t *testing.T) {
+	archive, cleanup := writeProblem(t, map[string]string{"t1.in": "1\n"})
+	defer cleanup()
+	solution := Solution{Source: []byte("int main() {}")}
+	res, err := WithGrader(archive, "problem", mustLookupLanguage(t, "cpp17"), solution)
+	assert.NoError(t, err)
+	assert.Equal(t, solution, res)
+}
@@ -0,0 +1,160 @@
+package testcase
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// processRegistry keeps track of process groups started for test runs.
+// Every group is also recorded as a file in a directory, so that groups orphaned
+// by a crashed server can be found and killed on the next startup.
+type processRegistry struct {
+	dir    string
+	m      sync.Mutex
+	active map[int]string
+}
+
+var processGroups = newProcessRegistry(filepath.Join(os.TempDir(), "inout_tester-processes"))
+
+func newProcessRegistry(dir string) *processRegistry {
+	return &processRegistry{
+		dir:    dir,
+		active: make(map[int]string),
+	}
+}
+
+func (r *processRegistry) entryFilename(pgid int) string {
+	return filepath.Join(r.dir, strconv.Itoa(pgid))
+}
+
+func (r *processRegistry) add(pgid int, executable string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.active[pgid] = executable
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		log.Println("unable to create process registry directory:", err)
+		return
+	}
+	entry := fmt.Sprintf("%d %s", os.Getpid(), executable)
+	if err := ioutil.WriteFile(r.entryFilename(pgid), []byte(entry), 0644); err != nil {
+		log.Println("unable to record process group:", err)
+	}
+}
+
+// release kills whatever is left of the process group and forgets about it
+func (r *processRegistry) release(pgid int) {
+	if err := killProcessGroup(pgid); err != nil {
+		log.Printf("unable to kill process group %d: %v\n", pgid, err)
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	delete(r.active, pgid)
+	os.Remove(r.entryFilename(pgid))
+}
+
+func (r *processRegistry) killAll() {
+	r.m.Lock()
+	pgids := make([]int, 0, len(r.active))
+	for pgid := range r.active {
+		pgids = append(pgids, pgid)
+	}
+	r.m.Unlock()
+	for _, pgid := range pgids {
+		r.release(pgid)
+	}
+}
+
+// sweep kills process groups recorded by server instances which are no longer running
+func (r *processRegistry) sweep() (killed int, err error) {
+	files, err := ioutil.ReadDir(r.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	booted := bootTime()
+	for _, f := range files {
+		pgid, err := strconv.Atoi(f.Name())
+		if err != nil {
+			continue
+		}
+		content, err := ioutil.ReadFile(r.entryFilename(pgid))
+		if err != nil {
+			continue
+		}
+		fields := strings.SplitN(string(content), " ", 2)
+		owner, err := strconv.Atoi(fields[0])
+		if err != nil {
+			os.Remove(r.entryFilename(pgid))
+			continue
+		}
+		if owner != os.Getpid() && processAlive(owner) {
+			// belongs to another running instance
+			continue
+		}
+		r.m.Lock()
+		_, ours := r.active[pgid]
+		r.m.Unlock()
+		if ours {
+			continue
+		}
+		// entries older than the last boot refer to process groups which no longer exist,
+		// the ID might have been reused by an unrelated process since then
+		if f.ModTime().After(booted) && processGroupAlive(pgid) {
+			log.Printf("killing orphaned process group %d (%s)\n", pgid, strings.Join(fields[1:], " "))
+			if err := killProcessGroup(pgid); err != nil {
+				log.Printf("unable to kill orphaned process group %d: %v\n", pgid, err)
+			} else {
+				killed++
+			}
+		}
+		os.Remove(r.entryFilename(pgid))
+	}
+	return killed, nil
+}
+
+// runInProcessGroup starts the command as a leader of a new process group and waits for it.
+// When ctx is done before the command exits the whole group is killed. In either case nothing
+// started by the command is left running after it returns.
+func runInProcessGroup(ctx context.Context, cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pgid := cmd.Process.Pid
+	processGroups.add(pgid, cmd.Path)
+	defer processGroups.release(pgid)
+
+	waitResult := make(chan error, 1)
+	go func() { waitResult <- cmd.Wait() }()
+	select {
+	case err := <-waitResult:
+		return err
+	case <-ctx.Done():
+		if err := killProcessGroup(pgid); err != nil {
+			log.Printf("unable to kill process group %d: %v\n", pgid, err)
+		}
+		return <-waitResult
+	}
+}
+
+// KillAllProcessGroups kills every process tree started by test runs which are still in progress.
+// Should be called on shutdown.
+func KillAllProcessGroups() {
+	processGroups.killAll()
+}
+
+// SweepOrphanedProcessGroups kills process trees left running by a previous instance of the server,
+// e.g. after a crash. Should be called on startup.
+func SweepOrphanedProcessGroups() (killed int, err error) {
+	return processGroups.sweep()
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package testcase
+
+import "time"
+
+// bootTime is unknown on this platform, every recorded process group is considered
+func bootTime() time.Time {
+	return time.Time{}
+}
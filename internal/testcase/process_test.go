@@ -0,0 +1,116 @@
+//go:build linux
+// +build linux
+
+package testcase
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitUntilDead waits until the process exits, a zombie waiting for its new parent to reap it is dead too
+func waitUntilDead(pid int) bool {
+	for i := 0; i < 100; i++ {
+		if syscall.Kill(pid, 0) == syscall.ESRCH {
+			return true
+		}
+		stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		// the state follows the command name in parentheses
+		if err == nil && strings.HasPrefix(string(stat[strings.LastIndex(string(stat), ")")+1:]), " Z") {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestRunTestCase_TimeLimitExceededKillsWholeTree(t *testing.T) {
+	info := Info{Name: "test1", TimeLimit: 500 * time.Millisecond}
+	streams := Streams{
+		Input:  strings.NewReader("1\n"),
+		Output: strings.NewReader("2\n"),
+	}
+	stdout, err := ioutil.TempFile(os.TempDir(), "tempstd-*.out")
+	assert.NoError(t, err)
+	defer os.Remove(stdout.Name())
+	defer stdout.Close()
+	stderr, err := ioutil.TempFile(os.TempDir(), "temperr-*.out")
+	assert.NoError(t, err)
+	defer os.Remove(stderr.Name())
+	defer stderr.Close()
+
+	res := RunTest([]string{"testdata/spawn_child.exe"}, info, streams, stdout, stderr)
+	assert.Equal(t, TimeLimitExceeded, res.Status)
+
+	output, err := ioutil.ReadFile(stdout.Name())
+	assert.NoError(t, err)
+	childPid, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	assert.NoError(t, err)
+	assert.True(t, waitUntilDead(childPid), "grandchild process %d is still running", childPid)
+}
+
+func TestRunTestCase_CancelKillsWholeTree(t *testing.T) {
+	info := Info{Name: "test1", TimeLimit: 10 * time.Second}
+	streams := Streams{
+		Input:  strings.NewReader("1\n"),
+		Output: strings.NewReader("2\n"),
+	}
+	stdout, err := ioutil.TempFile(os.TempDir(), "tempstd-*.out")
+	assert.NoError(t, err)
+	defer os.Remove(stdout.Name())
+	defer stdout.Close()
+	stderr, err := ioutil.TempFile(os.TempDir(), "temperr-*.out")
+	assert.NoError(t, err)
+	defer os.Remove(stderr.Name())
+	defer stderr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	res := RunTestContext(ctx, []string{"testdata/spawn_child.exe"}, info, streams, stdout, stderr)
+	assert.Equal(t, InternalError, res.Status)
+	assert.Equal(t, "test case 'test1' was cancelled", res.Description)
+
+	output, err := ioutil.ReadFile(stdout.Name())
+	assert.NoError(t, err)
+	childPid, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	assert.NoError(t, err)
+	assert.True(t, waitUntilDead(childPid), "grandchild process %d is still running", childPid)
+}
+
+func TestProcessRegistry_SweepKillsOrphans(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testprocesses-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	registry := newProcessRegistry(dir)
+
+	// an owner which is surely not running anymore
+	owner := exec.Command("true")
+	assert.NoError(t, owner.Run())
+
+	orphan := exec.Command("testdata/infinite_loop.exe")
+	setProcessGroup(orphan)
+	assert.NoError(t, orphan.Start())
+	entry := fmt.Sprintf("%d %s", owner.Process.Pid, orphan.Path)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(orphan.Process.Pid)), []byte(entry), 0644))
+
+	killed, err := registry.sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, killed)
+	orphan.Wait()
+	assert.True(t, waitUntilDead(orphan.Process.Pid))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(files))
+}
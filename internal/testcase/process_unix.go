@@ -0,0 +1,62 @@
+//go:build !windows
+// +build !windows
+
+package testcase
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func killProcessGroup(pgid int) error {
+	err := syscall.Kill(-pgid, syscall.SIGKILL)
+	if err == syscall.ESRCH {
+		return nil
+	}
+	return err
+}
+
+func processGroupAlive(pgid int) bool {
+	return syscall.Kill(-pgid, 0) == nil
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// withMemoryLimit wraps the command so that its data segment can't grow over limitBytes, 0 means no limit
+func withMemoryLimit(args []string, limitBytes int64) []string {
+	if limitBytes <= 0 {
+		return args
+	}
+	return append([]string{"/bin/sh", "-c", `ulimit -d "$0" && exec "$@"`, strconv.FormatInt(limitBytes>>10, 10)}, args...)
+}
+
+// crashSignals signals which mean that the program crashed, by their names
+var crashSignals = map[syscall.Signal]string{
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGABRT: "SIGABRT",
+}
+
+// crashSignal name and description of the signal which crashed the process, false if it wasn't a crash
+func crashSignal(state *os.ProcessState) (name, description string, crashed bool) {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return "", "", false
+	}
+	name, crashed = crashSignals[status.Signal()]
+	return name, status.Signal().String(), crashed
+}
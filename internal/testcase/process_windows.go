@@ -0,0 +1,46 @@
+package testcase
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// killProcessGroup kills the whole process tree rooted at given pid
+func killProcessGroup(pid int) error {
+	if !processAlive(pid) {
+		return nil
+	}
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run()
+}
+
+func processGroupAlive(pid int) bool {
+	return processAlive(pid)
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// withMemoryLimit memory limits are not supported on Windows
+func withMemoryLimit(args []string, limitBytes int64) []string {
+	return args
+}
+
+// crashSignal there are no signals on Windows, crashes are reported by the exit code
+func crashSignal(state *os.ProcessState) (name, description string, crashed bool) {
+	return "", "", false
+}
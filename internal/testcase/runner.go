@@ -14,7 +14,9 @@ import (
 )
 
 type Runner interface {
-	Run(executable string, info Info) Result
+	// Run runs the solution with the command, e.g. path to the executable followed by arguments.
+	// The run is aborted when ctx is cancelled.
+	Run(ctx context.Context, command []string, info Info) Result
 }
 
 type defaultRunner struct {
@@ -33,6 +35,10 @@ type Result struct {
 	Status      Status        `json:"status"`
 	Description string        `json:"description"`
 	Duration    time.Duration `json:"duration"`
+	// Signal name of the signal which crashed the solution, e.g. "SIGSEGV"
+	Signal string `json:"signal,omitempty"`
+	// Backtrace of the crash, see CrashBacktraces
+	Backtrace []StackFrame `json:"backtrace,omitempty"`
 }
 
 type CompletedTestCase struct {
@@ -55,17 +61,40 @@ func NewInfo(testName string, timeLimit time.Duration, memoryLimit int) Info {
 	}
 }
 
-func (r *defaultRunner) Run(executable string, info Info) Result {
+func (r *defaultRunner) Run(ctx context.Context, command []string, info Info) Result {
 	streams, err := r.streamsProvider(info)
 	if err != nil {
 		return Result{Status: InternalError, Description: fmt.Sprintf("unable to open data streams, %v", err)}
 	}
 	defer streams.Close()
 
-	return runTestWithTmpOutput(executable, info, streams)
+	result := runTestContextWithTmpOutput(ctx, command, info, streams)
+	if result.Signal != "" && ctx.Err() == nil {
+		result.Backtrace = r.backtrace(command, info)
+	}
+	return result
+}
+
+// backtrace runs the crashed test again under the debugger, nil if backtraces are disabled or unavailable
+func (r *defaultRunner) backtrace(command []string, info Info) []StackFrame {
+	streams, err := r.streamsProvider(info)
+	if err != nil {
+		log.Println("unable to reopen data streams for backtrace:", err)
+		return nil
+	}
+	defer streams.Close()
+	frames, err := CollectBacktrace(command, info, streams.Input)
+	if err != nil {
+		log.Printf("unable to collect backtrace of test '%s': %v", info.Name, err)
+	}
+	return frames
 }
 
-func runTestWithTmpOutput(executable string, info Info, streams Streams) Result {
+func runTestWithTmpOutput(command []string, info Info, streams Streams) Result {
+	return runTestContextWithTmpOutput(context.Background(), command, info, streams)
+}
+
+func runTestContextWithTmpOutput(ctx context.Context, command []string, info Info, streams Streams) Result {
 	tmpStdOutput, err := ioutil.TempFile(os.TempDir(), "tempstd-*.out")
 	if err != nil {
 		return Result{Status: InternalError, Description: fmt.Sprintf("unable to open temporary output file: %v", err)}
@@ -80,25 +109,38 @@ func runTestWithTmpOutput(executable string, info Info, streams Streams) Result
 	defer os.Remove(tmpErrorOutput.Name())
 	defer tmpErrorOutput.Close()
 
-	return RunTest(executable, info, streams, tmpStdOutput, tmpErrorOutput)
+	return RunTestContext(ctx, command, info, streams, tmpStdOutput, tmpErrorOutput)
 }
 
 // TODO(tjarosik): handle memory limit (-> ulimit -m 100000 && exec ./my-binary)
-func RunTest(executable string, info Info, streams Streams, generatedStdOutput io.ReadWriteSeeker, generatedErrorOutput io.ReadWriteSeeker) Result {
-	ctx, cancel := context.WithTimeout(context.Background(), info.TimeLimit)
+func RunTest(command []string, info Info, streams Streams, generatedStdOutput io.ReadWriteSeeker, generatedErrorOutput io.ReadWriteSeeker) Result {
+	return RunTestContext(context.Background(), command, info, streams, generatedStdOutput, generatedErrorOutput)
+}
+
+// RunTestContext runs the command in its own process group. The whole process tree is killed
+// when the time limit expires or ctx is cancelled.
+func RunTestContext(ctx context.Context, command []string, info Info, streams Streams, generatedStdOutput io.ReadWriteSeeker, generatedErrorOutput io.ReadWriteSeeker) Result {
+	if ctx.Err() != nil {
+		// don't start what would be killed right away
+		return cancelledResult(info, 0)
+	}
+	ctx, cancel := context.WithTimeout(ctx, info.TimeLimit)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, executable)
+	cmd := exec.Command(command[0], command[1:]...)
 	cmd.Stdin = streams.Input
 	cmd.Stdout = generatedStdOutput
 	cmd.Stderr = generatedErrorOutput
 	start := time.Now()
-	err := cmd.Run()
+	err := runInProcessGroup(ctx, cmd)
 	duration := time.Since(start)
 	if ctx.Err() == context.DeadlineExceeded {
 		return Result{Status: TimeLimitExceeded,
 			Description: fmt.Sprintf("time limit exceeded: test case was aborted after '%v'", info.TimeLimit),
 			Duration:    duration}
 	}
+	if ctx.Err() == context.Canceled {
+		return cancelledResult(info, duration)
+	}
 	if err != nil {
 		log.Println(err)
 		_, err = generatedErrorOutput.Seek(0, io.SeekStart)
@@ -106,8 +148,14 @@ func RunTest(executable string, info Info, streams Streams, generatedStdOutput i
 		if err != nil {
 			stderrOutput = []byte("unable to read stderr")
 		}
+		if signal, description, crashed := crashSignal(cmd.ProcessState); crashed {
+			return Result{Status: RuntimeError,
+				Description: fmt.Sprintf("program crashed with %s (%s) on test input file '%s'. Stderr:%s", signal, description, info.Name, string(stderrOutput)),
+				Duration:    duration,
+				Signal:      signal}
+		}
 		return Result{Status: RuntimeError,
-			Description: fmt.Sprintf("unable to run executable '%s' on test input file '%s'. Stderr:%s", executable, info.Name, string(stderrOutput)),
+			Description: fmt.Sprintf("unable to run executable '%s' on test input file '%s'. Stderr:%s", strings.Join(command, " "), info.Name, string(stderrOutput)),
 			Duration:    duration}
 	}
 	_, err = generatedStdOutput.Seek(0, io.SeekStart)
@@ -126,6 +174,12 @@ func RunTest(executable string, info Info, streams Streams, generatedStdOutput i
 	return Result{Status: Accepted, Description: "OK", Duration: duration}
 }
 
+func cancelledResult(info Info, duration time.Duration) Result {
+	return Result{Status: InternalError,
+		Description: fmt.Sprintf("test case '%s' was cancelled", info.Name),
+		Duration:    duration}
+}
+
 func compare(expected, actual io.Reader) error {
 
 	GB := 1024 * 1024 * 1024 // max memory 1GB
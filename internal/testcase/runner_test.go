@@ -4,6 +4,7 @@ package testcase
 //go:generate go build -o testdata/multiply3.exe testdata/multiply3.go
 //go:generate go build -o testdata/infinite_loop.exe testdata/infinite_loop.go
 //go:generate go build -o testdata/invalid_binary.exe testdata/invalid_binary.go
+//go:generate go build -o testdata/spawn_child.exe testdata/spawn_child.go
 
 import (
 	"errors"
@@ -20,7 +21,7 @@ func TestRunTestCase_Success(t *testing.T) {
 		Input:  strings.NewReader("1\n"),
 		Output: strings.NewReader("2\n"),
 	}
-	res := runTestWithTmpOutput("testdata/multiply2.exe", info, streams)
+	res := runTestWithTmpOutput([]string{"testdata/multiply2.exe"}, info, streams)
 	assert.Equal(t, Accepted, res.Status)
 }
 
@@ -30,7 +31,7 @@ func TestRunTestCase_WrongAnswer(t *testing.T) {
 		Input:  strings.NewReader("1\n"),
 		Output: strings.NewReader("2\n"),
 	}
-	res := runTestWithTmpOutput("testdata/multiply3.exe", info, streams)
+	res := runTestWithTmpOutput([]string{"testdata/multiply3.exe"}, info, streams)
 	assert.Equal(t, WrongAnswer, res.Status)
 }
 
@@ -40,7 +41,7 @@ func TestRunTestCase_TimeLimitExceeded(t *testing.T) {
 		Input:  strings.NewReader("1\n"),
 		Output: strings.NewReader("2\n"),
 	}
-	res := runTestWithTmpOutput("testdata/infinite_loop.exe", info, streams)
+	res := runTestWithTmpOutput([]string{"testdata/infinite_loop.exe"}, info, streams)
 	assert.Equal(t, TimeLimitExceeded, res.Status)
 	assert.Equal(t, "time limit exceeded: test case was aborted after '1s'", res.Description)
 }
@@ -51,7 +52,7 @@ func TestRunTestCase_ExecutableFailedToRun(t *testing.T) {
 		Input:  strings.NewReader("1\n"),
 		Output: strings.NewReader("2\n"),
 	}
-	res := runTestWithTmpOutput("testdata/invalid_binary.exe", info, streams)
+	res := runTestWithTmpOutput([]string{"testdata/invalid_binary.exe"}, info, streams)
 	assert.Equal(t, RuntimeError, res.Status)
 	assert.Equal(t, "unable to run executable 'testdata/invalid_binary.exe' on test input file 'test1'. Stderr:this is text on Stderr", res.Description)
 }
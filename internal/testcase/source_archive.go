@@ -0,0 +1,179 @@
+package testcase
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ArchiveFormat format of an archive with sources of a multi-file solution, not to be confused with Archive of test cases
+type ArchiveFormat string
+
+// Supported archive formats
+const (
+	ZipArchive   ArchiveFormat = "zip"
+	TarArchive   ArchiveFormat = "tar"
+	TarGzArchive ArchiveFormat = "tar.gz"
+)
+
+const (
+	maxArchiveFiles = 1000
+	maxArchiveBytes = 50 << 20
+)
+
+// ArchiveFormatOf detects format of the archive by its filename, empty if the file is not a supported archive
+func ArchiveFormatOf(filename string) ArchiveFormat {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ZipArchive
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGzArchive
+	case strings.HasSuffix(name, ".tar"):
+		return TarArchive
+	}
+	return ""
+}
+
+// archiveFiles collects regular files of an archive, rejecting paths which would escape the destination directory
+type archiveFiles struct {
+	files map[string][]byte
+	size  int64
+}
+
+func (a *archiveFiles) add(name string, content io.Reader) error {
+	clean := path.Clean(strings.Replace(name, "\\", "/", -1))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("invalid path in archive: %s", name)
+	}
+	if len(a.files) >= maxArchiveFiles {
+		return fmt.Errorf("archive has more than %d files", maxArchiveFiles)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(content, maxArchiveBytes-a.size+1))
+	if err != nil {
+		return err
+	}
+	a.size += int64(len(data))
+	if a.size > maxArchiveBytes {
+		return fmt.Errorf("archive is larger than %d bytes when unpacked", maxArchiveBytes)
+	}
+	a.files[clean] = data
+	return nil
+}
+
+// stripCommonDir removes the directory all files are in, e.g. when a whole project directory was archived
+func (a *archiveFiles) stripCommonDir() {
+	prefix := ""
+	for name := range a.files {
+		i := strings.Index(name, "/")
+		if i < 0 || (prefix != "" && name[:i+1] != prefix) {
+			return
+		}
+		prefix = name[:i+1]
+	}
+	stripped := make(map[string][]byte, len(a.files))
+	for name, data := range a.files {
+		stripped[strings.TrimPrefix(name, prefix)] = data
+	}
+	a.files = stripped
+}
+
+func readZip(content []byte, files *archiveFiles) error {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = files.add(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTar(r io.Reader, files *archiveFiles) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if err = files.add(header.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadSourceArchive reads all regular files of the archive into memory, keyed by slash separated paths.
+// If all files are in a single directory, the directory is stripped from their paths.
+func ReadSourceArchive(content []byte, format ArchiveFormat) (map[string][]byte, error) {
+	files := &archiveFiles{files: make(map[string][]byte)}
+	var err error
+	switch format {
+	case ZipArchive:
+		err = readZip(content, files)
+	case TarArchive:
+		err = readTar(bytes.NewReader(content), files)
+	case TarGzArchive:
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(content)); err == nil {
+			err = readTar(gz, files)
+		}
+	default:
+		err = fmt.Errorf("unsupported archive format '%s'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(files.files) == 0 {
+		return nil, errors.New("archive has no files")
+	}
+	files.stripCommonDir()
+	return files.files, nil
+}
+
+// UnpackSourceArchive writes files of the archive into dir, returns their sorted slash separated paths relative to dir
+func UnpackSourceArchive(content []byte, format ArchiveFormat, dir string) ([]string, error) {
+	files, err := ReadSourceArchive(content, format)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for name, data := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return nil, err
+		}
+		if err = ioutil.WriteFile(filename, data, 0644); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,143 @@
+package testcase
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+		f.Write([]byte(files[name]))
+	}
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for name, content := range files {
+		assert.NoError(t, w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		w.Write([]byte(content))
+	}
+	assert.NoError(t, w.WriteHeader(&tar.Header{Name: "link", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}))
+	assert.NoError(t, w.Close())
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveFormatOf(t *testing.T) {
+	assert.Equal(t, ZipArchive, ArchiveFormatOf("solution.ZIP"))
+	assert.Equal(t, TarArchive, ArchiveFormatOf("solution.tar"))
+	assert.Equal(t, TarGzArchive, ArchiveFormatOf("solution.tar.gz"))
+	assert.Equal(t, TarGzArchive, ArchiveFormatOf("solution.tgz"))
+	assert.Equal(t, ArchiveFormat(""), ArchiveFormatOf("solution.cpp"))
+}
+
+func TestReadSourceArchive(t *testing.T) {
+	files := map[string]string{"project/main.cpp": "main", "project/lib/util.h": "util"}
+	expected := map[string][]byte{"main.cpp": []byte("main"), "lib/util.h": []byte("util")}
+
+	res, err := ReadSourceArchive(zipArchive(t, files), ZipArchive)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, res)
+
+	res, err = ReadSourceArchive(tarGzArchive(t, files), TarGzArchive)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, res, "symlinks are skipped")
+}
+
+func TestReadSourceArchive_Invalid(t *testing.T) {
+	_, err := ReadSourceArchive(zipArchive(t, map[string]string{"../evil.cpp": ""}), ZipArchive)
+	assert.EqualError(t, err, "invalid path in archive: ../evil.cpp")
+	_, err = ReadSourceArchive(zipArchive(t, map[string]string{"/etc/evil.cpp": ""}), ZipArchive)
+	assert.Error(t, err)
+	_, err = ReadSourceArchive(zipArchive(t, map[string]string{}), ZipArchive)
+	assert.EqualError(t, err, "archive has no files")
+	_, err = ReadSourceArchive([]byte("not a zip"), ZipArchive)
+	assert.Error(t, err)
+	_, err = ReadSourceArchive([]byte{}, "rar")
+	assert.EqualError(t, err, "unsupported archive format 'rar'")
+}
+
+func TestCompile_CppArchive(t *testing.T) {
+	archive := zipArchive(t, map[string]string{
+		"main.cpp":     "#include <cstdio>\n#include \"lib/util.h\"\nint main() { printf(\"%d\\n\", twice(21)); return 0; }\n",
+		"lib/util.h":   "int twice(int x);\n",
+		"lib/util.cpp": "#include \"util.h\"\nint twice(int x) { return 2 * x; }\n",
+	})
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+
+	command, out, err := Compile(Solution{Source: archive, Archive: ZipArchive}, mustLookupLanguage(t, "cpp17"), ReleaseMode, dir)
+	assert.NoError(t, err, string(out))
+	assert.Equal(t, []string{filepath.Join(dir, executableFilename)}, command)
+	result := runTestWithTmpOutput(command, NewInfo("t", 10*time.Second, 0),
+		Streams{Input: strings.NewReader(""), Output: strings.NewReader("42\n")})
+	assert.Equal(t, Accepted, result.Status, result.Description)
+}
+
+func TestCompile_CppArchiveDiagnosticsHaveRelativePaths(t *testing.T) {
+	archive := zipArchive(t, map[string]string{
+		"main.cpp":     "int twice(int x);\nint main() { return twice(1); }\n",
+		"lib/util.cpp": "int twice(int x) { return 2 * y; }\n",
+	})
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+
+	_, out, err := Compile(Solution{Source: archive, Archive: ZipArchive}, mustLookupLanguage(t, "cpp17"), ReleaseMode, dir)
+	assert.Error(t, err)
+	diags := ParseDiagnostics(out)
+	assert.Equal(t, 1, len(diags))
+	assert.Equal(t, "lib/util.cpp", diags[0].File)
+	assert.Equal(t, 1, diags[0].Line)
+}
+
+func TestCompile_PythonArchiveEntry(t *testing.T) {
+	archive := zipArchive(t, map[string]string{
+		"app/run.py":   "from helper import twice\nprint(twice(int(input())))\n",
+		"app/other.py": "print('wrong entry')\n",
+		"helper.py":    "def twice(x):\n    return 2 * x\n",
+	})
+	python := mustLookupLanguage(t, "python3")
+	dir := tempBuildDir(t)
+	defer os.RemoveAll(dir)
+
+	_, out, err := Compile(Solution{Source: archive, Archive: ZipArchive}, python, ReleaseMode, dir)
+	assert.EqualError(t, err, "archive has 3 source files, choose the entry file")
+	assert.Equal(t, err.Error(), string(out))
+
+	_, _, err = Compile(Solution{Source: archive, Archive: ZipArchive, Entry: "missing.py"}, python, ReleaseMode, dir)
+	assert.EqualError(t, err, "entry file 'missing.py' is not in the archive")
+
+	archive = zipArchive(t, map[string]string{
+		"main.py":   "from helper import twice\nprint(twice(int(input())))\n",
+		"helper.py": "def twice(x):\n    return 2 * x\n",
+	})
+	command, _, err := Compile(Solution{Source: archive, Archive: ZipArchive}, python, ReleaseMode, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"python3", filepath.Join(dir, "main.py")}, command)
+	result := runTestWithTmpOutput(command, NewInfo("t", 10*time.Second, 0),
+		Streams{Input: strings.NewReader("21\n"), Output: strings.NewReader("42\n")})
+	assert.Equal(t, Accepted, result.Status, result.Description)
+}
@@ -0,0 +1,158 @@
+package testcase
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// StaticAnalyzer tool which checks sources of the solution without running it, e.g. clang-tidy or cppcheck
+type StaticAnalyzer struct {
+	Name string `json:"name"`
+	// Command template, placeholders are the same as in Language.Compile, e.g. {sources}, {std}, {dir}.
+	// The tool must print findings as "file:line:column: severity: message [check]".
+	Command []string `json:"command"`
+	// Families language families the analyzer supports, all families of the profile if empty
+	Families []string `json:"families,omitempty"`
+}
+
+// Supports returns true if the analyzer checks solutions in the language
+func (a StaticAnalyzer) Supports(lang Language) bool {
+	return len(a.Families) == 0 || contains(a.Families, lang.Family)
+}
+
+// DefaultStaticAnalyzers analyzers used by the default "Analyze" profiles
+func DefaultStaticAnalyzers() []StaticAnalyzer {
+	return []StaticAnalyzer{
+		{
+			Name:    "clang-tidy",
+			Command: []string{"clang-tidy", "--quiet", "{sources}", "--", "-std={std}", "-I{dir}"},
+		},
+		{
+			Name: "cppcheck",
+			Command: []string{"cppcheck", "--quiet", "--enable=warning,style,performance,portability", "-I{dir}",
+				"--template={file}:{line}:{column}: {severity}: {message} [{id}]", "{sources}"},
+		},
+	}
+}
+
+// Finding problem in the source reported by a StaticAnalyzer
+type Finding struct {
+	Tool string `json:"tool"`
+	// Check name of the check which reported the finding, e.g. "bugprone-integer-division"
+	Check    string `json:"check,omitempty"`
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Diagnostic the finding presented like a compiler diagnostic
+func (f Finding) Diagnostic() Diagnostic {
+	return Diagnostic{Severity: f.Severity, File: f.File, Line: f.Line, Column: f.Column, Message: f.Message, Option: f.Check}
+}
+
+// findingPattern matches "file:line:column: severity: message [check]" lines
+var findingPattern = regexp.MustCompile(`^(\S+?):(\d+):(?:(\d+):)? ([a-z][a-z ]*): (.*?)(?: \[([^\]]+)\])?$`)
+
+// parseFindings extracts findings about sources in dir from output of the analyzer, notes are skipped
+func parseFindings(tool string, output []byte, dir string) []Finding {
+	var res []Finding
+	for _, line := range strings.Split(string(output), "\n") {
+		m := findingPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil || m[4] == "note" {
+			continue
+		}
+		file, ok := solutionSourceName(m[1], dir)
+		if !ok {
+			continue
+		}
+		f := Finding{Tool: tool, File: file, Severity: m[4], Message: strings.TrimSpace(m[5]), Check: m[6]}
+		f.Line, _ = strconv.Atoi(m[2])
+		f.Column, _ = strconv.Atoi(m[3])
+		res = append(res, f)
+	}
+	return res
+}
+
+// StaticAnalysis runs analyzers of the compilation profile on sources of the solution in a scratch directory.
+// Analyzers which are not installed are skipped, findings about Extra files of the solution are dropped.
+// Analyzers run with the same limits as the compiler, see CurrentCompilationLimits.
+func StaticAnalysis(solution Solution, lang Language, mode CompilationMode) ([]Finding, error) {
+	if !lang.UsesCompilationMode() {
+		return nil, nil
+	}
+	profile, ok := LookupCompilationProfile(mode)
+	if !ok || len(profile.Analyzers) == 0 {
+		return nil, nil
+	}
+	dir, err := ioutil.TempDir(os.TempDir(), "analysis-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	files, err := prepareSources(solution, lang, dir)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Finding, 0)
+	for _, analyzer := range profile.Analyzers {
+		if !analyzer.Supports(lang) {
+			continue
+		}
+		args, err := commandArgs(analyzer.Command, lang, mode, dir, executableFilename, files)
+		if err != nil {
+			return res, err
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if _, err = exec.LookPath(args[0]); err != nil {
+			log.Printf("static analyzer %s skipped: %v", analyzer.Name, err)
+			continue
+		}
+		output, err := runAnalyzer(args, dir)
+		if err != nil {
+			return res, fmt.Errorf("%s: %v", analyzer.Name, err)
+		}
+		for _, f := range parseFindings(analyzer.Name, output, dir) {
+			if _, extra := solution.Extra[f.File]; !extra {
+				res = append(res, f)
+			}
+		}
+	}
+	return res, nil
+}
+
+// runAnalyzer returns combined output of the analyzer, its exit status is ignored because
+// analyzers report findings with it
+func runAnalyzer(args []string, dir string) ([]byte, error) {
+	limits := CurrentCompilationLimits()
+	args = withMemoryLimit(args, int64(limits.MemoryLimitMB)<<20)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	combined := &limitedBuffer{limit: limits.OutputLimitKB << 10}
+	cmd.Stdout = combined
+	cmd.Stderr = combined
+	ctx := context.Background()
+	if limits.Timeout() > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout())
+		defer cancel()
+	}
+	err := runInProcessGroup(ctx, cmd)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("aborted after %v", limits.Timeout())
+	}
+	if _, exited := err.(*exec.ExitError); err != nil && !exited {
+		return nil, err
+	}
+	return combined.Bytes(), nil
+}
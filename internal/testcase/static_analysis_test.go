@@ -0,0 +1,58 @@
+package testcase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFindings(t *testing.T) {
+	output := `/build/solution.cpp:4:11: warning: use nullptr [modernize-use-nullptr]
+    int *p = 0;
+          ^
+/build/solution.cpp:4:11: note: replace with nullptr
+/usr/include/c++/12/bits/stl_vector.h:10:1: warning: something in the library [readability-x]
+solution.cpp:7:0: style: The scope of the variable 'x' can be reduced. [variableScope]
+2 warnings generated.
+`
+	assert.Equal(t, []Finding{
+		{Tool: "tool", Check: "modernize-use-nullptr", Severity: "warning", File: "solution.cpp", Line: 4, Column: 11, Message: "use nullptr"},
+		{Tool: "tool", Check: "variableScope", Severity: "style", File: "solution.cpp", Line: 7, Message: "The scope of the variable 'x' can be reduced."},
+	}, parseFindings("tool", []byte(output), "/build"))
+}
+
+func TestStaticAnalysis(t *testing.T) {
+	defer SetCompilationProfiles(DefaultCompilationProfiles())
+	fake := StaticAnalyzer{
+		Name: "fake",
+		// reports a finding in every source and exits with an error, like clang-tidy does
+		Command: []string{"/bin/sh", "-c", `for f in "$@"; do echo "$f:1:1: warning: bad code [fake-check]"; done; exit 1`, "sh", "{sources}"},
+	}
+	missing := StaticAnalyzer{Name: "missing", Command: []string{"no-such-analyzer", "{sources}"}}
+	javaOnly := StaticAnalyzer{Name: "java", Command: []string{"/bin/echo", "solution.cpp:1:1: error: java [x]"}, Families: []string{"java"}}
+	assert.NoError(t, SetCompilationProfiles([]CompilationProfile{{
+		ID:        "Lint",
+		Compilers: map[string]string{FamilyCpp: "g++"},
+		Analyzers: []StaticAnalyzer{fake, missing, javaOnly},
+	}}))
+	cpp := mustLookupLanguage(t, "cpp17")
+
+	findings, err := StaticAnalysis(Solution{Source: []byte("int main() {}")}, cpp, "Lint")
+	assert.NoError(t, err)
+	assert.Equal(t, []Finding{{Tool: "fake", Check: "fake-check", Severity: "warning", File: "solution.cpp", Line: 1, Column: 1, Message: "bad code"}}, findings)
+
+	// findings about files of the grader are not interesting to the contestant
+	findings, err = StaticAnalysis(Solution{Source: []byte("int f() { return 1; }"), Extra: map[string][]byte{"grader.cpp": []byte("int main() {}")}}, cpp, "Lint")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(findings))
+	assert.Equal(t, "solution.cpp", findings[0].File)
+
+	findings, err = StaticAnalysis(Solution{Source: []byte("int main() {}")}, cpp, ReleaseMode)
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestCompilationProfile_InvalidAnalyzer(t *testing.T) {
+	p := CompilationProfile{ID: "x", Compilers: map[string]string{FamilyC: "gcc"}, Analyzers: []StaticAnalyzer{{Name: "a"}}}
+	assert.EqualError(t, p.Validate(), "compilation profile 'x' has an analyzer without name or command")
+}
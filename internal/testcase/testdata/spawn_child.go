@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "child" {
+		for {
+		}
+	}
+	child := exec.Command(os.Args[0], "child")
+	if err := child.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(child.Process.Pid)
+	for {
+	}
+}
@@ -0,0 +1,274 @@
+package testcase
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolchainStatus result of the self-check of a language compiled in a compilation mode
+type ToolchainStatus struct {
+	Language string `json:"language"`
+	// CompilationMode empty for languages which don't use compilation modes
+	CompilationMode CompilationMode `json:"compilationMode,omitempty"`
+	Binary          string          `json:"binary"`
+	Version         string          `json:"version"`
+	Available       bool            `json:"available"`
+	Error           string          `json:"error,omitempty"`
+	CheckedAt       time.Time       `json:"checkedAt"`
+}
+
+// selfCheckPrograms tiny programs per language family, reading a number and printing it doubled
+var selfCheckPrograms = map[string]string{
+	FamilyC: `#include <stdio.h>
+int main(void) { long long x; if (scanf("%lld", &x) != 1) return 1; printf("%lld\n", 2 * x); return 0; }
+`,
+	FamilyCpp: `#include <cstdio>
+int main() { long long x; if (scanf("%lld", &x) != 1) return 1; printf("%lld\n", 2 * x); return 0; }
+`,
+	"java": `import java.util.Scanner;
+public class Main { public static void main(String[] args) { System.out.println(2 * new Scanner(System.in).nextLong()); } }
+`,
+	"go": `package main
+
+import "fmt"
+
+func main() { var x int64; fmt.Scan(&x); fmt.Println(2 * x) }
+`,
+	"rust": `fn main() { let mut s = String::new(); std::io::stdin().read_line(&mut s).unwrap(); let x: i64 = s.trim().parse().unwrap(); println!("{}", 2 * x); }
+`,
+	"python":     "print(2 * int(input()))\n",
+	"javascript": "console.log(2 * parseInt(require('fs').readFileSync(0, 'utf8')));\n",
+}
+
+var (
+	toolchainStatuses      = make(map[string]ToolchainStatus)
+	toolchainStatusesMutex sync.RWMutex
+	// toolchainVersions versions by toolchainFingerprint, so compilers upgraded while the server is running are asked again
+	toolchainVersions sync.Map
+)
+
+func toolchainKey(lang Language, mode CompilationMode) string {
+	if !lang.UsesCompilationMode() {
+		mode = ""
+	}
+	return lang.ID + "/" + string(mode)
+}
+
+// toolchainBinary compiler, or interpreter for languages without a compilation step
+func toolchainBinary(lang Language, mode CompilationMode) (string, error) {
+	if lang.UsesCompilationMode() {
+		profile, ok := LookupCompilationProfile(mode)
+		if !ok {
+			return "", fmt.Errorf("unknown compilation mode '%s'", mode)
+		}
+		return profile.Compilers[lang.Family], nil
+	}
+	template := lang.Compile
+	if template == nil {
+		template = lang.Run
+	}
+	if len(template) == 0 {
+		return "", fmt.Errorf("language %s has no commands", lang.ID)
+	}
+	return template[0], nil
+}
+
+// toolchainFingerprint identifies the installed binary by its resolved path, size and modification time
+func toolchainFingerprint(binary string) (string, bool) {
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return "", false
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s %d %d", path, info.Size(), info.ModTime().UnixNano()), true
+}
+
+// toolchainVersion first line printed by the binary asked for its version
+func toolchainVersion(binary string) string {
+	fingerprint, found := toolchainFingerprint(binary)
+	if !found {
+		return ""
+	}
+	if v, ok := toolchainVersions.Load(fingerprint); ok {
+		return v.(string)
+	}
+	version := ""
+	// "version" for the go tool, which doesn't understand flags
+	for _, flag := range []string{"--version", "-version", "version"} {
+		output, err := exec.Command(binary, flag).CombinedOutput()
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				version = line
+				break
+			}
+		}
+		break
+	}
+	if version != "" {
+		toolchainVersions.Store(fingerprint, version)
+	}
+	return version
+}
+
+// CheckToolchain compiles and runs a tiny program in given language and compilation mode
+func CheckToolchain(lang Language, mode CompilationMode) ToolchainStatus {
+	status := ToolchainStatus{Language: lang.ID, CheckedAt: time.Now()}
+	if lang.UsesCompilationMode() {
+		status.CompilationMode = mode
+	}
+	binary, err := toolchainBinary(lang, mode)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Binary = binary
+	if _, err = exec.LookPath(binary); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Version = toolchainVersion(binary)
+	if profile, ok := LookupCompilationProfile(mode); ok && lang.UsesCompilationMode() && len(profile.Coverage) > 0 {
+		if _, err = exec.LookPath(profile.Coverage[0]); err != nil {
+			status.Error = err.Error()
+			return status
+		}
+	}
+
+	program, ok := selfCheckPrograms[lang.Family]
+	if !ok {
+		// nothing to run, the binary exists
+		status.Available = true
+		return status
+	}
+	dir, err := ioutil.TempDir(os.TempDir(), "selfcheck-")
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer os.RemoveAll(dir)
+	command, output, err := CompileSolution(strings.NewReader(program), lang, mode, dir)
+	if err != nil {
+		status.Error = fmt.Sprintf("%v: %s", err, output)
+		return status
+	}
+	streams := Streams{Input: strings.NewReader("21\n"), Output: strings.NewReader("42\n")}
+	result := runTestWithTmpOutput(command, NewInfo("self-check", 30*time.Second, 0), streams)
+	if result.Status != Accepted {
+		status.Error = fmt.Sprintf("self-check program: %s: %s", result.Status, result.Description)
+		return status
+	}
+	status.Available = true
+	return status
+}
+
+// SelfCheck checks every language in every compilation mode it supports and remembers the results,
+// see ToolchainAvailable
+func SelfCheck() []ToolchainStatus {
+	type check struct {
+		lang Language
+		mode CompilationMode
+	}
+	checks := make([]check, 0)
+	for _, lang := range Languages() {
+		if !lang.UsesCompilationMode() {
+			checks = append(checks, check{lang, ReleaseMode})
+			continue
+		}
+		for _, profile := range CompilationProfiles() {
+			if profile.Supports(lang) {
+				checks = append(checks, check{lang, profile.ID})
+			}
+		}
+	}
+
+	jobs := make(chan check, len(checks))
+	for _, c := range checks {
+		jobs <- c
+	}
+	close(jobs)
+	results := make(chan ToolchainStatus, len(checks))
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				results <- CheckToolchain(c.lang, c.mode)
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	statuses := make([]ToolchainStatus, 0, len(checks))
+	toolchainStatusesMutex.Lock()
+	toolchainStatuses = make(map[string]ToolchainStatus)
+	for s := range results {
+		lang, _ := LookupLanguage(s.Language)
+		toolchainStatuses[toolchainKey(lang, s.CompilationMode)] = s
+		statuses = append(statuses, s)
+		if !s.Available {
+			log.Printf("self-check: %s %s is not available: %s\n", s.Language, s.CompilationMode, s.Error)
+		}
+	}
+	toolchainStatusesMutex.Unlock()
+	sortToolchainStatuses(statuses)
+	return statuses
+}
+
+func sortToolchainStatuses(statuses []ToolchainStatus) {
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Language == statuses[j].Language {
+			return statuses[i].CompilationMode < statuses[j].CompilationMode
+		}
+		return statuses[i].Language < statuses[j].Language
+	})
+}
+
+// ToolchainStatuses results of the last SelfCheck
+func ToolchainStatuses() []ToolchainStatus {
+	toolchainStatusesMutex.RLock()
+	defer toolchainStatusesMutex.RUnlock()
+	res := make([]ToolchainStatus, 0, len(toolchainStatuses))
+	for _, s := range toolchainStatuses {
+		res = append(res, s)
+	}
+	sortToolchainStatuses(res)
+	return res
+}
+
+// ToolchainAvailable returns false if the last SelfCheck failed for the language in given mode.
+// Combinations which were not checked are assumed to be available.
+func ToolchainAvailable(lang Language, mode CompilationMode) bool {
+	toolchainStatusesMutex.RLock()
+	defer toolchainStatusesMutex.RUnlock()
+	s, ok := toolchainStatuses[toolchainKey(lang, mode)]
+	return !ok || s.Available
+}
+
+// CompilerVersion version of the compiler (or interpreter) used for the language in given mode
+func CompilerVersion(lang Language, mode CompilationMode) string {
+	binary, err := toolchainBinary(lang, mode)
+	if err != nil {
+		return ""
+	}
+	return toolchainVersion(binary)
+}
@@ -0,0 +1,83 @@
+package testcase
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withMissingCompilerProfile(t *testing.T) {
+	profiles := append(DefaultCompilationProfiles(), CompilationProfile{
+		ID:        "Missing",
+		Name:      "Missing compiler",
+		Compilers: map[string]string{FamilyC: "no-such-compiler", FamilyCpp: "no-such-compiler++"},
+		Standards: map[string]string{FamilyC: "c11", FamilyCpp: "c++17"},
+	})
+	assert.NoError(t, SetCompilationProfiles(profiles))
+}
+
+func resetToolchains() {
+	SetCompilationProfiles(DefaultCompilationProfiles())
+	toolchainStatusesMutex.Lock()
+	toolchainStatuses = make(map[string]ToolchainStatus)
+	toolchainStatusesMutex.Unlock()
+}
+
+func TestCheckToolchain_Available(t *testing.T) {
+	status := CheckToolchain(mustLookupLanguage(t, DefaultLanguageID), ReleaseMode)
+	assert.True(t, status.Available, status.Error)
+	assert.Equal(t, "g++", status.Binary)
+	assert.NotEmpty(t, status.Version)
+	assert.Equal(t, status.Version, CompilerVersion(mustLookupLanguage(t, DefaultLanguageID), ReleaseMode))
+}
+
+func TestCheckToolchain_MissingCompiler(t *testing.T) {
+	withMissingCompilerProfile(t)
+	defer resetToolchains()
+
+	status := CheckToolchain(mustLookupLanguage(t, DefaultLanguageID), "Missing")
+	assert.False(t, status.Available)
+	assert.Equal(t, "no-such-compiler++", status.Binary)
+	assert.NotEmpty(t, status.Error)
+}
+
+func TestSelfCheck(t *testing.T) {
+	withMissingCompilerProfile(t)
+	defer resetToolchains()
+	cpp := mustLookupLanguage(t, DefaultLanguageID)
+	assert.True(t, ToolchainAvailable(cpp, "Missing"), "not checked yet")
+
+	statuses := SelfCheck()
+	assert.NotEmpty(t, statuses)
+	assert.Equal(t, statuses, ToolchainStatuses())
+	assert.False(t, ToolchainAvailable(cpp, "Missing"))
+	assert.False(t, ToolchainAvailable(mustLookupLanguage(t, "c11"), "Missing"))
+	assert.True(t, ToolchainAvailable(cpp, ReleaseMode))
+}
+
+// writeFakeCompiler writes a script which prints the version, modified at the time
+func writeFakeCompiler(t *testing.T, filename, version string, modified time.Time) {
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("#!/bin/sh\necho "+version+"\n"), 0755))
+	assert.NoError(t, os.Chtimes(filename, modified, modified))
+}
+
+func TestToolchainVersion_CompilerUpgradedWhileRunning(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testtoolchain-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	compiler := filepath.Join(dir, "fake-compiler")
+	installed := time.Now().Add(-time.Hour)
+
+	writeFakeCompiler(t, compiler, "fake 1.0", installed)
+	assert.Equal(t, "fake 1.0", toolchainVersion(compiler))
+	assert.Equal(t, "fake 1.0", toolchainVersion(compiler))
+
+	writeFakeCompiler(t, compiler, "fake 1.1", installed.Add(time.Minute))
+	assert.Equal(t, "fake 1.1", toolchainVersion(compiler))
+
+	assert.Equal(t, "", toolchainVersion(filepath.Join(dir, "missing-compiler")))
+}
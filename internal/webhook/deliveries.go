@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Delivery a single attempt to deliver a payload to a webhook
+type Delivery struct {
+	ID           string        `json:"id"`
+	WebhookID    string        `json:"webhookId"`
+	URL          string        `json:"url"`
+	SubmissionID string        `json:"submissionId"`
+	Attempt      int           `json:"attempt"`
+	StatusCode   int           `json:"statusCode"`
+	Error        string        `json:"error,omitempty"`
+	Succeeded    bool          `json:"succeeded"`
+	Duration     time.Duration `json:"duration"`
+	AttemptedAt  time.Time     `json:"attemptedAt"`
+}
+
+// DeliveryLog records delivery attempts
+type DeliveryLog interface {
+	Record(d Delivery) error
+	// Recent returns most recent deliveries, newest first
+	Recent() []Delivery
+}
+
+const (
+	recentDeliveriesLimit = 200
+	// maxDeliveryLogSize the log is rotated when it grows over it, only one rotated file is kept
+	maxDeliveryLogSize = 10 << 20
+)
+
+type fileDeliveryLog struct {
+	filename string
+	maxSize  int64
+	recent   []Delivery
+	m        sync.Mutex
+}
+
+// NewFileDeliveryLog constructor of the DeliveryLog which appends deliveries to a file, one JSON per line.
+// The file is renamed to <filename>.1 when it grows over 10 MB.
+func NewFileDeliveryLog(filename string) DeliveryLog {
+	return &fileDeliveryLog{
+		filename: filename,
+		maxSize:  maxDeliveryLogSize,
+		recent:   make([]Delivery, 0),
+	}
+}
+
+func (l *fileDeliveryLog) Record(d Delivery) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.recent = append(l.recent, d)
+	if len(l.recent) > recentDeliveriesLimit {
+		l.recent = l.recent[len(l.recent)-recentDeliveriesLimit:]
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.filename), 0755); err != nil {
+		return err
+	}
+	if err := l.rotate(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(d)
+}
+
+// rotate replaces the previous rotated file with the log if it's too big
+func (l *fileDeliveryLog) rotate() error {
+	info, err := os.Stat(l.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() < l.maxSize {
+		return nil
+	}
+	return os.Rename(l.filename, l.filename+".1")
+}
+
+func (l *fileDeliveryLog) Recent() []Delivery {
+	l.m.Lock()
+	defer l.m.Unlock()
+	res := make([]Delivery, len(l.recent))
+	for i, d := range l.recent {
+		res[len(l.recent)-1-i] = d
+	}
+	return res
+}
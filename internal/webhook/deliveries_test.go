@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileDeliveryLog_Rotates(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testwebhook-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "deliveries.log")
+
+	l := NewFileDeliveryLog(filename).(*fileDeliveryLog)
+	l.maxSize = 1000
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, l.Record(Delivery{ID: "d", URL: "http://localhost:9999/hook", Succeeded: true}))
+	}
+	info, err := os.Stat(filename)
+	assert.NoError(t, err)
+	assert.True(t, info.Size() < 1000+200, info.Size())
+	rotated, err := os.Stat(filename + ".1")
+	assert.NoError(t, err)
+	assert.True(t, rotated.Size() >= 1000)
+	assert.Equal(t, 50, len(l.Recent()))
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	guuid "github.com/google/uuid"
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+)
+
+const (
+	// SignatureHeader HMAC-SHA256 of the request body, hex encoded and prefixed with "sha256="
+	SignatureHeader = "X-Inout-Signature"
+	// EventHeader name of the event the payload is about
+	EventHeader = "X-Inout-Event"
+	// DeliveryHeader unique ID of the delivery, same for all attempts
+	DeliveryHeader = "X-Inout-Delivery"
+
+	// SubmissionCompletedEvent sent when submission reaches a final status, see submission.Status.Final
+	SubmissionCompletedEvent = "submission.completed"
+)
+
+// Payload body of the webhook request
+type Payload struct {
+	Event      string          `json:"event"`
+	Submission submission.Info `json:"submission"`
+}
+
+// Dispatcher delivers final results of submissions in the outbox to registered webhooks
+type Dispatcher struct {
+	registry    Registry
+	deliveries  DeliveryLog
+	outbox      Outbox
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+
+	// inFlight submissions of the outbox being delivered
+	inFlight map[submission.ID]bool
+	m        sync.Mutex
+}
+
+// NewDispatcher constructor of the Dispatcher
+func NewDispatcher(registry Registry, deliveries DeliveryLog, outbox Outbox) *Dispatcher {
+	return &Dispatcher{
+		registry:    registry,
+		deliveries:  deliveries,
+		outbox:      outbox,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: 5,
+		backoff:     2 * time.Second,
+		inFlight:    make(map[submission.ID]bool),
+	}
+}
+
+// Sign computes signature of the payload sent in SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run delivers results of submissions in the outbox, left from a previous run and added later, until quit is closed
+func (d *Dispatcher) Run(quit <-chan struct{}) {
+	for {
+		pending, err := d.outbox.Pending()
+		if err != nil {
+			log.Println("webhook: unable to read the outbox:", err)
+		}
+		for _, meta := range pending {
+			d.m.Lock()
+			started := d.inFlight[meta.ID]
+			d.inFlight[meta.ID] = true
+			d.m.Unlock()
+			if !started {
+				go d.dispatch(meta)
+			}
+		}
+		select {
+		case <-quit:
+			return
+		case <-d.outbox.Added():
+		}
+	}
+}
+
+// dispatch delivers results of the submission to all matching webhooks, then removes it from the outbox
+func (d *Dispatcher) dispatch(meta submission.Metadata) {
+	defer func() {
+		if err := d.outbox.Done(meta.ID); err != nil {
+			log.Println("webhook: unable to remove from the outbox", meta.ID, err)
+		}
+		d.m.Lock()
+		defer d.m.Unlock()
+		delete(d.inFlight, meta.ID)
+	}()
+	var wg sync.WaitGroup
+	for _, w := range d.registry.Matching(meta.ProblemName) {
+		wg.Add(1)
+		go func(w Webhook) {
+			defer wg.Done()
+			d.deliver(w, Payload{Event: SubmissionCompletedEvent, Submission: submission.NewInfo(meta)})
+		}(w)
+	}
+	wg.Wait()
+}
+
+// deliver sends the payload, retrying with exponential backoff until it succeeds or attempts run out
+func (d *Dispatcher) deliver(w Webhook, payload Payload) bool {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Println("webhook: unable to encode payload:", err)
+		return false
+	}
+	deliveryID := guuid.New().String()
+	backoff := d.backoff
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		delivery := d.attempt(w, deliveryID, body)
+		delivery.SubmissionID = payload.Submission.ID.String()
+		delivery.Attempt = attempt
+		if err := d.deliveries.Record(delivery); err != nil {
+			log.Println("webhook: unable to record delivery:", err)
+		}
+		if delivery.Succeeded {
+			return true
+		}
+		if attempt < d.maxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	log.Printf("webhook: giving up on delivering %s to %s\n", payload.Submission.ID, w.URL)
+	return false
+}
+
+func (d *Dispatcher) attempt(w Webhook, deliveryID string, body []byte) Delivery {
+	delivery := Delivery{
+		ID:          deliveryID,
+		WebhookID:   w.ID,
+		URL:         w.URL,
+		AttemptedAt: time.Now(),
+	}
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, SubmissionCompletedEvent)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+	}
+	resp, err := d.client.Do(req)
+	delivery.Duration = time.Since(delivery.AttemptedAt)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	delivery.StatusCode = resp.StatusCode
+	delivery.Succeeded = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Succeeded {
+		delivery.Error = fmt.Sprintf("unexpected response status: %s", resp.Status)
+	}
+	return delivery
+}
@@ -0,0 +1,198 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+var cpp, _ = testcase.LookupLanguage(testcase.DefaultLanguageID)
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// stubReceiver local HTTP stand-in for a webhook receiver, fails first `failures` requests
+type stubReceiver struct {
+	failures int
+	received []receivedRequest
+	m        sync.Mutex
+}
+
+func (s *stubReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.received = append(s.received, receivedRequest{header: r.Header, body: body})
+	if len(s.received) <= s.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *stubReceiver) count() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return len(s.received)
+}
+
+// newTestDispatcher returns the dispatcher with its registry and the storage which adds completed submissions to its outbox
+func newTestDispatcher(t *testing.T, dir string) (*Dispatcher, Registry, submission.Storage) {
+	registry, err := NewFileRegistry(filepath.Join(dir, "webhooks.json"))
+	assert.NoError(t, err)
+	outbox, err := NewFileOutbox(filepath.Join(dir, "outbox"))
+	assert.NoError(t, err)
+	store := submission.NewDefaultStorage(filepath.Join(dir, "submissions"))
+	assert.NoError(t, store.Init())
+	d := NewDispatcher(registry, NewFileDeliveryLog(filepath.Join(dir, "deliveries.log")), outbox)
+	d.backoff = time.Millisecond
+	return d, registry, WithOutbox(store, outbox)
+}
+
+func pendingCount(t *testing.T, outbox Outbox) int {
+	pending, err := outbox.Pending()
+	assert.NoError(t, err)
+	return len(pending)
+}
+
+func waitFor(cond func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestDispatcher_DeliversSignedPayloadWithRetries(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testwebhook-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	receiver := &stubReceiver{failures: 2}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	d, registry, store := newTestDispatcher(t, dir)
+	w, err := registry.Register(Webhook{URL: server.URL, Secret: "s3cret"})
+	assert.NoError(t, err)
+
+	quit := make(chan struct{})
+	defer close(quit)
+	go d.Run(quit)
+
+	meta := submission.NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	assert.NoError(t, store.Save(meta))
+	meta.Status = submission.AllTestsCompleted
+	meta.AcceptedCount = 3
+	assert.NoError(t, store.Save(meta))
+
+	assert.True(t, waitFor(func() bool { return receiver.count() == 3 }))
+	assert.True(t, waitFor(func() bool { return pendingCount(t, d.outbox) == 0 }))
+
+	last := receiver.received[2]
+	assert.Equal(t, Sign("s3cret", last.body), last.header.Get(SignatureHeader))
+	assert.Equal(t, SubmissionCompletedEvent, last.header.Get(EventHeader))
+	assert.Equal(t, receiver.received[0].header.Get(DeliveryHeader), last.header.Get(DeliveryHeader))
+	var payload Payload
+	assert.NoError(t, json.Unmarshal(last.body, &payload))
+	assert.Equal(t, meta.ID, payload.Submission.ID)
+	assert.Equal(t, submission.AllTestsCompleted, payload.Submission.Status)
+	assert.Equal(t, 3, payload.Submission.AcceptedCount)
+	assert.NotContains(t, string(last.body), "solutionHash")
+	assert.NotContains(t, string(last.body), "executableFilename")
+
+	assert.True(t, waitFor(func() bool { return len(d.deliveries.Recent()) == 3 }))
+	deliveries := d.deliveries.Recent()
+	assert.True(t, deliveries[0].Succeeded)
+	assert.Equal(t, 3, deliveries[0].Attempt)
+	assert.Equal(t, w.ID, deliveries[0].WebhookID)
+	assert.False(t, deliveries[1].Succeeded)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[1].StatusCode)
+
+	logged, err := ioutil.ReadFile(filepath.Join(dir, "deliveries.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(logged), meta.ID.String())
+}
+
+func TestDispatcher_OnlyFinalStatusesOfMatchingProblems(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testwebhook-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	receiver := &stubReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	d, registry, store := newTestDispatcher(t, dir)
+	_, err = registry.Register(Webhook{URL: server.URL, ProblemName: "problem2"})
+	assert.NoError(t, err)
+
+	other := submission.NewMetadata("problem1", cpp, testcase.ReleaseMode)
+	other.Status = submission.AllTestsCompleted
+	assert.NoError(t, store.Save(other))
+	failed := submission.NewMetadata("problem2", cpp, testcase.ReleaseMode)
+	failed.Status = submission.CompilationError
+
+	running := failed
+	running.Status = submission.RunningTests
+	assert.NoError(t, store.Save(running))
+	assert.NoError(t, store.Save(failed))
+	// saving an already completed submission again doesn't notify again
+	assert.NoError(t, store.Save(failed))
+	assert.Equal(t, 2, pendingCount(t, d.outbox))
+
+	quit := make(chan struct{})
+	defer close(quit)
+	go d.Run(quit)
+
+	assert.True(t, waitFor(func() bool { return receiver.count() == 1 }))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, receiver.count())
+	var payload Payload
+	assert.NoError(t, json.Unmarshal(receiver.received[0].body, &payload))
+	assert.Equal(t, failed.ID, payload.Submission.ID)
+	assert.Equal(t, "", receiver.received[0].header.Get(SignatureHeader))
+}
+
+func TestDispatcher_DeliversPendingSubmissionsAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testwebhook-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	receiver := &stubReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	// many submissions completed while the server wasn't delivering
+	_, registry, store := newTestDispatcher(t, dir)
+	_, err = registry.Register(Webhook{URL: server.URL})
+	assert.NoError(t, err)
+	const completed = 300
+	for i := 0; i < completed; i++ {
+		meta := submission.NewMetadata("problem1", cpp, testcase.ReleaseMode)
+		meta.Status = submission.AllTestsCompleted
+		assert.NoError(t, store.Save(meta))
+	}
+
+	d, _, _ := newTestDispatcher(t, dir)
+	assert.Equal(t, completed, pendingCount(t, d.outbox))
+	quit := make(chan struct{})
+	defer close(quit)
+	go d.Run(quit)
+	assert.True(t, waitFor(func() bool { return receiver.count() == completed }))
+	assert.True(t, waitFor(func() bool { return pendingCount(t, d.outbox) == 0 }))
+}
@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tomekjarosik/inout_tester/internal/submission"
+)
+
+// Outbox persisted queue of completed submissions whose results weren't delivered to webhooks yet
+type Outbox interface {
+	// Add records the completed submission, it stays pending until Done, also across restarts
+	Add(meta submission.Metadata) error
+	// Done removes the submission after its results were delivered
+	Done(id submission.ID) error
+	// Pending submissions, the oldest first
+	Pending() ([]submission.Metadata, error)
+	// Added is signalled after submissions are added
+	Added() <-chan struct{}
+}
+
+const outboxFileExtension = ".json"
+
+type fileOutbox struct {
+	dir   string
+	added chan struct{}
+}
+
+// NewFileOutbox constructor of the Outbox which keeps a JSON file per pending submission in dir
+func NewFileOutbox(dir string) (Outbox, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileOutbox{dir: dir, added: make(chan struct{}, 1)}, nil
+}
+
+func (o *fileOutbox) filename(id submission.ID) string {
+	return filepath.Join(o.dir, id.String()+outboxFileExtension)
+}
+
+func (o *fileOutbox) Add(meta submission.Metadata) error {
+	content, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	// written under a temporary name, so that Pending never reads a partially written file
+	tmp, err := ioutil.TempFile(o.dir, meta.ID.String()+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), o.filename(meta.ID))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	select {
+	case o.added <- struct{}{}:
+	default:
+		// the dispatcher wasn't woken up yet
+	}
+	return nil
+}
+
+func (o *fileOutbox) Done(id submission.ID) error {
+	err := os.Remove(o.filename(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (o *fileOutbox) Pending() ([]submission.Metadata, error) {
+	files, err := ioutil.ReadDir(o.dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	res := make([]submission.Metadata, 0, len(files))
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), outboxFileExtension) {
+			continue
+		}
+		filename := filepath.Join(o.dir, f.Name())
+		content, err := ioutil.ReadFile(filename)
+		if os.IsNotExist(err) {
+			// delivered in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var meta submission.Metadata
+		if err = json.Unmarshal(content, &meta); err != nil {
+			log.Println("webhook: skipping invalid outbox entry", filename, err)
+			continue
+		}
+		res = append(res, meta)
+	}
+	return res, nil
+}
+
+func (o *fileOutbox) Added() <-chan struct{} {
+	return o.added
+}
+
+// outboxStorage adds submissions to the outbox when their final status is saved
+type outboxStorage struct {
+	submission.Storage
+	outbox Outbox
+}
+
+// WithOutbox wraps the Storage of the Processor, so that every submission which becomes completed
+// is added to the outbox before Save returns
+func WithOutbox(store submission.Storage, outbox Outbox) submission.Storage {
+	return &outboxStorage{Storage: store, outbox: outbox}
+}
+
+func (s *outboxStorage) Save(meta submission.Metadata) error {
+	previous, found := s.Storage.Get(meta.ID)
+	if err := s.Storage.Save(meta); err != nil {
+		return err
+	}
+	if !meta.Status.Final() || (found && previous.Status.Final()) {
+		return nil
+	}
+	return s.outbox.Add(meta)
+}
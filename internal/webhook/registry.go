@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	guuid "github.com/google/uuid"
+)
+
+// Webhook receiver of submission results
+type Webhook struct {
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	ProblemName  string    `json:"problemName,omitempty"` // empty means all problems
+	Secret       string    `json:"secret,omitempty"`      // used to sign payloads, no signature if empty
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// Matches returns true if webhook should be notified about submissions of given problem
+func (w Webhook) Matches(problemName string) bool {
+	return w.ProblemName == "" || w.ProblemName == problemName
+}
+
+// ErrNotFound returned by Registry.Remove when no webhook has given id
+var ErrNotFound = errors.New("webhook not found")
+
+// Registry keeps registered webhooks
+type Registry interface {
+	Register(w Webhook) (Webhook, error)
+	Remove(id string) error
+	List() []Webhook
+	Matching(problemName string) []Webhook
+}
+
+type fileRegistry struct {
+	filename string
+	webhooks map[string]Webhook
+	m        sync.Mutex
+}
+
+// NewFileRegistry constructor of the Registry persisted as a JSON file
+func NewFileRegistry(filename string) (Registry, error) {
+	r := &fileRegistry{
+		filename: filename,
+		webhooks: make(map[string]Webhook),
+	}
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var webhooks []Webhook
+	if err = json.Unmarshal(content, &webhooks); err != nil {
+		return nil, err
+	}
+	for _, w := range webhooks {
+		r.webhooks[w.ID] = w
+	}
+	return r, nil
+}
+
+func (r *fileRegistry) Register(w Webhook) (Webhook, error) {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return w, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return w, errors.New("webhook url must be an absolute http(s) url")
+	}
+	w.ID = guuid.New().String()
+	w.RegisteredAt = time.Now()
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.webhooks[w.ID] = w
+	if err = r.persist(); err != nil {
+		// not registered unless it survives a restart
+		delete(r.webhooks, w.ID)
+		return w, err
+	}
+	return w, nil
+}
+
+func (r *fileRegistry) Remove(id string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	w, ok := r.webhooks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(r.webhooks, id)
+	if err := r.persist(); err != nil {
+		r.webhooks[id] = w
+		return err
+	}
+	return nil
+}
+
+func (r *fileRegistry) List() []Webhook {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.sorted(func(Webhook) bool { return true })
+}
+
+func (r *fileRegistry) Matching(problemName string) []Webhook {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.sorted(func(w Webhook) bool { return w.Matches(problemName) })
+}
+
+func (r *fileRegistry) sorted(pred func(Webhook) bool) []Webhook {
+	res := make([]Webhook, 0)
+	for _, w := range r.webhooks {
+		if pred(w) {
+			res = append(res, w)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].RegisteredAt.Before(res[j].RegisteredAt) })
+	return res
+}
+
+func (r *fileRegistry) persist() error {
+	if err := os.MkdirAll(filepath.Dir(r.filename), 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(r.sorted(func(Webhook) bool { return true }), "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.filename, content, 0600)
+}
@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileRegistry_RegisterPersistRemove(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testwebhook-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "webhooks.json")
+
+	r, err := NewFileRegistry(filename)
+	assert.NoError(t, err)
+	_, err = r.Register(Webhook{URL: "not a url"})
+	assert.Error(t, err)
+
+	global, err := r.Register(Webhook{URL: "http://localhost:9999/all"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, global.ID)
+	perProblem, err := r.Register(Webhook{URL: "https://example.com/hook", ProblemName: "p1", Secret: "x"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(r.Matching("p1")))
+	assert.Equal(t, 1, len(r.Matching("p2")))
+	assert.Equal(t, global.ID, r.Matching("p2")[0].ID)
+
+	r2, err := NewFileRegistry(filename)
+	assert.NoError(t, err)
+	list := r2.List()
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, global.ID, list[0].ID)
+	assert.Equal(t, "x", list[1].Secret)
+
+	assert.NoError(t, r2.Remove(perProblem.ID))
+	assert.Equal(t, ErrNotFound, r2.Remove(perProblem.ID))
+	r3, err := NewFileRegistry(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(r3.List()))
+}
+
+func TestFileRegistry_KeepsStateWhenPersistingFails(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testwebhook-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "webhooks.json")
+
+	r, err := NewFileRegistry(filename)
+	assert.NoError(t, err)
+	w, err := r.Register(Webhook{URL: "http://localhost:9999/all"})
+	assert.NoError(t, err)
+
+	// the file can't be written when a directory is in its place
+	assert.NoError(t, os.Remove(filename))
+	assert.NoError(t, os.Mkdir(filename, 0755))
+	_, err = r.Register(Webhook{URL: "http://localhost:9999/other"})
+	assert.Error(t, err)
+	err = r.Remove(w.ID)
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrNotFound, err)
+	list := r.List()
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, w.ID, list[0].ID)
+}
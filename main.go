@@ -7,22 +7,40 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/tomekjarosik/inout_tester/internal/submission"
 	testcase "github.com/tomekjarosik/inout_tester/internal/testcase"
+	"github.com/tomekjarosik/inout_tester/internal/webhook"
 )
 
 var flagPort int
 var flagProblemsDirectory string
 var flagSubmissionsDirectory string
+var flagWebhooksDirectory string
+var flagConfigFile string
+var flagCompilationCacheDirectory string
+var flagCompilationCacheSize int64
+var flagStorage string
+var flagAdminToken string
 
 func init() {
 	flag.IntVar(&flagPort, "port", 8080, "Webserver port")
 	flag.StringVar(&flagProblemsDirectory, "problems-dir", "problems",
 		"Root directory where problems are located. Each problem is a sub-dir and contains test data (.in/.out files)")
 	flag.StringVar(&flagSubmissionsDirectory, "submissions-dir", "submissions", "Directory where submissions will be stored")
+	flag.StringVar(&flagStorage, "storage", "files",
+		"Storage of submissions: 'files' (metadata as .meta files, loaded into memory) or 'sqlite' (metadata in a database)")
+	flag.StringVar(&flagConfigFile, "config", "", "Server configuration file (JSON), see config.example.json")
+	flag.StringVar(&flagCompilationCacheDirectory, "compilation-cache-dir", "compilation-cache", "Directory where compiled solutions are cached")
+	flag.Int64Var(&flagCompilationCacheSize, "compilation-cache-size", 1024, "Maximum size of the compilation cache in megabytes, 0 disables the cache")
+	flag.StringVar(&flagAdminToken, "admin-token", "", "Bearer token of admin endpoints (/api/admin/..., /api/webhooks), they are disabled if empty")
+	flag.StringVar(&flagWebhooksDirectory, "webhooks-dir", "webhooks", "Directory where registered webhooks and the log of their deliveries are stored")
 }
 
 func generateMultiplyBy2(dir string) {
@@ -48,19 +66,134 @@ func generateMultiplyBy2(dir string) {
 	assert(ioutil.WriteFile(path.Join(problemPath, "t4.out"), []byte("-199999999999999999999999999999999999999999999999998\n"), 0666))
 }
 
-// TODO: Handle Ctrl+C properly
+// shutdownTimeout how long the submission in progress may take to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
+// handleShutdown lets the processor finish the submission in progress and save pending updates after Ctrl+C,
+// then stops watching the storage and makes sure no test processes outlive the server.
+// The second Ctrl+C exits right away.
+func handleShutdown(sp submission.Processor, processed <-chan struct{}, stopWatching func()) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	sig := <-signals
+	log.Println("Received", sig, "shutting down...")
+	sp.Quit()
+	select {
+	case <-processed:
+	case sig = <-signals:
+		log.Println("Received", sig, "again, progress of the submission in progress is lost")
+	case <-time.After(shutdownTimeout):
+		log.Println("Submission in progress didn't finish in", shutdownTimeout, "its progress is lost")
+	}
+	stopWatching()
+	testcase.KillAllProcessGroups()
+	os.Exit(0)
+}
+
+// newStorage storage of submissions selected with -storage
+func newStorage() submission.Storage {
+	switch flagStorage {
+	case "files":
+		return submission.NewDefaultStorage(flagSubmissionsDirectory)
+	case "sqlite":
+		return submission.NewSQLiteStorage(flagSubmissionsDirectory)
+	}
+	log.Panic("unknown storage: ", flagStorage)
+	return nil
+}
+
 // TODO: add ability to run tests in parallel, for each submission
 func main() {
-	fmt.Println("Starting...")
 	flag.Parse()
+	switch flag.Arg(0) {
+	case "backup":
+		runBackup(flag.Args()[1:])
+		return
+	case "restore":
+		runRestore(flag.Args()[1:])
+		return
+	case "submit":
+		os.Exit(runSubmit(flag.Args()[1:]))
+	case "status":
+		os.Exit(runStatus(flag.Args()[1:]))
+	case "upgrade":
+		upgraded, err := submission.UpgradeAll(newStorage())
+		if err != nil {
+			log.Fatal("upgrade failed: ", err)
+		}
+		fmt.Printf("Rewrote %d submissions in schema version %d\n", upgraded, submission.CurrentSchemaVersion)
+		return
+	case "migrate":
+		migrated, err := submission.MigrateToSQLite(flagSubmissionsDirectory)
+		if err != nil {
+			log.Fatal("migration failed: ", err)
+		}
+		fmt.Printf("Migrated %d submissions from .meta files into %s, start the server with -storage sqlite\n",
+			migrated, path.Join(flagSubmissionsDirectory, submission.SQLiteDatabaseFilename))
+		return
+	}
+	fmt.Println("Starting...")
+
+	var config Config
+	if flagConfigFile != "" {
+		var err error
+		config, err = loadConfig(flagConfigFile)
+		if err != nil {
+			log.Panic("unable to read config: ", err)
+		}
+		if err = config.apply(); err != nil {
+			log.Panic("invalid config: ", err)
+		}
+	}
 
-	storage := submission.NewDefaultStorage(flagSubmissionsDirectory)
+	log.Println("Checking available compilers...")
+	for _, s := range testcase.SelfCheck() {
+		if s.Available {
+			log.Printf("%s %s: %s\n", s.Language, s.CompilationMode, s.Version)
+		}
+	}
+	if backtraces := testcase.CurrentCrashBacktraces(); backtraces.Enabled {
+		if _, err := exec.LookPath(backtraces.Debugger[0]); err != nil {
+			log.Println("Backtraces of crashed solutions are not available:", err)
+		}
+	}
+
+	if killed, err := testcase.SweepOrphanedProcessGroups(); err != nil {
+		log.Println("unable to sweep orphaned test processes:", err)
+	} else if killed > 0 {
+		log.Printf("Killed %d test process groups orphaned by a previous run\n", killed)
+	}
+
+	storage := newStorage()
 	if err := storage.Init(); err != nil {
 		log.Panic(err)
 	}
 	testcaseArchive := testcase.NewArchive(flagProblemsDirectory)
-	sp := submission.NewProcessor(storage, testcaseArchive)
-	rp := NewRequestProcessor(storage, sp, testcaseArchive)
+	events := submission.NewEventBus()
+	storageChanges, stopWatching := storage.Watch()
+	go submission.PublishRemovals(storageChanges, events)
+	var compilationCache testcase.CompilationCache
+	if flagCompilationCacheSize > 0 {
+		cache, err := testcase.NewFileCompilationCache(flagCompilationCacheDirectory, flagCompilationCacheSize<<20)
+		if err != nil {
+			log.Panic(err)
+		}
+		compilationCache = cache
+	}
+	webhooks, err := webhook.NewFileRegistry(path.Join(flagWebhooksDirectory, "webhooks.json"))
+	if err != nil {
+		log.Panic(err)
+	}
+	outbox, err := webhook.NewFileOutbox(path.Join(flagWebhooksDirectory, "outbox"))
+	if err != nil {
+		log.Panic(err)
+	}
+	deliveries := webhook.NewFileDeliveryLog(path.Join(flagWebhooksDirectory, "deliveries.log"))
+	wp := NewWebhookRequestProcessor(webhooks, deliveries)
+	go webhook.NewDispatcher(webhooks, deliveries, outbox).Run(nil)
+
+	sp := submission.NewProcessor(webhook.WithOutbox(storage, outbox), testcaseArchive, events, compilationCache)
+	rp := NewRequestProcessor(storage, sp, testcaseArchive, events)
 
 	problems, err := testcaseArchive.Problems()
 	if err != nil || len(problems) == 0 {
@@ -69,10 +202,32 @@ func main() {
 	myRouter := mux.NewRouter().StrictSlash(true)
 	myRouter.HandleFunc("/", rp.RenderHomePage)
 	myRouter.HandleFunc("/submit", rp.wwwSubmitForm)
+	myRouter.HandleFunc("/submission/{id}", rp.wwwSubmissionPage)
+	myRouter.HandleFunc("/submission/{id}/coverage", rp.wwwCoveragePage)
 	myRouter.HandleFunc("/api/submit", rp.apiSubmitSolutionHandler).Methods("POST")
 	myRouter.HandleFunc("/api/submission/{problemName}/{id}", rp.apiReadSingleSubmission)
+	myRouter.HandleFunc("/api/events", rp.apiSubmissionEvents)
+	myRouter.HandleFunc("/api/toolchains", rp.apiToolchains).Methods("GET")
+	myRouter.HandleFunc("/api/problems/{problemName}/stub", rp.apiProblemStub).Methods("GET")
+	rp.registerAPIv1(myRouter)
+	ap := NewAdminRequestProcessor(storage, sp, flagProblemsDirectory, flagAdminToken)
+	myRouter.HandleFunc("/api/admin/backup", ap.authorized(ap.apiBackup)).Methods("GET")
+	myRouter.HandleFunc("/api/admin/restore", ap.authorized(ap.apiRestore)).Methods("POST")
+	myRouter.HandleFunc("/api/admin/submissions/{id}/rejudge", ap.authorized(ap.apiRejudge)).Methods("POST")
+	myRouter.HandleFunc("/api/webhooks", ap.authorized(wp.apiListWebhooks)).Methods("GET")
+	myRouter.HandleFunc("/api/webhooks", ap.authorized(wp.apiRegisterWebhook)).Methods("POST")
+	myRouter.HandleFunc("/api/webhooks/deliveries", ap.authorized(wp.apiListDeliveries)).Methods("GET")
+	myRouter.HandleFunc("/api/webhooks/{id}", ap.authorized(wp.apiRemoveWebhook)).Methods("DELETE")
 
-	go sp.Process()
+	if retention := config.retentionPolicy(); retention.Enabled() {
+		go submission.RunRetention(storage, retention, nil)
+	}
+	processed := make(chan struct{})
+	go func() {
+		sp.Process()
+		close(processed)
+	}()
+	go handleShutdown(sp, processed, stopWatching)
 	fmt.Printf("Started new server at http://localhost:%d\n", flagPort)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", flagPort), myRouter))
 }
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/tomekjarosik/inout_tester/internal/submission"
@@ -20,41 +27,190 @@ type RequestProcessor struct {
 	SubmissionStorage   submission.Storage
 	SubmissionProcessor submission.Processor
 	TestcaseArchive     testcase.Archive
+	Events              submission.EventBus
 }
 
 // NewRequestProcessor constructor
-func NewRequestProcessor(store submission.Storage, sp submission.Processor, archive testcase.Archive) RequestProcessor {
-	return RequestProcessor{store, sp, archive}
+func NewRequestProcessor(store submission.Storage, sp submission.Processor, archive testcase.Archive, events submission.EventBus) RequestProcessor {
+	return RequestProcessor{store, sp, archive, events}
 }
 
 func (rp *RequestProcessor) apiSubmitSolutionHandler(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseMultipartForm(10 << 20)
+	metadata, status, err := rp.submitSolution(r)
 	if err != nil {
-		log.Fatal(err)
+		http.Error(w, err.Error(), status)
+		return
 	}
+	http.Redirect(w, r, "/submission/"+metadata.ID.String(), http.StatusSeeOther)
+}
 
+// submitSolution validates the multipart form of a submission, stores the solution and queues it for processing.
+// Returns the HTTP status code for the error.
+func (rp *RequestProcessor) submitSolution(r *http.Request) (submission.Metadata, int, error) {
+	var metadata submission.Metadata
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		return metadata, http.StatusBadRequest, fmt.Errorf("expected a multipart form: %v", err)
+	}
 	formFile, header, err := r.FormFile("solution")
 	if err != nil {
-		http.Error(w, "unable to open solution file", http.StatusBadRequest)
-		return
+		return metadata, http.StatusBadRequest, errors.New("unable to open solution file")
 	}
 	defer formFile.Close()
 	problemName := r.Form.Get("problemName")
-	compilationMode, _ := strconv.Atoi(r.Form.Get("compilationMode"))
+	known, err := rp.knownProblem(problemName)
+	if err != nil {
+		return metadata, http.StatusInternalServerError, err
+	}
+	if !known {
+		return metadata, http.StatusBadRequest, fmt.Errorf("unknown problem '%s'", problemName)
+	}
+	compilationMode := testcase.CompilationMode(r.Form.Get("compilationMode"))
+	lang, ok := testcase.LookupLanguage(r.Form.Get("language"))
+	if !ok {
+		return metadata, http.StatusBadRequest, errors.New("unknown language " + r.Form.Get("language"))
+	}
+	if lang.UsesCompilationMode() {
+		profile, ok := testcase.LookupCompilationProfile(compilationMode)
+		if !ok || !profile.Supports(lang) {
+			return metadata, http.StatusBadRequest, fmt.Errorf("compilation mode '%s' is not available for %s", compilationMode, lang.Name)
+		}
+	} else {
+		compilationMode = testcase.ReleaseMode
+	}
+	if !testcase.ToolchainAvailable(lang, compilationMode) {
+		return metadata, http.StatusBadRequest, fmt.Errorf("compiler for %s in compilation mode '%s' is not installed on the server", lang.Name, compilationMode)
+	}
 
-	log.Println("compilationMode=", compilationMode)
-	metadata := submission.NewMetadata(problemName, testcase.CompilationMode(compilationMode))
+	log.Println("language=", lang.ID, "compilationMode=", compilationMode)
+	metadata = submission.NewMetadata(problemName, lang, compilationMode)
+	if format := testcase.ArchiveFormatOf(header.Filename); format != "" {
+		metadata.SetSourceArchive(format, r.Form.Get("entryFile"))
+	}
+	metadata.Author = strings.TrimSpace(r.Form.Get("author"))
 	fmt.Println("submissionMetadata:", metadata)
-	rp.SubmissionStorage.Upload(metadata, formFile)
+	if err = rp.SubmissionStorage.Upload(&metadata, formFile); err != nil {
+		return metadata, http.StatusInternalServerError, err
+	}
+	rp.SubmissionProcessor.Submit(metadata)
+
+	log.Printf("File %s uploaded successfully as submission %s\n", header.Filename, metadata.ID)
+	return metadata, http.StatusOK, nil
+}
+
+// knownProblem returns true if the archive has the problem
+func (rp *RequestProcessor) knownProblem(problemName string) (bool, error) {
+	problems, err := rp.TestcaseArchive.Problems()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false, err
+	}
+	for _, p := range problems {
+		if p == problemName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// apiSubmissionEvents streams submission events as Server-Sent Events, optionally only for a single submission (?id=)
+func (rp *RequestProcessor) apiSubmissionEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
 		return
 	}
-	rp.SubmissionProcessor.Submit(metadata)
+	var onlyID *submission.ID
+	if key := r.URL.Query().Get("id"); key != "" {
+		submissionID, err := submission.ParseID(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		onlyID = &submissionID
+	}
+
+	events, unsubscribe := rp.Events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if onlyID != nil && *onlyID != e.SubmissionID {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Println("unable to encode event:", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// parseQuery reads a submission.Query from URL parameters: problem, status, mode, author,
+// from and to (RFC 3339 or YYYY-MM-DD dates, "to" is exclusive), order=oldest, limit and cursor
+func parseQuery(values url.Values) (submission.Query, error) {
+	q := submission.Query{
+		ProblemName:     values.Get("problem"),
+		CompilationMode: testcase.CompilationMode(values.Get("mode")),
+		Author:          values.Get("author"),
+		Cursor:          values.Get("cursor"),
+	}
+	var err error
+	if status := values.Get("status"); status != "" {
+		if q.Status, err = submission.ParseStatus(status); err != nil {
+			return q, fmt.Errorf("unknown status '%s'", status)
+		}
+	}
+	if q.SubmittedAfter, err = parseQueryTime(values.Get("from")); err != nil {
+		return q, err
+	}
+	if q.SubmittedBefore, err = parseQueryTime(values.Get("to")); err != nil {
+		return q, err
+	}
+	switch values.Get("order") {
+	case "", "newest":
+	case "oldest":
+		q.Oldest = true
+	default:
+		return q, fmt.Errorf("order must be 'newest' or 'oldest'")
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 1 {
+			return q, fmt.Errorf("invalid limit '%s'", limit)
+		}
+	}
+	return q, q.Validate()
+}
 
-	fmt.Fprintf(w, website.HtmlDocumentWrap(
-		fmt.Sprintf(` <meta http-equiv="refresh" content="2;url=/" />
-			File %s uploaded successfully! You will be redirected to the Home Page in 2 seconds...`, header.Filename)))
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("invalid time '%s', expected RFC 3339 or YYYY-MM-DD", value)
+	}
+	return t, nil
 }
 
 func (rp *RequestProcessor) apiReadSingleSubmission(w http.ResponseWriter, r *http.Request) {
@@ -77,6 +233,51 @@ func (rp *RequestProcessor) apiReadSingleSubmission(w http.ResponseWriter, r *ht
 	}
 }
 
+// apiProblemStub template of a function-only solution, provided by the grader of the problem
+func (rp *RequestProcessor) apiProblemStub(w http.ResponseWriter, r *http.Request) {
+	problemName := mux.Vars(r)["problemName"]
+	known, err := rp.knownProblem(problemName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !known {
+		http.NotFound(w, r)
+		return
+	}
+	lang, ok := testcase.LookupLanguage(r.URL.Query().Get("language"))
+	if !ok {
+		http.Error(w, "unknown language "+r.URL.Query().Get("language"), http.StatusBadRequest)
+		return
+	}
+	config, err := rp.TestcaseArchive.Config(problemName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	grader, ok := config.Grader(lang)
+	if !ok || grader.Stub == "" {
+		http.NotFound(w, r)
+		return
+	}
+	stub, err := rp.TestcaseArchive.ProblemFile(problemName, grader.Stub)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(grader.SolutionFilenameFor(lang))))
+	w.Write(stub)
+}
+
+// apiToolchains results of the compiler self-check done at startup
+func (rp *RequestProcessor) apiToolchains(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(testcase.ToolchainStatuses()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // TODO(tjarosik): Read problem list from the Config
 func (rp *RequestProcessor) wwwSubmitForm(w http.ResponseWriter, r *http.Request) {
 	tmpl, err := website.SubmitForm()
@@ -91,19 +292,198 @@ func (rp *RequestProcessor) wwwSubmitForm(w http.ResponseWriter, r *http.Request
 		http.Error(w, "failed read problems from 'problems' directory: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	compilationModes := []testcase.CompilationMode{testcase.ReleaseMode, testcase.AnalyzeClangMode, testcase.AnalyzeGplusplusMode}
-
+	type ProblemOption struct {
+		Name string
+		// StubFamilies space separated language families for which the problem provides a solution template
+		StubFamilies string
+	}
+	type LanguageOption struct {
+		testcase.Language
+		Available bool
+	}
+	type CompilationModeOption struct {
+		testcase.CompilationProfile
+		Available bool
+		// UnavailableFor space separated IDs of languages which can't be compiled in this mode
+		UnavailableFor string
+	}
 	type ViewData struct {
-		Problems         []string
-		CompilationModes []testcase.CompilationMode
+		Problems         []ProblemOption
+		Languages        []LanguageOption
+		CompilationModes []CompilationModeOption
+	}
+	data := ViewData{}
+	for _, problem := range problems {
+		option := ProblemOption{Name: problem}
+		config, err := rp.TestcaseArchive.Config(problem)
+		if err != nil {
+			log.Println("unable to read config of problem", problem, err)
+		}
+		families := make([]string, 0)
+		for family, grader := range config.Graders {
+			if grader.Stub != "" {
+				families = append(families, family)
+			}
+		}
+		option.StubFamilies = strings.Join(families, " ")
+		data.Problems = append(data.Problems, option)
+	}
+	languages := testcase.Languages()
+	profiles := testcase.CompilationProfiles()
+	for _, lang := range languages {
+		option := LanguageOption{Language: lang}
+		if !lang.UsesCompilationMode() {
+			option.Available = testcase.ToolchainAvailable(lang, testcase.ReleaseMode)
+		}
+		for _, p := range profiles {
+			if p.Supports(lang) && testcase.ToolchainAvailable(lang, p.ID) {
+				option.Available = true
+			}
+		}
+		data.Languages = append(data.Languages, option)
+	}
+	for _, p := range profiles {
+		option := CompilationModeOption{CompilationProfile: p}
+		unavailable := make([]string, 0)
+		for _, lang := range languages {
+			if !lang.UsesCompilationMode() {
+				continue
+			}
+			if p.Supports(lang) && testcase.ToolchainAvailable(lang, p.ID) {
+				option.Available = true
+			} else {
+				unavailable = append(unavailable, lang.ID)
+			}
+		}
+		option.UnavailableFor = strings.Join(unavailable, " ")
+		data.CompilationModes = append(data.CompilationModes, option)
 	}
-	data := ViewData{Problems: problems, CompilationModes: compilationModes}
 
 	if err = tmpl.Execute(w, data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// sourceLookup finds content of files reported by the compiler among sources of the submission
+func sourceLookup(metadata submission.Metadata, lang testcase.Language, content []byte) func(file string) ([]byte, bool) {
+	if metadata.SourceArchive == "" {
+		return func(file string) ([]byte, bool) {
+			return content, lang.IsSolutionFile(file)
+		}
+	}
+	files, err := testcase.ReadSourceArchive(content, metadata.SourceArchive)
+	return func(file string) ([]byte, bool) {
+		if err != nil {
+			return nil, false
+		}
+		source, ok := files[path.Clean(file)]
+		return source, ok
+	}
+}
+
+func (rp *RequestProcessor) wwwSubmissionPage(w http.ResponseWriter, r *http.Request) {
+	submissionID, err := submission.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metadata, ok := rp.SubmissionStorage.Get(submissionID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	tmpl, err := website.SubmissionPageTemplate()
+	if err != nil {
+		http.Error(w, "unable to parse template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	type PageData struct {
+		submission.Metadata
+		Snippets []website.DiagnosticSnippet
+	}
+	data := PageData{Metadata: metadata}
+	diagnostics := append([]testcase.Diagnostic{}, metadata.Diagnostics...)
+	for _, f := range metadata.StaticAnalysis {
+		diagnostics = append(diagnostics, f.Diagnostic())
+	}
+	if lang, ok := testcase.LookupLanguage(metadata.Language); ok && len(diagnostics) > 0 {
+		solution, err := rp.SubmissionStorage.Download(metadata)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		source, err := ioutil.ReadAll(solution)
+		solution.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data.Snippets = website.DiagnosticSnippets(diagnostics, sourceLookup(metadata, lang, source), 2)
+	}
+	if err = tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (rp *RequestProcessor) wwwCoveragePage(w http.ResponseWriter, r *http.Request) {
+	submissionID, err := submission.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metadata, ok := rp.SubmissionStorage.Get(submissionID)
+	lang, known := testcase.LookupLanguage(metadata.Language)
+	if !ok || !known || metadata.Coverage == nil {
+		http.NotFound(w, r)
+		return
+	}
+	tmpl, err := website.CoveragePageTemplate()
+	if err != nil {
+		http.Error(w, "unable to parse template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	artifact, err := rp.SubmissionStorage.DownloadArtifact(metadata, submission.CoverageArtifact)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var report testcase.CoverageReport
+	err = json.NewDecoder(artifact).Decode(&report)
+	artifact.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	solution, err := rp.SubmissionStorage.Download(metadata)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	source, err := ioutil.ReadAll(solution)
+	solution.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lookup := sourceLookup(metadata, lang, source)
+	type PageData struct {
+		submission.Metadata
+		Sources []website.CoveredSource
+	}
+	data := PageData{Metadata: metadata, Sources: website.CoveredSources(report, func(file string) ([]byte, bool) {
+		if content, ok := lookup(file); ok {
+			return content, true
+		}
+		// files of the problem grader
+		content, err := rp.TestcaseArchive.ProblemFile(metadata.ProblemName, file)
+		return content, err == nil
+	})}
+	if err = tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// RenderHomePage renders a page of submissions selected by filters in URL parameters, see parseQuery
 func (rp *RequestProcessor) RenderHomePage(w http.ResponseWriter, r *http.Request) {
 	tmpl, err := website.HomePageTemplate()
 
@@ -111,17 +491,48 @@ func (rp *RequestProcessor) RenderHomePage(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "unable to parse template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	allSubmissions := rp.SubmissionStorage.List()
+	filters := r.URL.Query()
+	q, err := parseQuery(filters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := rp.SubmissionStorage.Query(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	limit := 50
-	for i := range allSubmissions {
-		if len(allSubmissions[i].CompletedTestCases) > limit {
+	for i := range page.Submissions {
+		if len(page.Submissions[i].CompletedTestCases) > limit {
 			// we have to cut them down
-			allSubmissions[i].CompletedTestCases = allSubmissions[i].CompletedTestCases[:limit]
+			page.Submissions[i].CompletedTestCases = page.Submissions[i].CompletedTestCases[:limit]
 		}
 	}
-
-	if err = tmpl.Execute(w, allSubmissions); err != nil {
+	problems, err := rp.TestcaseArchive.Problems()
+	if err != nil {
+		log.Println("unable to read problems:", err)
+	}
+	nextPage := ""
+	if page.NextCursor != "" {
+		next := url.Values{}
+		for k, v := range filters {
+			next[k] = v
+		}
+		next.Set("cursor", page.NextCursor)
+		nextPage = "/?" + next.Encode()
+	}
+	data := struct {
+		Submissions      []submission.Metadata
+		Filters          url.Values
+		Problems         []string
+		Statuses         []submission.Status
+		CompilationModes []testcase.CompilationProfile
+		NextPage         string
+		FollowNew        bool
+	}{page.Submissions, filters, problems, submission.Statuses(), testcase.CompilationProfiles(), nextPage,
+		q.Cursor == "" && !q.Oldest}
+	if err = tmpl.Execute(w, data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
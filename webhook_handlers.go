@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tomekjarosik/inout_tester/internal/webhook"
+)
+
+// WebhookRequestProcessor processes HTTP requests managing webhooks
+type WebhookRequestProcessor struct {
+	Registry   webhook.Registry
+	Deliveries webhook.DeliveryLog
+}
+
+// NewWebhookRequestProcessor constructor
+func NewWebhookRequestProcessor(registry webhook.Registry, deliveries webhook.DeliveryLog) WebhookRequestProcessor {
+	return WebhookRequestProcessor{registry, deliveries}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (wp *WebhookRequestProcessor) apiListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks := wp.Registry.List()
+	for i := range webhooks {
+		if webhooks[i].Secret != "" {
+			webhooks[i].Secret = "<redacted>"
+		}
+	}
+	writeJSON(w, http.StatusOK, webhooks)
+}
+
+func (wp *WebhookRequestProcessor) apiRegisterWebhook(w http.ResponseWriter, r *http.Request) {
+	var hook webhook.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		http.Error(w, "invalid webhook: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	hook, err := wp.Registry.Register(hook)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+func (wp *WebhookRequestProcessor) apiRemoveWebhook(w http.ResponseWriter, r *http.Request) {
+	err := wp.Registry.Remove(mux.Vars(r)["id"])
+	if err == webhook.ErrNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (wp *WebhookRequestProcessor) apiListDeliveries(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, wp.Deliveries.Recent())
+}
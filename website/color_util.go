@@ -22,4 +22,26 @@ func TestCaseStatusColorFormat(status testcase.Status) string {
 
 func TestCaseDurationFormatFunc(duration time.Duration) string {
 	return fmt.Sprintf("%ds %3d ms", int(duration.Seconds()), int(duration.Milliseconds()))
-}
\ No newline at end of file
+}
+
+// DiagnosticSeverityColorFormat color of a compiler diagnostic or a finding of a static analyzer
+func DiagnosticSeverityColorFormat(severity string) string {
+	switch severity {
+	case "warning", "style", "performance", "portability":
+		return "yellow lighten-4"
+	case "note", "information":
+		return "blue lighten-5"
+	}
+	return "red lighten-4"
+}
+
+// CoverageColorFormat color of a source line executed count times, non-executable lines have no color
+func CoverageColorFormat(executable bool, count int64) string {
+	if !executable {
+		return ""
+	}
+	if count == 0 {
+		return "red lighten-4"
+	}
+	return "green lighten-4"
+}
@@ -0,0 +1,83 @@
+package website
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// CoveredLine line of a source file with its coverage
+type CoveredLine struct {
+	SourceLine
+	Executable bool
+	testcase.LineCoverage
+}
+
+// CoveredSource source file annotated with hit counts of its lines
+type CoveredSource struct {
+	File string
+	// Available false if the source couldn't be found, only executable lines are listed then
+	Available bool
+	Lines     []CoveredLine
+}
+
+// CoveredSources joins files of the coverage report with their sources found with lookup
+func CoveredSources(report testcase.CoverageReport, lookup func(file string) ([]byte, bool)) []CoveredSource {
+	res := make([]CoveredSource, 0, len(report.Files))
+	for _, f := range report.Files {
+		covered := make(map[int]testcase.LineCoverage, len(f.Lines))
+		for _, l := range f.Lines {
+			covered[l.Line] = l
+		}
+		cs := CoveredSource{File: f.File}
+		source, ok := lookup(f.File)
+		if !ok {
+			for _, l := range f.Lines {
+				cs.Lines = append(cs.Lines, CoveredLine{SourceLine: SourceLine{Number: l.Line}, Executable: true, LineCoverage: l})
+			}
+			res = append(res, cs)
+			continue
+		}
+		cs.Available = true
+		for i, text := range strings.Split(strings.TrimRight(string(source), "\n"), "\n") {
+			line := CoveredLine{SourceLine: SourceLine{Number: i + 1, Text: strings.TrimRight(text, "\r")}}
+			line.LineCoverage, line.Executable = covered[i+1]
+			cs.Lines = append(cs.Lines, line)
+		}
+		res = append(res, cs)
+	}
+	return res
+}
+
+// CoveragePageTemplate source of the submission with hit counts of its lines.
+// Expects the submission as .Metadata and annotated sources as .Sources.
+func CoveragePageTemplate() (*template.Template, error) {
+	funcs := template.FuncMap{"CoverageColor": CoverageColorFormat}
+	return template.New("coveragePage").Funcs(funcs).Parse(HtmlDocumentWrap(HtmlHead() + `
+	<body class="container">
+		<nav>
+			<div class="nav-wrapper black">
+			<a href="/" class="brand-logo">INOUT</a>
+			<ul id="nav-mobile" class="right hide-on-med-and-down">
+				<li><a href="/">Home</a></li>
+				<li><a href="/submission/{{.ID}}">Submission</a></li>
+			</ul>
+			</div>
+		</nav>
+
+		<div class="section">
+			<h5>{{.ProblemName}}: coverage of {{.TestCasesCount}} tests</h5>
+			{{with .Coverage}}
+			<p>Lines: {{.LinesCovered}}/{{.LinesTotal}} ({{printf "%.1f" .LinePercent}}%){{if .BranchesTotal}},
+			branches: {{.BranchesTaken}}/{{.BranchesTotal}}{{end}}</p>
+			{{end}}
+			{{range .Sources}}
+			<h6>{{.File}}{{if not .Available}} <small>(source unavailable)</small>{{end}}</h6>
+			<pre style="border: 1px solid #dddddd;">{{range .Lines}}<span class="{{CoverageColor .Executable .Count}}">{{if .Executable}}{{printf "%7d" .Count}}{{else}}      -{{end}} {{printf "%4d" .Number}} | {{.Text}}{{if lt .BranchesTaken .Branches}}  <small>[{{.BranchesTaken}}/{{.Branches}} branches taken]</small>{{end}}</span>
+{{end}}</pre>
+			{{end}}
+		</div>
+	</body>
+`))
+}
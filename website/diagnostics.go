@@ -0,0 +1,46 @@
+package website
+
+import (
+	"strings"
+
+	"github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+// SourceLine line of the submitted source shown next to a diagnostic
+type SourceLine struct {
+	Number      int
+	Text        string
+	Highlighted bool
+}
+
+// DiagnosticSnippet diagnostic with surrounding lines of the submitted source
+type DiagnosticSnippet struct {
+	testcase.Diagnostic
+	Lines []SourceLine
+}
+
+// DiagnosticSnippets cuts contextLines lines around every diagnostic reported about sources of the solution,
+// which are found with lookup. Diagnostics about other files, e.g. system headers, are skipped.
+func DiagnosticSnippets(diagnostics []testcase.Diagnostic, lookup func(file string) ([]byte, bool), contextLines int) []DiagnosticSnippet {
+	res := make([]DiagnosticSnippet, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		source, ok := lookup(d.File)
+		if !ok {
+			continue
+		}
+		lines := strings.Split(strings.TrimRight(string(source), "\n"), "\n")
+		if d.Line < 1 || d.Line > len(lines) {
+			continue
+		}
+		snippet := DiagnosticSnippet{Diagnostic: d}
+		for n := d.Line - contextLines; n <= d.Line+contextLines; n++ {
+			if n < 1 || n > len(lines) {
+				continue
+			}
+			text := strings.TrimRight(lines[n-1], "\r")
+			snippet.Lines = append(snippet.Lines, SourceLine{Number: n, Text: text, Highlighted: n == d.Line})
+		}
+		res = append(res, snippet)
+	}
+	return res
+}
@@ -2,23 +2,11 @@ package website
 
 import (
 	"html/template"
-	"time"
-
-	"github.com/tomekjarosik/inout_tester/internal/testcase"
 )
 
 // TODO: add 'active' to first collapsible item,e.g.: <li class="active">
 func HomePageTemplate() (*template.Template, error) {
-	return template.New("homepage").Funcs(
-		template.FuncMap{
-			"TimeFormat":                func(t time.Time) string { return t.Format(time.Stamp) },
-			"ScoreColorFormat":          ScoreColorFormat,
-			"TestCaseStatusColor":       TestCaseStatusColorFormat,
-			"TestCaseDurationFormatFunc": TestCaseDurationFormatFunc,
-			"HasAnyTestCases":           func(c []testcase.CompletedTestCase) bool { return len(c) > 0 },
-			"BytesToString":             func(arr []byte) string { return string(arr) },
-			"FullCompilationCommandFor": testcase.FullCompilationCommadFor,
-		}).Parse(HtmlDocumentWrap(HtmlHead() + `
+	return template.New("homepage").Funcs(submissionFuncMap()).Parse(SubmissionDetails() + HtmlDocumentWrap(HtmlHead()+`
 	<body class="container">
 		<nav>
 			<div class="nav-wrapper black">
@@ -33,58 +21,78 @@ func HomePageTemplate() (*template.Template, error) {
 
 		<div class="divider"></div>
 
+		<form class="row section" method="GET" action="/">
+			<div class="input-field col s2">
+				<select name="problem">
+					<option value="">Any problem</option>
+					{{range .Problems}}<option value="{{.}}" {{if eq ($.Filters.Get "problem") .}}selected{{end}}>{{.}}</option>{{end}}
+				</select>
+			</div>
+			<div class="input-field col s2">
+				<select name="status">
+					<option value="">Any status</option>
+					{{range .Statuses}}<option value="{{.}}" {{if eq ($.Filters.Get "status") .String}}selected{{end}}>{{.}}</option>{{end}}
+				</select>
+			</div>
+			<div class="input-field col s2">
+				<select name="mode">
+					<option value="">Any compilation mode</option>
+					{{range .CompilationModes}}<option value="{{.ID}}" {{if eq ($.Filters.Get "mode") (print .ID)}}selected{{end}}>{{.ID}}</option>{{end}}
+				</select>
+			</div>
+			<div class="input-field col s2">
+				<input type="text" name="author" id="author" value="{{.Filters.Get "author"}}"/>
+				<label for="author">Author</label>
+			</div>
+			<div class="input-field col s1">
+				<input type="date" name="from" id="from" value="{{.Filters.Get "from"}}"/>
+				<label for="from">From</label>
+			</div>
+			<div class="input-field col s1">
+				<input type="date" name="to" id="to" value="{{.Filters.Get "to"}}"/>
+				<label for="to">Before</label>
+			</div>
+			<div class="input-field col s2">
+				<button class="btn waves-effect waves-light" type="submit">Filter<i class="material-icons right">filter_list</i></button>
+			</div>
+		</form>
+
 		<div class="section center-align">
-		{{range .}}
+		{{if not .Submissions}}<p>No submissions found</p>{{end}}
+		{{range .Submissions}}
 		<ul class="collapsible">
 		<li>
 		<div class="collapsible-header">
-			<span style="font-weight:bold">{{TimeFormat .SubmittedAt}}&nbsp;|&nbsp;</span>{{.ProblemName}}</span>&nbsp;&nbsp;{{.Status}} 
-			<span class="new badge {{ScoreColorFormat .AcceptedCount}}" data-badge-caption="points">{{.AcceptedCount}}/{{.TestCasesCount}}</span>
+			<span style="font-weight:bold">{{TimeFormat .SubmittedAt}}&nbsp;|&nbsp;</span>{{.ProblemName}}{{if .Author}}&nbsp;({{.Author}}){{end}}</span>&nbsp;&nbsp;<span id="status-{{.ID}}">{{.Status}}</span>
+			<a href="/submission/{{.ID}}"><i class="material-icons">open_in_new</i></a>
+			<span id="score-{{.ID}}" class="new badge {{ScoreColorFormat .AcceptedCount}}" data-badge-caption="points">{{.AcceptedCount}}/{{.TestCasesCount}}</span>
 		</div>
 		<div class="collapsible-body">
-			<div style="border: 2px solid black; background: lightblue;">
-			{{FullCompilationCommandFor .CompilationMode}}
-			<span class="badge lightblue"><a href="/api/submission/{{.ProblemName}}/{{.ID}}"><i class="material-icons right">cloud_download</i></a></span>
-			</div>
-			{{if HasAnyTestCases .CompletedTestCases}}
-				<table class="responsive-table striped" cellspacing="0">
-				<style type="text/css" scoped>
-					td, th {
-						border: 1px solid #dddddd;
-						text-align: left;
-						padding: 0px;
-					}
-				</style>
-				<thead>
-				<tr>
-					<th>Test name</th>
-					<th>Status</th>
-					<th>Duration</th>
-					<th>Additional info</th>
-				</tr>
-				<tbody>
-				{{range .CompletedTestCases}}
-					<tr class="{{TestCaseStatusColor .Result.Status}}">
-						<td>{{.Info.Name}} </td>
-						<td>{{.Result.Status}} </td>
-						<td>{{TestCaseDurationFormatFunc .Result.Duration}} / {{.Info.TimeLimit}}</td>
-						<td>{{.Result.Description}}</td>
-					</tr>
-				{{end}}
-				</tbody> 
-				</table>
-			{{else}}
-			<div style="border: 2px solid red;">
-			<p>{{BytesToString .CompilationOutput}}</p>
-			</div>
-			{{end}}
+			{{template "submissionDetails" .}}
 		</div>
 		</li>
 		</ul>
 		{{end}}
+		{{if .NextPage}}<a class="btn-flat waves-effect" href="{{.NextPage}}">Older submissions<i class="material-icons right">navigate_next</i></a>{{end}}
 		</div>
 	<!--JavaScript at end of body for optimized loading-->
 	<script src="https://cdnjs.cloudflare.com/ajax/libs/materialize/1.0.0/js/materialize.min.js"></script>
+	`+LiveUpdatesScript()+`
+	<script>
+	// new submissions appear only on the first page of the most recent ones
+	var followNew = {{.FollowNew}};
+	subscribeToSubmissionEvents("/api/events", function(type, e) {
+		var shown = document.getElementById("status-" + e.submissionId);
+		if (!shown && (!followNew || type === "removed")) {
+			return;
+		}
+		if (!shown || type === "removed" || isFinalStatus(e.status)) {
+			location.reload();
+			return;
+		}
+		updateSubmissionHeader(e);
+	});
+	</script>
 	</body>
 `))
 }
@@ -0,0 +1,66 @@
+package website
+
+// LiveUpdatesScript helpers to keep a page up to date with submission events streamed from /api/events
+func LiveUpdatesScript() string {
+	return `
+	<script>
+	function subscribeToSubmissionEvents(url, onEvent) {
+		var source = new EventSource(url);
+		["status", "testcase", "removed"].forEach(function(type) {
+			source.addEventListener(type, function(msg) {
+				onEvent(type, JSON.parse(msg.data));
+			});
+		});
+		return source;
+	}
+
+	function isFinalStatus(status) {
+		return status === "AllTestsCompleted" || status === "CompilationError" || status === "CompilationTimeout" || status === "InternalError";
+	}
+
+	function updateSubmissionHeader(e) {
+		var status = document.getElementById("status-" + e.submissionId);
+		if (status) {
+			status.textContent = e.status;
+		}
+		var score = document.getElementById("score-" + e.submissionId);
+		if (score) {
+			score.textContent = e.acceptedCount + "/" + e.testCasesCount;
+			score.classList.remove("red", "blue");
+			score.classList.add(e.acceptedCount === 0 ? "red" : "blue");
+		}
+	}
+
+	function formatDuration(nanoseconds) {
+		var ms = Math.floor(nanoseconds / 1000000);
+		return Math.floor(ms / 1000) + "s " + ms + " ms";
+	}
+
+	// appendTestCaseRow returns false if there is no table to append to
+	function appendTestCaseRow(e) {
+		var table = document.getElementById("testcases-" + e.submissionId);
+		if (!table) {
+			return false;
+		}
+		var tc = e.testCase;
+		var row = document.createElement("tr");
+		row.className = tc.result.status === "Accepted" ? "green lighten-3" : " red lighten-3";
+		[tc.info.name, tc.result.status, formatDuration(tc.result.duration) + " / " + (tc.info.timeLimit / 1e9) + "s", tc.result.description].forEach(function(text) {
+			var cell = document.createElement("td");
+			cell.textContent = text;
+			row.appendChild(cell);
+		});
+		if (tc.result.backtrace) {
+			var trace = document.createElement("pre");
+			trace.textContent = tc.result.backtrace.map(function(f, i) {
+				var where = f.file ? " at " + f.file + ":" + f.line : (f.library ? " from " + f.library : "");
+				return "#" + i + " " + f.function + where;
+			}).join("\n");
+			row.lastChild.appendChild(trace);
+		}
+		table.appendChild(row);
+		return true;
+	}
+	</script>
+	`
+}
@@ -0,0 +1,164 @@
+package website
+
+import (
+	"html/template"
+	"time"
+
+	"github.com/tomekjarosik/inout_tester/internal/testcase"
+)
+
+func submissionFuncMap() template.FuncMap {
+	return template.FuncMap{
+		"TimeFormat":                 func(t time.Time) string { return t.Format(time.Stamp) },
+		"ScoreColorFormat":           ScoreColorFormat,
+		"TestCaseStatusColor":        TestCaseStatusColorFormat,
+		"TestCaseDurationFormatFunc": TestCaseDurationFormatFunc,
+		"HasAnyTestCases":            func(c []testcase.CompletedTestCase) bool { return len(c) > 0 },
+		"BytesToString":              func(arr []byte) string { return string(arr) },
+		"FullCommandFor":             testcase.FullCommandFor,
+		"DiagnosticColor":            DiagnosticSeverityColorFormat,
+	}
+}
+
+// SubmissionDetails template shared by pages which show details of a single submission
+func SubmissionDetails() string {
+	return `{{define "submissionDetails"}}
+			<div style="border: 2px solid black; background: lightblue;">
+			{{FullCommandFor .Language .CompilationMode}}
+			{{if .CompilerVersion}}<br/><small>{{.CompilerVersion}}{{if .CompilationCached}} (compiled earlier, reused from cache){{end}}</small>{{end}}
+			{{with .ResultsFrom}}<br/><small>The same solution was judged earlier, results are reused from <a href="/submission/{{.}}">{{.}}</a></small>{{end}}
+			{{with .Coverage}}<br/><a href="/submission/{{$.ID}}/coverage">Line coverage: {{.LinesCovered}}/{{.LinesTotal}} ({{printf "%.1f" .LinePercent}}%)</a>{{end}}
+			<span class="badge lightblue"><a href="/api/submission/{{.ProblemName}}/{{.ID}}"><i class="material-icons right">cloud_download</i></a></span>
+			</div>
+			{{if .Diagnostics}}
+				<table class="striped" cellspacing="0">
+				<thead>
+				<tr>
+					<th>Severity</th>
+					<th>Location</th>
+					<th>Message</th>
+				</tr>
+				</thead>
+				<tbody>
+				{{range .Diagnostics}}
+					<tr class="{{DiagnosticColor .Severity}}">
+						<td>{{.Severity}}</td>
+						<td>{{if $.SourceArchive}}{{.File}}:{{end}}{{.Line}}{{if .Column}}:{{.Column}}{{end}}</td>
+						<td>{{.Message}}{{if .Option}} <code>[{{.Option}}]</code>{{end}}
+						{{range .Notes}}<br/><small>note ({{.Line}}{{if .Column}}:{{.Column}}{{end}}): {{.Message}}</small>{{end}}
+						</td>
+					</tr>
+				{{end}}
+				</tbody>
+				</table>
+			{{end}}
+			{{if .StaticAnalysis}}
+				<table class="striped" cellspacing="0">
+				<thead>
+				<tr>
+					<th>Analyzer</th>
+					<th>Check</th>
+					<th>Location</th>
+					<th>Message</th>
+				</tr>
+				</thead>
+				<tbody>
+				{{range .StaticAnalysis}}
+					<tr class="{{DiagnosticColor .Severity}}">
+						<td>{{.Tool}}</td>
+						<td>{{if .Check}}<code>{{.Check}}</code>{{end}}</td>
+						<td>{{if $.SourceArchive}}{{.File}}:{{end}}{{.Line}}{{if .Column}}:{{.Column}}{{end}}</td>
+						<td>{{.Severity}}: {{.Message}}</td>
+					</tr>
+				{{end}}
+				</tbody>
+				</table>
+			{{end}}
+			{{if HasAnyTestCases .CompletedTestCases}}
+				<table class="responsive-table striped" cellspacing="0">
+				<style type="text/css" scoped>
+					td, th {
+						border: 1px solid #dddddd;
+						text-align: left;
+						padding: 0px;
+					}
+				</style>
+				<thead>
+				<tr>
+					<th>Test name</th>
+					<th>Status</th>
+					<th>Duration</th>
+					<th>Additional info</th>
+				</tr>
+				<tbody id="testcases-{{.ID}}">
+				{{range .CompletedTestCases}}
+					<tr class="{{TestCaseStatusColor .Result.Status}}">
+						<td>{{.Info.Name}} </td>
+						<td>{{.Result.Status}} </td>
+						<td>{{TestCaseDurationFormatFunc .Result.Duration}} / {{.Info.TimeLimit}}</td>
+						<td>{{.Result.Description}}{{if .Result.Backtrace}}<pre>{{range $i, $frame := .Result.Backtrace}}#{{$i}} {{$frame}}
+{{end}}</pre>{{end}}</td>
+					</tr>
+				{{end}}
+				</tbody> 
+				</table>
+			{{else if .CompilationOutput}}
+			<div style="border: 2px solid red; text-align: left;">
+			<pre>{{BytesToString .CompilationOutput}}</pre>
+			</div>
+			{{end}}
+{{end}}`
+}
+
+// SubmissionPageTemplate page with details of a single submission, updated live while it is processed.
+// Expects the submission as .Metadata and diagnostics with the source of the solution as .Snippets.
+func SubmissionPageTemplate() (*template.Template, error) {
+	return template.New("submissionPage").Funcs(submissionFuncMap()).Parse(SubmissionDetails() + HtmlDocumentWrap(HtmlHead()+`
+	<body class="container">
+		<nav>
+			<div class="nav-wrapper black">
+			<a href="/" class="brand-logo">INOUT</a>
+			<ul id="nav-mobile" class="right hide-on-med-and-down">
+				<li><a href="/">Home</a></li>
+				<li><a class="waves-effect waves-light btn" href="/submit"><i class="material-icons right">cloud_upload</i>Submit</a></li>
+			</ul>
+			</div>
+		</nav>
+
+		<div class="divider"></div>
+
+		<div class="section">
+			<h5>
+				<span style="font-weight:bold">{{TimeFormat .SubmittedAt}}&nbsp;|&nbsp;</span>{{.ProblemName}}&nbsp;&nbsp;<span id="status-{{.ID}}">{{.Status}}</span>
+				<span id="score-{{.ID}}" class="new badge {{ScoreColorFormat .AcceptedCount}}" data-badge-caption="points">{{.AcceptedCount}}/{{.TestCasesCount}}</span>
+			</h5>
+			{{template "submissionDetails" .Metadata}}
+			{{if .Snippets}}
+			<h6>Source</h6>
+			{{range $snippet := .Snippets}}
+			<div style="border: 1px solid #dddddd; margin-bottom: 8px;">
+				<div class="{{DiagnosticColor .Severity}}">{{if $.SourceArchive}}{{.File}}: {{end}}{{.Severity}}: {{.Message}}</div>
+				<pre style="margin: 0;">{{range .Lines}}<span{{if .Highlighted}} class="{{DiagnosticColor $snippet.Severity}}"{{end}}>{{printf "%4d" .Number}} | {{.Text}}</span>
+{{end}}</pre>
+			</div>
+			{{end}}
+			{{end}}
+		</div>
+	<!--JavaScript at end of body for optimized loading-->
+	<script src="https://cdnjs.cloudflare.com/ajax/libs/materialize/1.0.0/js/materialize.min.js"></script>
+	`+LiveUpdatesScript()+`
+	<script>
+	subscribeToSubmissionEvents("/api/events?id={{.ID}}", function(type, e) {
+		if (type === "removed" || isFinalStatus(e.status)) {
+			location.reload();
+			return;
+		}
+		updateSubmissionHeader(e);
+		if (type === "testcase" && !appendTestCaseRow(e)) {
+			location.reload();
+		}
+	});
+	</script>
+	</body>
+`))
+}
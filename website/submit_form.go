@@ -8,7 +8,6 @@ import (
 
 func SubmitForm() (*template.Template, error) {
 	return template.New("submitForm").Funcs(template.FuncMap{
-		"AsInt":                    func(mode testcase.CompilationMode) int { return int(mode) },
 		"FullCompilationCommadFor": testcase.FullCompilationCommadFor,
 	}).Parse(HtmlDocumentWrap(HtmlHead() + `
 	<body class="container">
@@ -27,19 +26,32 @@ func SubmitForm() (*template.Template, error) {
 	<div class="section center-align">
 	<form action="/api/submit" method="post" enctype="multipart/form-data">
 		<div class="row input-field">
-			<select name="problemName" required>
+			<select name="problemName" id="problemName" required>
 				<option value="" disabled selected>Choose problem</option>
 				{{range .Problems}}
-				<option value="{{.}}">{{.}}</option>
+				<option value="{{.Name}}" data-stub-families="{{.StubFamilies}}">{{.Name}}</option>
 				{{end}}
 			</select>
 	 	 </div>
 
 		<div class="row input-field">
-		  <select name="compilationMode" required>
+			<select name="language" id="language" required>
+				<option value="" disabled selected>Choose language</option>
+				{{range .Languages}}
+				<option value="{{.ID}}" data-family="{{.Family}}" data-uses-compilation-mode="{{.UsesCompilationMode}}"{{if not .Available}} disabled{{end}}>{{.Name}}{{if not .Available}} (unavailable){{end}}</option>
+				{{end}}
+			</select>
+		</div>
+
+		<div class="row">
+			<a id="stubLink" href="#" style="display: none;"><i class="material-icons left">file_download</i>This problem asks for a function only, download the solution template</a>
+		</div>
+
+		<div class="row input-field">
+		  <select name="compilationMode" id="compilationMode" required>
 			  <option value="" disabled selected>Choose compilation mode</option>
 			  {{range .CompilationModes}}
-			  <option value="{{AsInt .}}">{{.}} [{{FullCompilationCommadFor .}}]</span></option>
+			  <option value="{{.ID}}" title="{{.Description}}" data-unavailable-for="{{.UnavailableFor}}"{{if not .Available}} disabled{{end}}>{{.Name}} [{{FullCompilationCommadFor .ID}}]{{if not .Available}} (unavailable){{end}}</option>
 			  {{end}}
 		  </select>
 		</div>
@@ -52,10 +64,20 @@ func SubmitForm() (*template.Template, error) {
 				</div>
 
 				<div class="file-path-wrapper">
-					<input class="file-path validate" type="text" name="solution" placeholder="Your solution .cpp"/>
+					<input class="file-path validate" type="text" name="solution" placeholder="Your solution source file, or a .zip/.tar/.tar.gz archive of sources"/>
 				</div>
 			</div>
 		</div>
+
+		<div class="row input-field">
+			<input type="text" name="entryFile" id="entryFile"/>
+			<label for="entryFile">Entry file in the archive (optional, e.g. src/main.py)</label>
+		</div>
+
+		<div class="row input-field">
+			<input type="text" name="author" id="author"/>
+			<label for="author">Author (optional, used to filter submissions)</label>
+		</div>
 		<button class="btn waves-effect waves-light" type="submit" name="action">Submit
 			<i class="material-icons right">send</i>
 	  </button>
@@ -64,6 +86,39 @@ func SubmitForm() (*template.Template, error) {
 
 	<!--JavaScript at end of body for optimized loading-->
 	<script src="https://cdnjs.cloudflare.com/ajax/libs/materialize/1.0.0/js/materialize.min.js"></script>
+	<script>
+	// compilation modes apply only to C and C++, and only modes with an installed compiler can be chosen
+	document.getElementById("language").addEventListener("change", function(e) {
+		var option = e.target.options[e.target.selectedIndex];
+		var modes = document.getElementById("compilationMode");
+		modes.disabled = option.dataset.usesCompilationMode !== "true";
+		for (var i = 1; i < modes.options.length; i++) {
+			var unavailable = modes.options[i].dataset.unavailableFor.split(" ");
+			modes.options[i].disabled = unavailable.indexOf(option.value) !== -1;
+			if (modes.options[i].disabled && modes.selectedIndex === i) {
+				modes.selectedIndex = 0;
+			}
+		}
+		M.FormSelect.init(modes, {});
+	});
+
+	// problems with a grader offer a template of the solution
+	function updateStubLink() {
+		var problems = document.getElementById("problemName");
+		var languages = document.getElementById("language");
+		var link = document.getElementById("stubLink");
+		var problem = problems.options[problems.selectedIndex];
+		var language = languages.options[languages.selectedIndex];
+		if (!problem.value || !language.value || problem.dataset.stubFamilies.split(" ").indexOf(language.dataset.family) === -1) {
+			link.style.display = "none";
+			return;
+		}
+		link.href = "/api/problems/" + encodeURIComponent(problem.value) + "/stub?language=" + encodeURIComponent(language.value);
+		link.style.display = "";
+	}
+	document.getElementById("problemName").addEventListener("change", updateStubLink);
+	document.getElementById("language").addEventListener("change", updateStubLink);
+	</script>
 	</body>
 `))
 }